@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	archiveClient = &http.Client{Timeout: 30 * time.Second}
+	// archiveMaxBytes limits the size of each resource of a page
+	archiveMaxBytes int64 = 10 << 20
+	// archiveMaxTotal limits the size of all resources of a page,
+	// those past it are left out of the snapshot
+	archiveMaxTotal int64 = 50 << 20
+	// archiveQueue is the number of pages waiting to be archived,
+	// more are retried later
+	archiveQueue = 1024
+	// archiveRetry is how long to wait before retrying a failed page,
+	// doubling with each failure, until archiveTries have failed
+	archiveRetry = time.Hour
+	archiveTries = 5
+
+	cssURLRe = regexp.MustCompile(`url\(\s*(?:'([^']*)'|"([^"]*)"|([^)'"\s]*))\s*\)`)
+)
+
+// archives snapshots the linked pages of subscriptions with the archive option,
+// it is nil if there is no ArchiveDir.
+var archives *archiver
+
+// archiver keeps snapshots in dir/<article id>.html or dir/<article id>.warc.gz,
+// taken when an article is first seen and kept while it is in the store.
+type archiver struct {
+	dir string
+	c   chan archiveJob
+
+	mu sync.RWMutex
+	// files maps article ids to file names in dir
+	files map[string]string
+	// queued holds the article ids in c or being archived
+	queued map[string]bool
+	// failed holds the article ids of pages that couldn't be archived, to retry
+	failed map[string]archiveFailure
+}
+
+type archiveFailure struct {
+	tries int
+	next  time.Time
+}
+
+type archiveJob struct {
+	e      *Entry
+	source string
+	format string
+}
+
+func newArchiver(dir string) *archiver {
+	a := &archiver{
+		dir:    dir,
+		c:      make(chan archiveJob, archiveQueue),
+		files:  make(map[string]string),
+		queued: make(map[string]bool),
+		failed: make(map[string]archiveFailure),
+	}
+	fns, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, fn := range fns {
+		base := filepath.Base(fn)
+		if strings.HasSuffix(base, ".tmp") {
+			os.Remove(fn)
+			continue
+		}
+		if i := strings.Index(base, "."); i > 0 {
+			a.files[base[:i]] = base
+		}
+	}
+	return a
+}
+
+// url is where the snapshot of e is served, if there is one.
+func (a *archiver) url(e *Entry) string {
+	if a == nil {
+		return ""
+	}
+	a.mu.RLock()
+	fn, ok := a.files[e.key()]
+	a.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(PublicURL, "/") + "/archive/" + fn
+}
+
+// queue schedules snapshots of es for the subscriptions in users that archive them,
+// skipping entries already archived or queued.
+func (a *archiver) queue(users map[string][]Sub, es []*Entry) {
+	if a == nil {
+		return
+	}
+	subs := make(map[string]Sub)
+	for _, us := range users {
+		for _, sub := range us {
+			if sub.Archive != "" {
+				subs[sub.feed()] = sub
+			}
+		}
+	}
+	for _, e := range es {
+		sub, ok := subs[e.Feed]
+		if !ok || e.URL == "" || a.url(e) != "" {
+			continue
+		}
+		k := e.key()
+		a.mu.Lock()
+		if a.queued[k] {
+			a.mu.Unlock()
+			continue
+		}
+		select {
+		case a.c <- archiveJob{e, sub.Name, sub.Archive}:
+			a.queued[k] = true
+			a.mu.Unlock()
+		default:
+			a.mu.Unlock()
+			archivePages.Add(1, "dropped")
+			logger.Warn("archive queue full", "source", sub.Name, "url", e.URL)
+			a.fail(k)
+		}
+	}
+}
+
+// fail records that the page of the article with id k wasn't archived.
+func (a *archiver) fail(k string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f := a.failed[k]
+	f.next = time.Now().Add(archiveRetry << uint(f.tries))
+	f.tries++
+	a.failed[k] = f
+}
+
+// retries returns the entries in store whose failed pages are due to be retried.
+func (a *archiver) retries(store *Store) []*Entry {
+	if a == nil {
+		return nil
+	}
+	now := time.Now()
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var es []*Entry
+	for k, f := range a.failed {
+		if f.tries >= archiveTries || now.Before(f.next) {
+			continue
+		}
+		if e := store.Get(k); e != nil {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+// prune deletes the snapshots and failures of articles no longer in store.
+func (a *archiver) prune(store *Store) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, name := range a.files {
+		if store.Get(k) != nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.dir, name)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("remove archived page", "file", name, "error", err)
+			continue
+		}
+		delete(a.files, k)
+		logger.Debug("removed archived page", "file", name)
+	}
+	for k := range a.failed {
+		if store.Get(k) == nil {
+			delete(a.failed, k)
+		}
+	}
+}
+
+// run takes snapshots one at a time until ctx is done.
+func (a *archiver) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-a.c:
+			err := a.archive(ctx, j)
+			if err != nil {
+				archivePages.Add(1, "error")
+				logger.Warn("archive page", "source", j.source, "url", j.e.URL, "error", err)
+				a.fail(j.e.key())
+			}
+			a.mu.Lock()
+			delete(a.queued, j.e.key())
+			if err == nil {
+				delete(a.failed, j.e.key())
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// archive snapshots the page of j.e with its stylesheets and images.
+func (a *archiver) archive(ctx context.Context, j archiveJob) error {
+	t := time.Now()
+	ctx, sp := startSpan(ctx, "archive page", "source", j.source, "url", j.e.URL, "format", j.format)
+	sp.kind = spanClient
+	defer sp.End()
+
+	s := &snapshot{ctx: ctx}
+	page, err := s.page(j.e.URL)
+	if err != nil {
+		sp.SetError(err)
+		return err
+	}
+	var b bytes.Buffer
+	ext := ".html"
+	switch j.format {
+	case "warc":
+		ext = ".warc.gz"
+		err = s.writeWARC(&b)
+	default:
+		b.WriteString(page)
+	}
+	if err != nil {
+		sp.SetError(err)
+		return err
+	}
+
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return err
+	}
+	name := j.e.key() + ext
+	fn := filepath.Join(a.dir, name)
+	if err := ioutil.WriteFile(fn+".tmp", b.Bytes(), 0644); err != nil {
+		os.Remove(fn + ".tmp")
+		return err
+	}
+	if err := os.Rename(fn+".tmp", fn); err != nil {
+		os.Remove(fn + ".tmp")
+		return err
+	}
+	sp.SetAttributes("resources", len(s.records), "bytes", b.Len())
+
+	a.mu.Lock()
+	a.files[j.e.key()] = name
+	a.mu.Unlock()
+	archivePages.Add(1, "ok")
+	logger.Info("archived page", "source", j.source, "url", j.e.URL, "file", name, "resources", len(s.records), "bytes", b.Len(), "duration", time.Since(t))
+	return nil
+}
+
+// ServeHTTP serves snapshots under /archive/.
+// Pages are sandboxed so nothing they contain runs or loads from elsewhere.
+func (a *archiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	a.mu.RLock()
+	ok := strings.HasPrefix(r.URL.Path, "/archive/") && a.files[strings.SplitN(name, ".", 2)[0]] == name
+	a.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(name, ".warc.gz") {
+		w.Header().Set("Content-Type", "application/warc")
+		w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	} else {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:; sandbox")
+	}
+	http.ServeFile(w, r, filepath.Join(a.dir, name))
+}
+
+// snapshot fetches a page and the resources it needs,
+// recording every response for WARC output.
+type snapshot struct {
+	ctx     context.Context
+	total   int64
+	records []warcResponse
+	// inlined caches data uris by url
+	inlined map[string]string
+}
+
+type warcResponse struct {
+	url  string
+	date time.Time
+	res  *http.Response
+	body []byte
+}
+
+// fetch gets u, keeping to the size limits.
+func (s *snapshot) fetch(u string) ([]byte, *http.Response, error) {
+	if s.total >= archiveMaxTotal {
+		return nil, nil, fmt.Errorf("over %v bytes", archiveMaxTotal)
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "readss")
+	date := time.Now()
+	res, err := archiveClient.Do(req.WithContext(s.ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("status %v", res.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, archiveMaxBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(b)) > archiveMaxBytes {
+		return nil, nil, fmt.Errorf("over %v bytes", archiveMaxBytes)
+	}
+	s.total += int64(len(b))
+	s.records = append(s.records, warcResponse{res.Request.URL.String(), date, res, b})
+	return b, res, nil
+}
+
+// page fetches u and returns it as a single html document,
+// with stylesheets, images and icons inlined, scripts removed, and links made absolute.
+func (s *snapshot) page(u string) (string, error) {
+	b, res, err := s.fetch(u)
+	if err != nil {
+		return "", err
+	}
+	ct := res.Header.Get("Content-Type")
+	if ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("content type %v", ct)
+	}
+	r, err := charset.NewReader(bytes.NewReader(b), ct)
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return "", err
+	}
+	base := res.Request.URL
+	if href, ok := doc.Find("base[href]").Attr("href"); ok {
+		if bu, err := base.Parse(href); err == nil {
+			base = bu
+		}
+	}
+	doc.Find("base").Remove()
+
+	doc.Find("script, noscript, iframe, object, embed, picture source, meta[http-equiv]").Remove()
+	doc.Find("meta[charset]").Remove()
+	doc.Find("head").PrependHtml(`<meta charset="utf-8">`)
+	doc.Find("*").Each(func(i int, sel *goquery.Selection) {
+		var handlers []string
+		for _, attr := range sel.Nodes[0].Attr {
+			if strings.HasPrefix(strings.ToLower(attr.Key), "on") {
+				handlers = append(handlers, attr.Key)
+			}
+		}
+		for _, k := range handlers {
+			sel.RemoveAttr(k)
+		}
+	})
+
+	doc.Find("link[href]").Each(func(i int, sel *goquery.Selection) {
+		rel := " " + strings.ToLower(sel.AttrOr("rel", "")) + " "
+		ref, _ := absURL(base, sel.AttrOr("href", ""))
+		switch {
+		case strings.Contains(rel, " stylesheet "):
+			css, err := s.stylesheet(ref)
+			if err != nil {
+				logger.Debug("archive stylesheet", "url", ref, "error", err)
+				sel.Remove()
+				return
+			}
+			style := "<style"
+			if media, ok := sel.Attr("media"); ok {
+				style += ` media="` + html.EscapeString(media) + `"`
+			}
+			sel.ReplaceWithHtml(style + ">" + strings.Replace(css, "</", `<\/`, -1) + "</style>")
+		case strings.Contains(rel, "icon"):
+			sel.SetAttr("href", s.inline(ref))
+		default:
+			sel.SetAttr("href", ref)
+		}
+	})
+	doc.Find("style").Each(func(i int, sel *goquery.Selection) {
+		sel.SetText(s.inlineCSS(base, sel.Text()))
+	})
+	doc.Find("[style]").Each(func(i int, sel *goquery.Selection) {
+		sel.SetAttr("style", s.inlineCSS(base, sel.AttrOr("style", "")))
+	})
+	doc.Find("img, input[type=image]").Each(func(i int, sel *goquery.Selection) {
+		src := sel.AttrOr("src", "")
+		for _, lazy := range []string{"data-src", "data-original", "data-lazy-src"} {
+			if v := sel.AttrOr(lazy, ""); v != "" && (src == "" || strings.HasPrefix(src, "data:")) {
+				src = v
+			}
+		}
+		sel.RemoveAttr("srcset")
+		sel.RemoveAttr("sizes")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		ref, _ := absURL(base, src)
+		sel.SetAttr("src", s.inline(ref))
+	})
+	for _, attr := range []string{"href", "src", "action", "poster"} {
+		doc.Find("[" + attr + "]").Each(func(i int, sel *goquery.Selection) {
+			v := sel.AttrOr(attr, "")
+			if strings.HasPrefix(v, "data:") || strings.HasPrefix(v, "#") {
+				return
+			}
+			if ref, ok := absURL(base, v); ok {
+				sel.SetAttr(attr, ref)
+			}
+		})
+	}
+
+	h, err := doc.Html()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<!-- archived by readss from %s at %s -->\n%s", strings.Replace(u, "--", "%2D%2D", -1), time.Now().UTC().Format(time.RFC3339), h), nil
+}
+
+// stylesheet fetches the stylesheet at u with its url references inlined.
+func (s *snapshot) stylesheet(u string) (string, error) {
+	b, res, err := s.fetch(u)
+	if err != nil {
+		return "", err
+	}
+	r, err := charset.NewReader(bytes.NewReader(b), res.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+	b, err = ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return s.inlineCSS(res.Request.URL, string(b)), nil
+}
+
+// inlineCSS replaces url() references in css, relative to base, with data uris.
+func (s *snapshot) inlineCSS(base *url.URL, css string) string {
+	return cssURLRe.ReplaceAllStringFunc(css, func(m string) string {
+		sm := cssURLRe.FindStringSubmatch(m)
+		ref := sm[1] + sm[2] + sm[3]
+		if ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			return m
+		}
+		u, ok := absURL(base, ref)
+		if !ok {
+			return m
+		}
+		return `url("` + s.inline(u) + `")`
+	})
+}
+
+// inline returns u as a data uri, or u itself if it can't be fetched.
+func (s *snapshot) inline(u string) string {
+	if d, ok := s.inlined[u]; ok {
+		return d
+	}
+	if s.inlined == nil {
+		s.inlined = make(map[string]string)
+	}
+	d := u
+	b, res, err := s.fetch(u)
+	if err != nil {
+		logger.Debug("archive resource", "url", u, "error", err)
+	} else {
+		ct := res.Header.Get("Content-Type")
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			ct = mt
+		} else {
+			ct = http.DetectContentType(b)
+		}
+		d = "data:" + ct + ";base64," + base64.StdEncoding.EncodeToString(b)
+	}
+	s.inlined[u] = d
+	return d
+}
+
+// absURL makes ref absolute against base, only allowing http(s) results.
+func absURL(base *url.URL, ref string) (string, bool) {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ref, false
+	}
+	return u.String(), true
+}
+
+// writeWARC writes the recorded responses as a WARC 1.1 file,
+// each record a separate gzip member as is customary.
+func (s *snapshot) writeWARC(w io.Writer) error {
+	info := "software: readss\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"
+	if err := writeWARCRecord(w, "warcinfo", "", time.Now(), "application/warc-fields", []byte(info), ""); err != nil {
+		return err
+	}
+	for _, r := range s.records {
+		var block bytes.Buffer
+		fmt.Fprintf(&block, "HTTP/%d.%d %s\r\n", r.res.ProtoMajor, r.res.ProtoMinor, r.res.Status)
+		h := make(http.Header, len(r.res.Header))
+		for k, v := range r.res.Header {
+			h[k] = v
+		}
+		// the body is stored decoded and whole
+		h.Del("Transfer-Encoding")
+		h.Del("Content-Encoding")
+		h.Set("Content-Length", fmt.Sprint(len(r.body)))
+		h.Write(&block)
+		block.WriteString("\r\n")
+		block.Write(r.body)
+		digest := sha1.Sum(r.body)
+		if err := writeWARCRecord(w, "response", r.url, r.date, "application/http;msgtype=response", block.Bytes(), "sha1:"+base32.StdEncoding.EncodeToString(digest[:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeWARCRecord(w io.Writer, typ, target string, date time.Time, ct string, block []byte, digest string) error {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	zw := gzip.NewWriter(w)
+	fmt.Fprintf(zw, "WARC/1.1\r\nWARC-Type: %s\r\nWARC-Record-ID: <urn:uuid:%x-%x-%x-%x-%x>\r\nWARC-Date: %s\r\n",
+		typ, id[0:4], id[4:6], id[6:8], id[8:10], id[10:], date.UTC().Format(time.RFC3339))
+	if target != "" {
+		fmt.Fprintf(zw, "WARC-Target-URI: %s\r\n", target)
+	}
+	if digest != "" {
+		fmt.Fprintf(zw, "WARC-Payload-Digest: %s\r\n", digest)
+	}
+	fmt.Fprintf(zw, "Content-Type: %s\r\nContent-Length: %d\r\n\r\n", ct, len(block))
+	zw.Write(block)
+	zw.Write([]byte("\r\n\r\n"))
+	return zw.Close()
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testArchivePage = `<!DOCTYPE html>
+<html><head><title>A page</title>
+<meta charset="iso-8859-1">
+<link rel="stylesheet" href="style.css" media="screen">
+<link rel="icon" href="/favicon.png">
+<style>h1 { background: url('bg.png') }</style>
+<script src="/app.js"></script>
+</head><body onload="track()">
+<h1 style="background-image: url(&quot;bg.png&quot;)">A page</h1>
+<p><img src="img.png" srcset="img-2x.png 2x" onerror="alert(1)">
+<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" data-src="/lazy.png"></p>
+<p><a href="/other">other</a> <a href="#top">top</a></p>
+<iframe src="https://ads.example/"></iframe>
+</body></html>`
+
+// testArchiveSite serves testArchivePage at /page and its resources,
+// counting requests by path.
+func testArchiveSite() (*httptest.Server, map[string]int, *sync.Mutex) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(testArchivePage))
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`body { background: url(bg.png) } /* </style><script>alert(1)</script> */`))
+		case "/img.png", "/bg.png", "/lazy.png", "/favicon.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testPNG))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts, hits, &mu
+}
+
+func TestArchiveHTML(t *testing.T) {
+	ts, hits, mu := testArchiveSite()
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := newArchiver(dir)
+	e := &Entry{ID: "1", Feed: ts.URL + "/feed", URL: ts.URL + "/page"}
+	if err := a.archive(context.Background(), archiveJob{e, "site", "html"}); err != nil {
+		t.Fatal(err)
+	}
+	u := a.url(e)
+	if !strings.HasSuffix(u, "/archive/"+e.key()+".html") {
+		t.Fatalf("url = %q, want the html snapshot", u)
+	}
+
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/archive/"+e.key()+".html", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Security-Policy"), "sandbox") {
+		t.Fatalf("serve = %v %v, want a sandboxed page", w.Code, w.Header())
+	}
+	page := w.Body.String()
+	if !strings.HasPrefix(page, "<!-- archived by readss from "+ts.URL+"/page at ") {
+		t.Errorf("page header = %q", strings.SplitN(page, "\n", 2)[0])
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte(testPNG))
+	for _, sel := range []string{"script", "iframe", "[onload]", "[onerror]", "[srcset]", `link[rel="stylesheet"]`, `meta[charset="iso-8859-1"]`} {
+		if n := doc.Find(sel).Length(); n != 0 {
+			t.Errorf("snapshot has %d %v", n, sel)
+		}
+	}
+	if cs, _ := doc.Find("meta[charset]").Attr("charset"); cs != "utf-8" {
+		t.Errorf("charset = %q, want utf-8", cs)
+	}
+	imgs := doc.Find("img")
+	if imgs.Length() != 2 || imgs.Eq(0).AttrOr("src", "") != png || imgs.Eq(1).AttrOr("src", "") != png {
+		t.Errorf("images not inlined: %v", imgs.Nodes)
+	}
+	if href := doc.Find(`link[rel="icon"]`).AttrOr("href", ""); href != png {
+		t.Errorf("icon = %q, want inlined", href)
+	}
+	styles := doc.Find("style")
+	if styles.Length() != 2 || styles.Eq(0).AttrOr("media", "") != "screen" {
+		t.Fatalf("styles = %d, want the inlined stylesheet with its media and the page style", styles.Length())
+	}
+	for i := 0; i < 2; i++ {
+		if css := styles.Eq(i).Text(); !strings.Contains(css, `url("`+png+`")`) {
+			t.Errorf("style %d = %q, want the background inlined", i, css)
+		}
+	}
+	// the comment stays in the stylesheet instead of closing the style element
+	if css := styles.Eq(0).Text(); !strings.Contains(css, `<\/style><script>alert(1)`) {
+		t.Errorf("stylesheet = %q, want its escaped comment", css)
+	}
+	if style := doc.Find("h1").AttrOr("style", ""); !strings.Contains(style, png) {
+		t.Errorf("style attribute = %q, want the background inlined", style)
+	}
+	links := doc.Find("a")
+	if links.Eq(0).AttrOr("href", "") != ts.URL+"/other" || links.Eq(1).AttrOr("href", "") != "#top" {
+		t.Errorf("links = %q %q, want absolute and fragment", links.Eq(0).AttrOr("href", ""), links.Eq(1).AttrOr("href", ""))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range []string{"/page", "/style.css", "/bg.png", "/img.png", "/lazy.png", "/favicon.png"} {
+		if hits[p] != 1 {
+			t.Errorf("fetched %v %d times, want once", p, hits[p])
+		}
+	}
+	if hits["/app.js"] != 0 || hits["/img-2x.png"] != 0 {
+		t.Errorf("fetched scripts or srcset images: %v", hits)
+	}
+}
+
+type warcRecord struct {
+	header textproto.MIMEHeader
+	block  []byte
+}
+
+// readWARC reads the records of a WARC file, checking each is its own gzip member.
+func readWARC(t *testing.T, b []byte) []warcRecord {
+	t.Helper()
+	var rs []warcRecord
+	br := bytes.NewReader(b)
+	for br.Len() > 0 {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr.Multistream(false)
+		member, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bufio.NewReader(bytes.NewReader(member))
+		version, err := r.ReadString('\n')
+		if err != nil || version != "WARC/1.1\r\n" {
+			t.Fatalf("record version = %q, %v", version, err)
+		}
+		h, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := strconv.Atoi(h.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := make([]byte, n)
+		if _, err := io.ReadFull(r, block); err != nil {
+			t.Fatal(err)
+		}
+		if rest, _ := ioutil.ReadAll(r); string(rest) != "\r\n\r\n" {
+			t.Fatalf("record trailer = %q, want a single record per member", rest)
+		}
+		rs = append(rs, warcRecord{h, block})
+	}
+	return rs
+}
+
+func TestArchiveWARC(t *testing.T) {
+	ts, _, _ := testArchiveSite()
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := newArchiver(dir)
+	e := &Entry{ID: "1", Feed: ts.URL + "/feed", URL: ts.URL + "/page"}
+	if err := a.archive(context.Background(), archiveJob{e, "site", "warc"}); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/archive/"+e.key()+".warc.gz", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/warc" {
+		t.Fatalf("serve = %v %v, want a warc download", w.Code, w.Header())
+	}
+
+	rs := readWARC(t, w.Body.Bytes())
+	if len(rs) == 0 || rs[0].header.Get("WARC-Type") != "warcinfo" || !bytes.Contains(rs[0].block, []byte("software: readss")) {
+		t.Fatalf("first record = %v, want warcinfo", rs)
+	}
+	want := map[string]string{
+		"/page":        testArchivePage,
+		"/style.css":   `body { background: url(bg.png) } /* </style><script>alert(1)</script> */`,
+		"/bg.png":      testPNG,
+		"/img.png":     testPNG,
+		"/lazy.png":    testPNG,
+		"/favicon.png": testPNG,
+	}
+	ids := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, r := range rs {
+		id := r.header.Get("WARC-Record-ID")
+		if !strings.HasPrefix(id, "<urn:uuid:") || ids[id] {
+			t.Errorf("record id %q, want a distinct uuid", id)
+		}
+		ids[id] = true
+		if r.header.Get("WARC-Date") == "" {
+			t.Errorf("record %v has no date", id)
+		}
+		if r.header.Get("WARC-Type") != "response" {
+			continue
+		}
+		target := r.header.Get("WARC-Target-URI")
+		p := strings.TrimPrefix(target, ts.URL)
+		seen[p] = true
+		if r.header.Get("Content-Type") != "application/http;msgtype=response" {
+			t.Errorf("%v content type = %q", p, r.header.Get("Content-Type"))
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(r.block)), nil)
+		if err != nil {
+			t.Fatalf("%v: %v", p, err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("%v: %v", p, err)
+		}
+		if res.StatusCode != http.StatusOK || string(body) != want[p] {
+			t.Errorf("%v = %v %q, want %q", p, res.Status, body, want[p])
+		}
+		if res.ContentLength != int64(len(body)) {
+			t.Errorf("%v content length = %d, want %d", p, res.ContentLength, len(body))
+		}
+		digest := sha1.Sum(body)
+		if got, want := r.header.Get("WARC-Payload-Digest"), "sha1:"+base32.StdEncoding.EncodeToString(digest[:]); got != want {
+			t.Errorf("%v digest = %q, want %q", p, got, want)
+		}
+	}
+	if len(seen) != len(want) || len(rs) != len(want)+1 {
+		t.Errorf("responses = %v, want one of each of %d resources", seen, len(want))
+	}
+	for p := range want {
+		if !seen[p] {
+			t.Errorf("no response for %v", p)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Account lets a client log in as User.
+type Account struct {
+	Login    string
+	Password string
+	User     string
+}
+
+// parseAccounts reads a csv file of accounts,
+// each record is: login, password, followed by optional key=value options:
+//
+//	user=user whose subscriptions are served, default ""
+//	password_env=environment variable holding the password
+func parseAccounts(fn string) map[string]Account {
+	as, errs := readAccounts(fn)
+	for _, err := range errs {
+		logger.Warn("parse accounts", "file", fn, "error", err)
+	}
+	m := make(map[string]Account, len(as))
+	for _, a := range as {
+		m[a.Login] = a
+	}
+	return m
+}
+
+// readAccounts is parseAccounts, returning the problems it skipped.
+func readAccounts(fn string) ([]Account, []error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rr, err := cr.ReadAll()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	as := make([]Account, 0, len(rr))
+	for _, r := range rr {
+		if len(r) < 2 || r[0] == "" {
+			errs = append(errs, fmt.Errorf("short record %v", r))
+			continue
+		}
+		a := Account{
+			Login:    r[0],
+			Password: r[1],
+		}
+		for _, opt := range r[2:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				errs = append(errs, fmt.Errorf("%v: bad option %q", a.Login, opt))
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "user":
+				a.User = kv[1]
+			case "password_env":
+				a.Password = os.Getenv(kv[1])
+			default:
+				errs = append(errs, fmt.Errorf("%v: unknown option %q", a.Login, kv[0]))
+			}
+		}
+		if a.Password == "" {
+			errs = append(errs, fmt.Errorf("%v: empty password", a.Login))
+			continue
+		}
+		as = append(as, a)
+	}
+	return as, errs
+}
+
+// loadAccounts rereads Accounts.
+func (s *Server) loadAccounts() {
+	var accounts map[string]Account
+	if Accounts != "" {
+		accounts = parseAccounts(Accounts)
+	}
+	s.mu.Lock()
+	s.accounts = accounts
+	s.mu.Unlock()
+}
+
+// account returns the account with login, as of the last refresh.
+func (s *Server) account(login string) (Account, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.accounts[login]
+	return a, ok
+}
+
+// checkAccount returns the account with login if password matches.
+func (s *Server) checkAccount(login, password string) (Account, bool) {
+	a, ok := s.account(login)
+	if !ok || subtle.ConstantTimeCompare([]byte(a.Password), []byte(password)) != 1 {
+		return Account{}, false
+	}
+	return a, true
+}
+
+type accountKey struct{}
+
+// authInterceptor authenticates rpcs with the basic credentials of an account
+// in the authorization metadata, for rpcUser.
+// Requests without credentials pass through as anonymous.
+func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 {
+		return handler(ctx, req)
+	}
+	a, ok := s.basicAccount(auth[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "bad credentials")
+	}
+	return handler(context.WithValue(ctx, accountKey{}, a), req)
+}
+
+// basicAccount finds the account matching a basic authorization header.
+func (s *Server) basicAccount(h string) (Account, bool) {
+	if !strings.HasPrefix(h, "Basic ") {
+		return Account{}, false
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "Basic "))
+	if err != nil {
+		return Account{}, false
+	}
+	kv := strings.SplitN(string(b), ":", 2)
+	if len(kv) != 2 {
+		return Account{}, false
+	}
+	return s.checkAccount(kv[0], kv[1])
+}
+
+// rpcUser is the user an rpc is served as:
+// the user of the authenticated account,
+// or the anonymous user "" of a single file config.
+// The requested user must be empty or match.
+func rpcUser(ctx context.Context, requested string) (string, error) {
+	if a, ok := ctx.Value(accountKey{}).(Account); ok {
+		if requested != "" && requested != a.User {
+			return "", status.Errorf(codes.PermissionDenied, "account %q can't read user %q", a.Login, requested)
+		}
+		return a.User, nil
+	}
+	if requested != "" {
+		return "", status.Errorf(codes.Unauthenticated, "user %q needs an account login", requested)
+	}
+	return "", nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"seankhliao.com/readss/readss"
+)
+
+func basicHeader(login, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login+":"+password))
+}
+
+func testAuthServer() *Server {
+	return &Server{
+		accounts: map[string]Account{
+			"me@x":  {Login: "me@x", Password: "pw", User: "alice"},
+			"anon":  {Login: "anon", Password: "pw2"},
+			"other": {Login: "other", Password: "pw3", User: "bob"},
+		},
+		users: map[string][]Sub{
+			"":      nil,
+			"alice": nil,
+		},
+		lists: map[string]*readss.ListReply{
+			"":      {Articles: []*readss.Article{{Title: "anonymous"}}},
+			"alice": {Articles: []*readss.Article{{Title: "alices"}}},
+		},
+	}
+}
+
+func TestRPCUser(t *testing.T) {
+	alice := context.WithValue(context.Background(), accountKey{}, Account{Login: "me@x", User: "alice"})
+	anon := context.WithValue(context.Background(), accountKey{}, Account{Login: "anon"})
+	tcs := []struct {
+		name      string
+		ctx       context.Context
+		requested string
+		want      string
+		code      codes.Code
+	}{
+		{name: "anonymous", ctx: context.Background()},
+		{name: "anonymous asks for user", ctx: context.Background(), requested: "alice", code: codes.Unauthenticated},
+		{name: "account default", ctx: alice, want: "alice"},
+		{name: "account own user", ctx: alice, requested: "alice", want: "alice"},
+		{name: "account other user", ctx: alice, requested: "bob", code: codes.PermissionDenied},
+		{name: "account of anonymous user", ctx: anon},
+		{name: "account of anonymous user asks for user", ctx: anon, requested: "alice", code: codes.PermissionDenied},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rpcUser(tc.ctx, tc.requested)
+			if status.Code(err) != tc.code {
+				t.Fatalf("rpcUser(%q) err = %v, want code %v", tc.requested, err, tc.code)
+			}
+			if got != tc.want {
+				t.Errorf("rpcUser(%q) = %q, want %q", tc.requested, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	s := testAuthServer()
+	tcs := []struct {
+		name   string
+		auth   []string
+		called bool
+		login  string
+		code   codes.Code
+	}{
+		{name: "no credentials", called: true},
+		{name: "good credentials", auth: []string{basicHeader("me@x", "pw")}, called: true, login: "me@x"},
+		{name: "password with colon", auth: []string{basicHeader("me@x", "pw:")}, code: codes.Unauthenticated},
+		{name: "bad password", auth: []string{basicHeader("me@x", "nope")}, code: codes.Unauthenticated},
+		{name: "unknown login", auth: []string{basicHeader("who", "pw")}, code: codes.Unauthenticated},
+		{name: "not basic", auth: []string{"Bearer abc"}, code: codes.Unauthenticated},
+		{name: "bad base64", auth: []string{"Basic !!!"}, code: codes.Unauthenticated},
+		{name: "no colon", auth: []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("me@x"))}, code: codes.Unauthenticated},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tc.auth != nil {
+				md.Set("authorization", tc.auth...)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			var called bool
+			var login string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				if a, ok := ctx.Value(accountKey{}).(Account); ok {
+					login = a.Login
+				}
+				return req, nil
+			}
+			_, err := s.authInterceptor(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/readss.Lister/List"}, handler)
+			if status.Code(err) != tc.code {
+				t.Fatalf("authInterceptor err = %v, want code %v", err, tc.code)
+			}
+			if called != tc.called {
+				t.Errorf("handler called = %v, want %v", called, tc.called)
+			}
+			if login != tc.login {
+				t.Errorf("handler login = %q, want %q", login, tc.login)
+			}
+		})
+	}
+}
+
+func TestListAuth(t *testing.T) {
+	s := testAuthServer()
+	list := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.List(ctx, req.(*readss.ListRequest))
+	}
+	tcs := []struct {
+		name  string
+		auth  string
+		user  string
+		title string
+		code  codes.Code
+	}{
+		{name: "anonymous", title: "anonymous"},
+		{name: "anonymous asks for user", user: "alice", code: codes.Unauthenticated},
+		{name: "account", auth: basicHeader("me@x", "pw"), title: "alices"},
+		{name: "account names own user", auth: basicHeader("me@x", "pw"), user: "alice", title: "alices"},
+		{name: "account reads other user", auth: basicHeader("me@x", "pw"), user: "bob", code: codes.PermissionDenied},
+		{name: "account of unknown user", auth: basicHeader("other", "pw3"), code: codes.NotFound},
+		{name: "bad password", auth: basicHeader("me@x", "pw3"), code: codes.Unauthenticated},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tc.auth != "" {
+				md.Set("authorization", tc.auth)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			res, err := s.authInterceptor(ctx, &readss.ListRequest{User: tc.user}, &grpc.UnaryServerInfo{}, list)
+			if status.Code(err) != tc.code {
+				t.Fatalf("List err = %v, want code %v", err, tc.code)
+			}
+			if err != nil {
+				return
+			}
+			ats := res.(*readss.ListReply).Articles
+			if len(ats) != 1 || ats[0].Title != tc.title {
+				t.Errorf("List articles = %v, want %q", ats, tc.title)
+			}
+		})
+	}
+}
+
+func TestListBeforeRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "alice.csv"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{fn: dir}
+	ctx := context.WithValue(context.Background(), accountKey{}, Account{Login: "me@x", User: "alice"})
+	res, err := s.List(ctx, &readss.ListRequest{})
+	if err != nil || len(res.Articles) != 0 {
+		t.Errorf("List known user = %v, %v, want empty reply", res, err)
+	}
+	ctx = context.WithValue(context.Background(), accountKey{}, Account{Login: "other", User: "bob"})
+	_, err = s.List(ctx, &readss.ListRequest{})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("List unknown user err = %v, want NotFound", err)
+	}
+}
+
+func TestReadAccounts(t *testing.T) {
+	os.Setenv("READSS_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("READSS_TEST_PASSWORD")
+
+	f, err := ioutil.TempFile("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "me@x,pw,user=alice\n"+
+		"env,,password_env=READSS_TEST_PASSWORD\n"+
+		"empty,\n"+
+		"bad,pw,nokey\n"+
+		"short\n")
+	f.Close()
+
+	as, errs := readAccounts(f.Name())
+	if len(errs) != 3 {
+		t.Errorf("readAccounts errs = %v, want 3", errs)
+	}
+	want := map[string]Account{
+		"me@x": {Login: "me@x", Password: "pw", User: "alice"},
+		"env":  {Login: "env", Password: "secret"},
+		"bad":  {Login: "bad", Password: "pw"},
+	}
+	if len(as) != len(want) {
+		t.Fatalf("readAccounts = %v, want %v", as, want)
+	}
+	for _, a := range as {
+		if want[a.Login] != a {
+			t.Errorf("readAccounts %q = %v, want %v", a.Login, a, want[a.Login])
+		}
+	}
+}
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>shared</title>
+<item><title>post</title><link>https://example.com%s/post</link><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate></item>
+</channel></rss>`
+
+func TestUserArticles(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		fmt.Fprintf(w, testFeed, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	users := map[string][]Sub{
+		"alice": {{Name: "Shared A", URL: ts.URL + "/shared"}, {Name: "Own", URL: ts.URL + "/alice"}},
+		"bob":   {{Name: "Shared B", URL: ts.URL + "/shared"}},
+		"carol": nil,
+	}
+	uats := userArticles(users, fetchEntries(context.Background(), users), nil)
+
+	for path, n := range hits {
+		if n != 1 {
+			t.Errorf("fetched %v %d times, want once", path, n)
+		}
+	}
+	if len(hits) != 2 {
+		t.Errorf("fetched %v, want 2 distinct feeds", hits)
+	}
+
+	sources := func(ats []*readss.Article) map[string]bool {
+		m := make(map[string]bool)
+		for _, at := range ats {
+			m[at.Source] = true
+		}
+		return m
+	}
+	if got := sources(uats["alice"].Articles); len(got) != 2 || !got["Shared A"] || !got["Own"] {
+		t.Errorf("alice sources = %v, want Shared A and Own", got)
+	}
+	if got := sources(uats["bob"].Articles); len(got) != 1 || !got["Shared B"] {
+		t.Errorf("bob sources = %v, want Shared B", got)
+	}
+	if l, ok := uats["carol"]; !ok || len(l.Articles) != 0 {
+		t.Errorf("carol list = %v, %v, want empty", l, ok)
+	}
+}
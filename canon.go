@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	resolveClient = &http.Client{Timeout: 15 * time.Second}
+	// resolveWorkers limits the number of redirects resolved at once
+	resolveWorkers = 4
+
+	// wrapperRe matches urls of feed proxies that redirect to the real article
+	wrapperRe = regexp.MustCompile(`(?i)^https?://(feedproxy\.google\.com/~r/|feeds\.feedburner\.com/~r/|[^/]*\.feedsportal\.com/|rss\.feedsportal\.com/)`)
+)
+
+// canonicalURL normalizes u:
+// the scheme and host are lowercased, default ports and empty fragments dropped,
+// and query parameters matching TrackingParams removed.
+// Entries ending in * match by prefix.
+func canonicalURL(u string) string {
+	pu, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || pu.Host == "" {
+		return u
+	}
+	pu.Scheme = strings.ToLower(pu.Scheme)
+	pu.Host = strings.ToLower(pu.Host)
+	switch {
+	case pu.Scheme == "http" && strings.HasSuffix(pu.Host, ":80"):
+		pu.Host = strings.TrimSuffix(pu.Host, ":80")
+	case pu.Scheme == "https" && strings.HasSuffix(pu.Host, ":443"):
+		pu.Host = strings.TrimSuffix(pu.Host, ":443")
+	}
+	if pu.Path == "" {
+		pu.Path = "/"
+	}
+
+	// filter RawQuery by hand to keep the original order
+	var keep []string
+	for _, kv := range strings.Split(pu.RawQuery, "&") {
+		if kv == "" {
+			continue
+		}
+		k := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			k = kv[:i]
+		}
+		if uk, err := url.QueryUnescape(k); err == nil {
+			k = uk
+		}
+		if !isTrackingParam(k) {
+			keep = append(keep, kv)
+		}
+	}
+	pu.RawQuery = strings.Join(keep, "&")
+	pu.ForceQuery = false
+	return pu.String()
+}
+
+func isTrackingParam(k string) bool {
+	k = strings.ToLower(k)
+	for _, p := range TrackingParams {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(k, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if k == p {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveEntries replaces urls of feed proxy wrappers with where they redirect to,
+// reusing previous resolutions from the store.
+func resolveEntries(ctx context.Context, feeds map[string][]*Entry, store *Store) {
+	ctx, sp := startSpan(ctx, "resolve")
+	defer sp.End()
+
+	var todo []*Entry
+	for _, es := range feeds {
+		for _, e := range es {
+			if !wrapperRe.MatchString(e.URL) {
+				continue
+			}
+			if old := store.Get(e.key()); old != nil && old.OrigURL == e.OrigURL && old.URL != e.URL {
+				e.URL = old.URL
+				continue
+			}
+			todo = append(todo, e)
+		}
+	}
+
+	sp.SetAttributes("urls", len(todo))
+	c := make(chan *Entry)
+	var wg sync.WaitGroup
+	for i := 0; i < resolveWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range c {
+				u, err := resolveURL(ctx, e.URL)
+				if err != nil {
+					logger.Warn("resolve redirect", "url", e.URL, "error", err)
+					continue
+				}
+				e.URL = canonicalURL(u)
+			}
+		}()
+	}
+loop:
+	for _, e := range todo {
+		select {
+		case c <- e:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(c)
+	wg.Wait()
+}
+
+// resolveURL follows redirects from u and returns the final url.
+func resolveURL(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := resolveClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	return res.Request.URL.String(), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	defer func(ps []string) { TrackingParams = ps }(TrackingParams)
+	TrackingParams = []string{"utm_*", "fbclid", "ref_src"}
+
+	tcs := []struct {
+		in, want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"  https://example.com/a\n", "https://example.com/a"},
+		{"HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"https://example.com", "https://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:443/a", "http://example.com:443/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"https://example.com/a#", "https://example.com/a"},
+		{"https://example.com/a#section", "https://example.com/a#section"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"https://example.com/a?utm_source=rss&utm_medium=feed", "https://example.com/a"},
+		{"https://example.com/a?id=2&utm_source=rss&page=1&fbclid=x", "https://example.com/a?id=2&page=1"},
+		{"https://example.com/a?UTM_Source=rss&b=1", "https://example.com/a?b=1"},
+		{"https://example.com/a?utm%5Fsource=rss&b=1", "https://example.com/a?b=1"},
+		{"https://example.com/a?z=1&a=2&ref_src", "https://example.com/a?z=1&a=2"},
+		{"https://example.com/a?fbclid_like=1", "https://example.com/a?fbclid_like=1"},
+		{"https://example.com/a?q=a%20b&&utm_x=1", "https://example.com/a?q=a%20b"},
+		{"/relative/path", "/relative/path"},
+		{"not a url", "not a url"},
+		{"http://[::1", "http://[::1"},
+		{"", ""},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := canonicalURL(tc.in); got != tc.want {
+				t.Errorf("canonicalURL(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWrapperRe(t *testing.T) {
+	tcs := []struct {
+		in   string
+		want bool
+	}{
+		{"http://feedproxy.google.com/~r/blog/~3/abc/post", true},
+		{"https://feeds.feedburner.com/~r/blog/~3/abc/", true},
+		{"https://da.feedsportal.com/c/1/f/2/s/3/story01.htm", true},
+		{"https://feeds.feedburner.com/blog", false},
+		{"https://example.com/feedproxy.google.com/~r/", false},
+	}
+	for _, tc := range tcs {
+		if got := wrapperRe.MatchString(tc.in); got != tc.want {
+			t.Errorf("wrapperRe.MatchString(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
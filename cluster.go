@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+
+	"seankhliao.com/readss/readss"
+)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "have": true,
+	"in": true, "is": true, "it": true, "its": true, "new": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "with": true, "will": true, "you": true, "your": true,
+}
+
+// cluster groups near duplicate articles in rs, setting their ClusterId.
+// Articles are in the same cluster if they are within ClusterWindow of each other and
+// their normalized titles have a jaccard similarity of at least ClusterThreshold,
+// or they share a link target.
+// Articles from the same feed are never joined directly,
+// a feed's own follow ups aren't other sources for a story.
+// Only clusters with more than one member are returned.
+func cluster(rs []ranked) []*readss.Cluster {
+	titles := make([]map[string]bool, len(rs))
+	links := make([]map[string]bool, len(rs))
+	for i, r := range rs {
+		titles[i] = titleWords(r.e.Title)
+		links[i] = linkTargets(r.e)
+	}
+
+	parent := make([]int, len(rs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range rs {
+		for j := i + 1; j < len(rs); j++ {
+			d := rs[i].e.Time.Sub(rs[j].e.Time)
+			if d < 0 {
+				d = -d
+			}
+			if d > ClusterWindow || rs[i].e.Feed == rs[j].e.Feed {
+				continue
+			}
+			if jaccard(titles[i], titles[j]) >= ClusterThreshold || intersects(links[i], links[j]) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range rs {
+		root := find(i)
+		if len(members[root]) == 0 {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	var cs []*readss.Cluster
+	for _, root := range roots {
+		ms := members[root]
+		if len(ms) < 2 {
+			continue
+		}
+		// the oldest article names the cluster, so the id is stable as it grows
+		oldest := ms[0]
+		for _, m := range ms {
+			if rs[m].e.Time.Before(rs[oldest].e.Time) {
+				oldest = m
+			}
+		}
+		c := &readss.Cluster{
+			Id: rs[oldest].e.key(),
+		}
+		seen := make(map[string]bool)
+		for _, m := range ms {
+			rs[m].at.ClusterId = c.Id
+			c.ArticleIds = append(c.ArticleIds, rs[m].at.Id)
+			if !seen[rs[m].sub.Name] {
+				seen[rs[m].sub.Name] = true
+				c.Sources = append(c.Sources, rs[m].sub.Name)
+			}
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// titleWords is the set of significant words in a title.
+func titleWords(s string) map[string]bool {
+	ws := make(map[string]bool)
+	for _, t := range tokenize(s) {
+		if len(t.word) > 1 && !stopwords[t.word] {
+			ws[t.word] = true
+		}
+	}
+	return ws
+}
+
+// linkTargets is the set of urls an entry is about:
+// its own canonical url and its primary link, if any.
+// The primary link is the only link in its content to another site,
+// as in link aggregators and link blogs;
+// articles that merely cite other pages have none.
+func linkTargets(e *Entry) map[string]bool {
+	ls := make(map[string]bool)
+	if e.URL != "" {
+		ls[e.URL] = true
+	}
+	var primary string
+	own, _ := url.Parse(e.URL)
+	for _, s := range []string{e.Summary, e.Content} {
+		z := xhtml.NewTokenizer(strings.NewReader(s))
+		for tt := z.Next(); tt != xhtml.ErrorToken; tt = z.Next() {
+			if tt != xhtml.StartTagToken {
+				continue
+			}
+			name, hasAttr := z.TagName()
+			if string(name) != "a" {
+				continue
+			}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				if string(k) != "href" {
+					continue
+				}
+				u, err := url.Parse(string(v))
+				// skip relative links, links to the same site and to site roots
+				if err != nil || u.Host == "" || own != nil && u.Host == own.Host || strings.Trim(u.Path, "/") == "" {
+					continue
+				}
+				switch l := canonicalURL(u.String()); primary {
+				case "":
+					primary = l
+				case l:
+				default:
+					return ls
+				}
+			}
+		}
+	}
+	if primary != "" {
+		ls[primary] = true
+	}
+	return ls
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var n int
+	for w := range a {
+		if b[w] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a)+len(b)-n)
+}
+
+func intersects(a, b map[string]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+	return false
+}
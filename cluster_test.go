@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+func TestCluster(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	type entry struct {
+		feed, title, url, content string
+		age                       time.Duration
+	}
+	tcs := []struct {
+		name    string
+		entries []entry
+		// want is the groups of entry indexes clustered together
+		want [][]int
+	}{
+		{
+			name: "similar titles",
+			entries: []entry{
+				{"a", "Go 1.14 is released", "https://a.example/1", "", 0},
+				{"b", "Go 1.14 released", "https://b.example/1", "", time.Hour},
+				{"c", "Rust 1.41 released", "https://c.example/1", "", time.Hour},
+			},
+			want: [][]int{{0, 1}},
+		},
+		{
+			name: "outside window",
+			entries: []entry{
+				{"a", "Go 1.14 is released", "https://a.example/1", "", 0},
+				{"b", "Go 1.14 released", "https://b.example/1", "", 72 * time.Hour},
+			},
+		},
+		{
+			name: "same feed",
+			entries: []entry{
+				{"a", "Go 1.14 is released", "https://a.example/1", "", 0},
+				{"a", "Go 1.14 released", "https://a.example/2", "", time.Hour},
+			},
+		},
+		{
+			name: "same feed joined through another",
+			entries: []entry{
+				{"a", "Go 1.14 is released", "https://a.example/1", "", 0},
+				{"a", "Go 1.14 released", "https://a.example/2", "", time.Hour},
+				{"b", "Go 1.14 released today", "https://b.example/1", "", time.Hour},
+			},
+			want: [][]int{{0, 1, 2}},
+		},
+		{
+			name: "same url",
+			entries: []entry{
+				{"a", "Show HN: a thing", "https://x.example/post", "", 0},
+				{"b", "A thing I made", "https://x.example/post", "", time.Hour},
+			},
+			want: [][]int{{0, 1}},
+		},
+		{
+			name: "primary link",
+			entries: []entry{
+				{"a", "Something", "https://news.example/item?id=1", `<a href="https://x.example/post">link</a>`, 0},
+				{"b", "Unrelated words", "https://x.example/post", "", time.Hour},
+			},
+			want: [][]int{{0, 1}},
+		},
+		{
+			name: "cited links",
+			entries: []entry{
+				{"a", "Something", "https://a.example/1", `<a href="https://x.example/post">one</a> <a href="https://y.example/post">two</a>`, 0},
+				{"b", "Unrelated words", "https://x.example/post", "", time.Hour},
+			},
+		},
+		{
+			name: "same site links",
+			entries: []entry{
+				{"a", "Something", "https://a.example/1", `<a href="https://a.example/2">prev</a> <a href="https://x.example/post">link</a> <a href="https://z.example/">home</a>`, 0},
+				{"b", "Unrelated words", "https://x.example/post", "", time.Hour},
+			},
+			want: [][]int{{0, 1}},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := make([]ranked, len(tc.entries))
+			idx := make(map[string]int)
+			for i, te := range tc.entries {
+				e := &Entry{
+					ID:      te.url + te.title,
+					Feed:    te.feed,
+					Title:   te.title,
+					URL:     te.url,
+					Content: te.content,
+					Time:    t0.Add(te.age),
+				}
+				rs[i] = ranked{e, &readss.Article{Id: e.key()}, Sub{Name: te.feed}}
+				idx[e.key()] = i
+			}
+			var got [][]int
+			for _, c := range cluster(rs) {
+				var g []int
+				for _, id := range c.ArticleIds {
+					g = append(g, idx[id])
+				}
+				sort.Ints(g)
+				got = append(got, g)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("cluster = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
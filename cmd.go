@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type command struct {
+	name string
+	help string
+	run  func(args []string) error
+}
+
+// commands are the subcommands of readss, serve is the default.
+var commands = []command{
+	{"serve", "serve articles over grpc-web", serve},
+	{"fetch", "fetch subscriptions once and print the articles", fetch},
+	{"validate", "check subscriptions and filters, and probe each feed", validate},
+	{"import", "convert an OPML file to subscriptions", importOPML},
+	{"export", "convert subscriptions to an OPML file", exportOPML},
+	{"tui", "browse articles from a running server in the terminal", tui},
+	{"digest", "render or send a digest for a recipient now", digest},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: readss [command] [flags]\n\ncommands:\n")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.name, c.help)
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "\nrun readss <command> -h for the flags of a command\n")
+}
+
+// configFlags adds flags for the settings shared by commands,
+// defaulting to their env values.
+func configFlags(fs *flag.FlagSet) {
+	fs.StringVar(&Config, "config", Config, "subscriptions csv file, or directory of <user>.csv files (CONFIG)")
+	fs.StringVar(&Filters, "filters", Filters, "filter rules csv file (FILTERS)")
+}
+
+// userSubs returns the subscriptions of user in Config.
+func userSubs(user string) ([]Sub, error) {
+	files, err := userFiles(Config)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := files[user]
+	if !ok {
+		return nil, fmt.Errorf("no subscriptions for user %q in %v", user, Config)
+	}
+	return parseSubs(fn), nil
+}
+
+// fetch runs a single round of fetching and prints the resulting articles.
+func fetch(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	configFlags(fs)
+	user := fs.String("user", "", "user to fetch for, if -config is a directory")
+	format := fs.String("format", "table", "output format: table or json")
+	fs.Parse(args)
+
+	switch *format {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	subs, err := userSubs(*user)
+	if err != nil {
+		return err
+	}
+	ats := getArticles(subs, parseRules(Filters))
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ats)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSOURCE\tTITLE\tURL")
+	for _, at := range ats {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", at.Reltime, at.Source, at.Title, at.Url)
+	}
+	return w.Flush()
+}
+
+// validate reports problems in the subscriptions and filters,
+// and fetches every feed to check it can be parsed.
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFlags(fs)
+	fs.StringVar(&Digests, "digests", Digests, "digest recipients csv file (DIGESTS)")
+	fs.StringVar(&Webhooks, "webhooks", Webhooks, "webhooks csv file (WEBHOOKS)")
+	probe := fs.Bool("probe", true, "fetch every feed")
+	timeout := fs.Duration("timeout", 30*time.Second, "time allowed for probing each feed")
+	fs.StringVar(&Accounts, "accounts", Accounts, "accounts csv file (ACCOUNTS)")
+	fs.Parse(args)
+
+	var problems int
+	report := func(fn string, err error) {
+		problems++
+		fmt.Printf("%v: %v\n", fn, err)
+	}
+
+	files, err := userFiles(Config)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		report(Config, fmt.Errorf("no subscription files"))
+	}
+	feeds := make(map[string]Sub)
+	for _, fn := range files {
+		subs, errs := readSubs(fn)
+		for _, err := range errs {
+			report(fn, err)
+		}
+		names := make(map[string]bool)
+		urls := make(map[string]bool)
+		for _, sub := range subs {
+			if sub.Name == "" {
+				report(fn, fmt.Errorf("%v: empty name", sub.URL))
+			} else if names[sub.Name] {
+				report(fn, fmt.Errorf("%v: duplicate name", sub.Name))
+			}
+			if urls[sub.URL] {
+				report(fn, fmt.Errorf("%v: duplicate url %v", sub.Name, sub.URL))
+			}
+			names[sub.Name], urls[sub.URL] = true, true
+
+			u, err := url.Parse(sub.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				report(fn, fmt.Errorf("%v: bad url %q", sub.Name, sub.URL))
+				continue
+			}
+			feeds[sub.feed()] = sub
+		}
+	}
+	_, errs := readRules(Filters)
+	for _, err := range errs {
+		report(Filters, err)
+	}
+	if Accounts != "" {
+		as, errs := readAccounts(Accounts)
+		for _, err := range errs {
+			report(Accounts, err)
+		}
+		logins := make(map[string]bool)
+		for _, a := range as {
+			if _, ok := files[a.User]; !ok {
+				report(Accounts, fmt.Errorf("%v: no subscriptions for user %q", a.Login, a.User))
+			}
+			if logins[a.Login] {
+				report(Accounts, fmt.Errorf("%v: duplicate login", a.Login))
+			}
+			logins[a.Login] = true
+		}
+	}
+	if Digests != "" {
+		_, errs := readRecipients(Digests)
+		for _, err := range errs {
+			report(Digests, err)
+		}
+	}
+	if Webhooks != "" {
+		_, errs := readWebhooks(Webhooks)
+		for _, err := range errs {
+			report(Webhooks, err)
+		}
+	}
+
+	if *probe {
+		type result struct {
+			name, url string
+			items     int
+			d         time.Duration
+			err       error
+		}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var rs []result
+		for u, sub := range feeds {
+			wg.Add(1)
+			go func(u string, sub Sub) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+				defer cancel()
+				t := time.Now()
+				feed, err := fetchFeed(ctx, sub)
+				r := result{name: sub.Name, url: u, d: time.Since(t), err: err}
+				if err == nil {
+					r.items = len(feed.Items)
+				}
+				mu.Lock()
+				rs = append(rs, r)
+				mu.Unlock()
+			}(u, sub)
+		}
+		wg.Wait()
+		sort.Slice(rs, func(i, j int) bool { return rs[i].name < rs[j].name })
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tSOURCE\tITEMS\tTIME\tURL")
+		for _, r := range rs {
+			st := "ok"
+			if r.err != nil {
+				problems++
+				st = "FAIL: " + r.err.Error()
+			} else if r.items == 0 {
+				st = "empty"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%v\t%s\n", st, r.name, r.items, r.d.Round(time.Millisecond), redactURL(r.url))
+		}
+		w.Flush()
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"seankhliao.com/readss/readss"
+)
+
+// Recipient receives digests of the articles of User on a schedule.
+type Recipient struct {
+	Email    string
+	User     string
+	Schedule schedule
+	// Query filters articles with the Search syntax
+	Query   Query
+	Max     int
+	Subject string
+}
+
+// schedule is either every interval, or daily or weekly at a time of day.
+type schedule struct {
+	every   time.Duration
+	weekly  bool
+	weekday time.Weekday
+	hour    int
+	min     int
+}
+
+// parseSchedule parses one of:
+//
+//	every 6h
+//	daily 08:00
+//	weekly mon 08:00
+func parseSchedule(s string) (schedule, error) {
+	var sc schedule
+	fs := strings.Fields(strings.ToLower(s))
+	if len(fs) < 2 {
+		return sc, fmt.Errorf("bad schedule %q", s)
+	}
+	switch {
+	case fs[0] == "every" && len(fs) == 2:
+		d, err := time.ParseDuration(fs[1])
+		if err != nil || d < time.Minute {
+			return sc, fmt.Errorf("bad interval %q", fs[1])
+		}
+		sc.every = d
+		return sc, nil
+	case fs[0] == "daily" && len(fs) == 2:
+	case fs[0] == "weekly" && len(fs) == 3:
+		sc.weekly = true
+		sc.weekday = -1
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if len(fs[1]) >= 3 && strings.HasPrefix(strings.ToLower(d.String()), fs[1]) {
+				sc.weekday = d
+			}
+		}
+		if sc.weekday < 0 {
+			return sc, fmt.Errorf("bad weekday %q", fs[1])
+		}
+		fs = fs[1:]
+	default:
+		return sc, fmt.Errorf("bad schedule %q", s)
+	}
+	t, err := time.Parse("15:04", fs[1])
+	if err != nil {
+		return sc, fmt.Errorf("bad time of day %q", fs[1])
+	}
+	sc.hour, sc.min = t.Hour(), t.Minute()
+	return sc, nil
+}
+
+// last is the most recent scheduled time at or before now.
+func (sc schedule) last(now time.Time) time.Time {
+	if sc.every > 0 {
+		return now.Truncate(sc.every)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), sc.hour, sc.min, 0, 0, now.Location())
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	for sc.weekly && t.Weekday() != sc.weekday {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+func (sc schedule) period() time.Duration {
+	switch {
+	case sc.every > 0:
+		return sc.every
+	case sc.weekly:
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// parseRecipients reads a csv file of digest recipients,
+// each record is: email, user, schedule, followed by optional key=value options:
+//
+//	query=search query articles must match
+//	max=maximum number of articles in a digest, default 50
+//	subject=subject line
+func parseRecipients(fn string) []Recipient {
+	rs, errs := readRecipients(fn)
+	for _, err := range errs {
+		logger.Warn("parse digests", "file", fn, "error", err)
+	}
+	return rs
+}
+
+// readRecipients is parseRecipients, returning the problems it skipped.
+func readRecipients(fn string) ([]Recipient, []error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rr, err := cr.ReadAll()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	rs := make([]Recipient, 0, len(rr))
+records:
+	for _, r := range rr {
+		if len(r) < 3 {
+			errs = append(errs, fmt.Errorf("short record %v", r))
+			continue
+		}
+		rcpt := Recipient{
+			Email: strings.TrimSpace(r[0]),
+			User:  r[1],
+			Max:   50,
+		}
+		rcpt.Schedule, err = parseSchedule(r[2])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", rcpt.Email, err))
+			continue
+		}
+		for _, opt := range r[3:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				errs = append(errs, fmt.Errorf("%v: bad option %q", rcpt.Email, opt))
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "query":
+				rcpt.Query, err = ParseQuery(kv[1])
+				if err != nil {
+					// an unparsable filter would send everything
+					errs = append(errs, fmt.Errorf("%v: bad query: %v", rcpt.Email, err))
+					continue records
+				}
+			case "max":
+				rcpt.Max, err = strconv.Atoi(kv[1])
+				if err != nil || rcpt.Max < 1 {
+					errs = append(errs, fmt.Errorf("%v: bad max %q", rcpt.Email, kv[1]))
+					rcpt.Max = 50
+				}
+			case "subject":
+				rcpt.Subject = kv[1]
+			default:
+				errs = append(errs, fmt.Errorf("%v: unknown option %q", rcpt.Email, kv[0]))
+			}
+		}
+		rs = append(rs, rcpt)
+	}
+	return rs, errs
+}
+
+// key identifies the recipient in the digest state,
+// an address can receive digests of more than one user.
+func (r Recipient) key() string {
+	return r.User + "\x00" + r.Email
+}
+
+// digestState is the watermark of a recipient,
+// articles added up to Until have been sent.
+type digestState struct {
+	Run   time.Time
+	Until time.Time
+}
+
+// digester sends digests to the recipients in Digests when they are due.
+type digester struct {
+	svr *Server
+	fn  string
+
+	mu sync.Mutex
+	// state is keyed by Recipient.key
+	state map[string]digestState
+	// failed holds when sending last failed, to back off retries
+	failed map[string]time.Time
+}
+
+func newDigester(svr *Server, fn string) *digester {
+	d := &digester{
+		svr:    svr,
+		fn:     fn,
+		state:  make(map[string]digestState),
+		failed: make(map[string]time.Time),
+	}
+	if fn == "" {
+		return d
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("read digest state", "file", fn, "error", err)
+		}
+		return d
+	}
+	if err := json.Unmarshal(b, &d.state); err != nil {
+		logger.Error("decode digest state", "file", fn, "error", err)
+	}
+	return d
+}
+
+// run checks for due digests every minute until ctx is done.
+func (d *digester) run(ctx context.Context) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		d.check(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *digester) check(now time.Time) {
+	if !d.svr.isReady() {
+		return
+	}
+	d.svr.mu.RLock()
+	users, rules := d.svr.users, d.svr.rules
+	d.svr.mu.RUnlock()
+
+	for _, r := range parseRecipients(Digests) {
+		due := r.Schedule.last(now)
+		d.mu.Lock()
+		st, ok := d.state[r.key()]
+		failed := d.failed[r.key()]
+		d.mu.Unlock()
+		if !ok {
+			// new recipients get their first digest at the next scheduled time
+			d.mu.Lock()
+			d.state[r.key()] = digestState{Run: due, Until: now}
+			d.mu.Unlock()
+			if err := d.save(); err != nil {
+				logger.Error("save digest state", "file", d.fn, "error", err)
+			}
+			continue
+		}
+		if !due.After(st.Run) || now.Sub(failed) < 10*time.Minute {
+			continue
+		}
+		subs, ok := users[r.User]
+		if !ok {
+			logger.Warn("digest for unknown user", "recipient", r.Email, "user", r.User)
+			continue
+		}
+
+		since := st.Until
+		items := digestItems(r, subs, rules, d.svr.store, since, now)
+		if len(items) > 0 {
+			msg, err := renderDigest(r, items, since, now)
+			if err == nil {
+				err = sendMail(SMTPFrom, r.Email, msg)
+			}
+			if err != nil {
+				logger.Error("send digest", "recipient", r.Email, "error", err)
+				d.mu.Lock()
+				d.failed[r.key()] = now
+				d.mu.Unlock()
+				continue
+			}
+			logger.Info("sent digest", "recipient", r.Email, "articles", len(items))
+		}
+
+		d.mu.Lock()
+		d.state[r.key()] = digestState{Run: due, Until: now}
+		delete(d.failed, r.key())
+		d.mu.Unlock()
+		if err := d.save(); err != nil {
+			logger.Error("save digest state", "file", d.fn, "error", err)
+		}
+	}
+}
+
+func (d *digester) save() error {
+	if d.fn == "" {
+		return nil
+	}
+	d.mu.Lock()
+	b, err := json.Marshal(d.state)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := d.fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.fn)
+}
+
+// matchEntries presents the entries of es in the feeds of subs as articles,
+// keeping those matching q and not dropped or hidden by rules, newest first.
+func matchEntries(es []*Entry, q Query, subs []Sub, rules Rules) ([]*Entry, []*readss.Article) {
+	feeds := make(map[string]Sub, len(subs))
+	for _, sub := range subs {
+		if _, ok := feeds[sub.feed()]; !ok {
+			feeds[sub.feed()] = sub
+		}
+	}
+
+	var mes []*Entry
+	var ats []*readss.Article
+	for _, e := range NewIndex(es).Match(q) {
+		sub, ok := feeds[e.Feed]
+		if !ok {
+			continue
+		}
+		v := rules.Apply(e, sub)
+		if v.Drop || v.Hide {
+			continue
+		}
+		at := e.Article(sub)
+		at.Tags = append(at.Tags, v.Tags...)
+		if q.Filter(e, at) {
+			mes = append(mes, e)
+			ats = append(ats, at)
+		}
+	}
+	sort.Sort(entryArticles{mes, ats})
+	return mes, ats
+}
+
+type digestItem struct {
+	Title   string
+	URL     string
+	Source  string
+	Time    string
+	Tags    []string
+	Summary string
+}
+
+// digestItems are the articles of subs added between since and until
+// that pass the rules and the recipient's query, newest first.
+func digestItems(r Recipient, subs []Sub, rules Rules, store *Store, since, until time.Time) []digestItem {
+	es, ats := matchEntries(store.AddedBetween(since, until), r.Query, subs, rules)
+	if len(es) > r.Max {
+		es, ats = es[:r.Max], ats[:r.Max]
+	}
+
+	items := make([]digestItem, len(es))
+	for i, e := range es {
+		s := htmlText(e.Summary)
+		if s == "" {
+			s = htmlText(e.Content)
+		}
+		if utf8.RuneCountInString(s) > 280 {
+			s = string([]rune(s)[:280]) + "…"
+		}
+		items[i] = digestItem{
+			Title:   ats[i].Title,
+			URL:     ats[i].Url,
+			Source:  ats[i].Source,
+			Time:    ats[i].Time,
+			Tags:    ats[i].Tags,
+			Summary: s,
+		}
+	}
+	return items
+}
+
+const defaultDigestText = `{{len .Articles}} new articles since {{.Since.Format "Mon Jan 2 15:04"}}
+{{range .Articles}}
+{{.Title}}
+{{.Source}}, {{.Time}}
+{{.URL}}
+{{with .Summary}}{{.}}
+{{end}}{{end}}`
+
+const defaultDigestHTML = `<!doctype html>
+<html><body style="font-family:sans-serif;max-width:40em">
+<p>{{len .Articles}} new articles since {{.Since.Format "Mon Jan 2 15:04"}}</p>
+{{range .Articles}}<div style="margin:1.5em 0">
+<a href="{{.URL}}" style="font-size:1.1em">{{.Title}}</a><br>
+<small>{{.Source}}, {{.Time}}{{range .Tags}} #{{.}}{{end}}</small>
+{{with .Summary}}<p>{{.}}</p>{{end}}
+</div>
+{{end}}</body></html>
+`
+
+// digestTemplates are loaded from digest.txt and digest.html in DigestTemplates,
+// falling back to the defaults.
+func digestTemplates() (*texttemplate.Template, *htmltemplate.Template, error) {
+	txt, html := defaultDigestText, defaultDigestHTML
+	if DigestTemplates != "" {
+		if b, err := ioutil.ReadFile(filepath.Join(DigestTemplates, "digest.txt")); err == nil {
+			txt = string(b)
+		} else if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		if b, err := ioutil.ReadFile(filepath.Join(DigestTemplates, "digest.html")); err == nil {
+			html = string(b)
+		} else if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	tt, err := texttemplate.New("digest.txt").Parse(txt)
+	if err != nil {
+		return nil, nil, err
+	}
+	ht, err := htmltemplate.New("digest.html").Parse(html)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tt, ht, nil
+}
+
+// renderDigest builds a multipart/alternative email with text and html parts.
+func renderDigest(r Recipient, items []digestItem, since, until time.Time) ([]byte, error) {
+	tt, ht, err := digestTemplates()
+	if err != nil {
+		return nil, err
+	}
+	data := struct {
+		Recipient string
+		User      string
+		Since     time.Time
+		Until     time.Time
+		Articles  []digestItem
+	}{r.Email, r.User, since, until, items}
+
+	subject := r.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("readss digest: %d new articles", len(items))
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "From: %s\r\n", SMTPFrom)
+	fmt.Fprintf(&b, "To: %s\r\n", r.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", until.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%d.%x@readss>\r\n", until.UnixNano(), id)
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, p := range []struct {
+		typ  string
+		exec func(io.Writer) error
+	}{
+		{"text/plain", func(w io.Writer) error { return tt.Execute(w, data) }},
+		{"text/html", func(w io.Writer) error { return ht.Execute(w, data) }},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if err := p.exec(qw); err != nil {
+			return nil, fmt.Errorf("render %v: %v", p.typ, err)
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sendMail sends msg through SMTPAddr, authenticating if SMTPUser is set.
+func sendMail(from, to string, msg []byte) error {
+	var auth smtp.Auth
+	if SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(SMTPAddr)
+		auth = smtp.PlainAuth("", SMTPUser, SMTPPassword, host)
+	}
+	return smtp.SendMail(SMTPAddr, auth, from, []string{to}, msg)
+}
+
+// digest renders, and optionally sends, a digest immediately
+// from the articles in the store, without updating the watermark.
+func digest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	configFlags(fs)
+	fs.StringVar(&StoreFile, "store", StoreFile, "file articles are persisted in (STORE)")
+	fs.StringVar(&Digests, "digests", Digests, "digest recipients csv file (DIGESTS)")
+	since := fs.Duration("since", 0, "include articles added in this long, default the recipient's schedule period")
+	send := fs.Bool("send", false, "send over SMTP instead of printing the message")
+	user := fs.String("user", "", "user of the digest, for addresses receiving more than one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: readss digest [flags] recipient\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var rcpt *Recipient
+	for _, r := range parseRecipients(Digests) {
+		if r.Email == fs.Arg(0) && (*user == "" || r.User == *user) {
+			r := r
+			rcpt = &r
+		}
+	}
+	if rcpt == nil {
+		return fmt.Errorf("no recipient %q in %v", fs.Arg(0), Digests)
+	}
+	subs, err := userSubs(rcpt.User)
+	if err != nil {
+		return err
+	}
+	if *since == 0 {
+		*since = rcpt.Schedule.period()
+	}
+
+	now := time.Now()
+	items := digestItems(*rcpt, subs, parseRules(Filters), NewStore(StoreFile), now.Add(-*since), now)
+	msg, err := renderDigest(*rcpt, items, now.Add(-*since), now)
+	if err != nil {
+		return err
+	}
+	if *send {
+		return sendMail(SMTPFrom, rcpt.Email, msg)
+	}
+	_, err = os.Stdout.Write(msg)
+	return err
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleLast(t *testing.T) {
+	now := time.Date(2020, 1, 15, 10, 30, 0, 0, time.UTC) // a wednesday
+	tcs := []struct {
+		sched string
+		want  time.Time
+	}{
+		{"every 6h", time.Date(2020, 1, 15, 6, 0, 0, 0, time.UTC)},
+		{"every 1m", now},
+		{"daily 08:00", time.Date(2020, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"daily 10:30", now},
+		{"daily 12:00", time.Date(2020, 1, 14, 12, 0, 0, 0, time.UTC)},
+		{"weekly wed 08:00", time.Date(2020, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"weekly wed 12:00", time.Date(2020, 1, 8, 12, 0, 0, 0, time.UTC)},
+		{"weekly monday 08:00", time.Date(2020, 1, 13, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.sched, func(t *testing.T) {
+			sc, err := parseSchedule(tc.sched)
+			if err != nil {
+				t.Fatalf("parseSchedule: %v", err)
+			}
+			if got := sc.last(now); !got.Equal(tc.want) {
+				t.Errorf("last = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "every", "every 30s", "daily", "daily 25:00", "weekly xx 08:00", "hourly 08:00"} {
+		if _, err := parseSchedule(s); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded", s)
+		}
+	}
+}
+
+// smtpSink is a minimal smtp server that keeps the messages it receives.
+type smtpSink struct {
+	l  net.Listener
+	mu sync.Mutex
+	// msgs are the DATA of each message
+	msgs []string
+	// rcpts are the RCPT TO addresses of each message
+	rcpts []string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *smtpSink) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	reply := func(line string) { c.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	var rcpt string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line + " x")[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			reply("250 ok")
+		case "RCPT":
+			rcpt = strings.Trim(strings.TrimSpace(line[len("RCPT TO:"):]), "<>")
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, data.String())
+			s.rcpts = append(s.rcpts, rcpt)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpSink) received() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.msgs...), append([]string(nil), s.rcpts...)
+}
+
+// digestParts parses a digest message into its headers and decoded parts by content type.
+func digestParts(t *testing.T, msg string) (*mail.Message, map[string]string) {
+	m, err := mail.ReadMessage(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	mt, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", m.Header.Get("Content-Type"), err)
+	}
+	parts := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		// the reader decodes quoted-printable parts and drops their encoding header
+		if _, ok := p.Header["Content-Transfer-Encoding"]; ok {
+			t.Errorf("part %v is not quoted-printable", p.Header.Get("Content-Type"))
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		parts[p.Header.Get("Content-Type")] = strings.Replace(string(b), "\r\n", "\n", -1)
+	}
+	return m, parts
+}
+
+func TestRenderDigest(t *testing.T) {
+	defer func(from string) { SMTPFrom = from }(SMTPFrom)
+	SMTPFrom = "readss@example.com"
+
+	since := time.Date(2020, 1, 14, 8, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+	items := []digestItem{
+		{
+			Title:   "Go <generics> & you",
+			URL:     "https://example.com/a?x=1&y=2",
+			Source:  "Some Blog",
+			Time:    "2020-01-15 07:00",
+			Tags:    []string{"go"},
+			Summary: "a summary longer than the quoted printable line limit of seventy six characters per line",
+		},
+		{Title: "Second", URL: "https://example.com/b", Source: "Other", Time: "2020-01-14 09:00"},
+	}
+
+	tcs := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"default subject", "", "readss digest: 2 new articles"},
+		{"custom subject", "Morning news ☕", "Morning news ☕"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := Recipient{Email: "me@example.com", User: "alice", Subject: tc.subject}
+			b, err := renderDigest(r, items, since, until)
+			if err != nil {
+				t.Fatalf("renderDigest: %v", err)
+			}
+			m, parts := digestParts(t, string(b))
+
+			subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+			if err != nil || subject != tc.want {
+				t.Errorf("Subject = %q, %v, want %q", subject, err, tc.want)
+			}
+			for h, want := range map[string]string{
+				"From": "readss@example.com",
+				"To":   "me@example.com",
+				"Date": until.Format(time.RFC1123Z),
+			} {
+				if got := m.Header.Get(h); got != want {
+					t.Errorf("%v = %q, want %q", h, got, want)
+				}
+			}
+
+			txt := parts["text/plain; charset=utf-8"]
+			for _, want := range []string{
+				"2 new articles since Tue Jan 14 08:00",
+				"Go <generics> & you\nSome Blog, 2020-01-15 07:00\nhttps://example.com/a?x=1&y=2\n" + items[0].Summary,
+				"Second\nOther, 2020-01-14 09:00\nhttps://example.com/b\n",
+			} {
+				if !strings.Contains(txt, want) {
+					t.Errorf("text part missing %q in:\n%s", want, txt)
+				}
+			}
+			html := parts["text/html; charset=utf-8"]
+			for _, want := range []string{
+				`<a href="https://example.com/a?x=1&amp;y=2" style="font-size:1.1em">Go &lt;generics&gt; &amp; you</a>`,
+				"<small>Some Blog, 2020-01-15 07:00 #go</small>",
+				"<p>" + items[0].Summary + "</p>",
+			} {
+				if !strings.Contains(html, want) {
+					t.Errorf("html part missing %q in:\n%s", want, html)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderDigestTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "digest.txt"), []byte("{{.User}}:{{range .Articles}} {{.Title}}{{end}}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(dt string) { DigestTemplates = dt }(DigestTemplates)
+	DigestTemplates = dir
+
+	b, err := renderDigest(Recipient{User: "alice"}, []digestItem{{Title: "one"}, {Title: "two"}}, time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("renderDigest: %v", err)
+	}
+	_, parts := digestParts(t, string(b))
+	if got := parts["text/plain; charset=utf-8"]; got != "alice: one two" {
+		t.Errorf("text part = %q", got)
+	}
+	if got := parts["text/html; charset=utf-8"]; !strings.Contains(got, "2 new articles") {
+		t.Errorf("html part did not fall back to the default: %q", got)
+	}
+}
+
+func TestDigesterCheck(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.l.Close()
+
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	digests := filepath.Join(dir, "digests.csv")
+	err = ioutil.WriteFile(digests, []byte("me@example.com,alice,every 1h\nyou@example.com,bob,every 1h\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(addr, from, digests, user string) {
+		SMTPAddr, SMTPFrom, Digests, SMTPUser = addr, from, digests, user
+	}(SMTPAddr, SMTPFrom, Digests, SMTPUser)
+	SMTPAddr, SMTPFrom, Digests, SMTPUser = sink.l.Addr().String(), "readss@example.com", digests, ""
+
+	const feed = "https://example.com/feed"
+	svr := &Server{
+		health: newHealth(),
+		store:  NewStore(""),
+		users: map[string][]Sub{
+			"alice": {{Name: "Some Blog", URL: feed}},
+			"bob":   {{Name: "Other", URL: "https://other.example/feed"}},
+		},
+	}
+	d := newDigester(svr, filepath.Join(dir, "digest.json"))
+
+	// not ready, nothing happens
+	d.check(time.Now())
+	if len(d.state) != 0 {
+		t.Fatalf("state before ready = %v", d.state)
+	}
+	svr.setReady()
+
+	svr.store.Add([]*Entry{{ID: "old", Feed: feed, Title: "old news", Time: time.Now()}})
+
+	// the first run only sets a baseline
+	t0 := time.Now()
+	d.check(t0)
+	if msgs, _ := sink.received(); len(msgs) != 0 {
+		t.Fatalf("first check sent %d digests, want 0", len(msgs))
+	}
+	st, ok := d.state[Recipient{Email: "me@example.com", User: "alice"}.key()]
+	if !ok || !st.Until.Equal(t0) || !st.Run.Equal(t0.Truncate(time.Hour)) {
+		t.Fatalf("baseline state = %+v, %v", st, ok)
+	}
+
+	svr.store.Add([]*Entry{{ID: "new", Feed: feed, Title: "fresh news", Time: time.Now()}})
+
+	// not due yet within the same period
+	d.check(time.Now())
+	if msgs, _ := sink.received(); len(msgs) != 0 {
+		t.Fatalf("check before due sent %d digests, want 0", len(msgs))
+	}
+
+	// due in the next period: only articles added since the baseline
+	t1 := t0.Add(time.Hour)
+	d.check(t1)
+	msgs, rcpts := sink.received()
+	if len(msgs) != 1 || rcpts[0] != "me@example.com" {
+		t.Fatalf("due check sent %d digests to %v, want 1 to me@example.com", len(msgs), rcpts)
+	}
+	_, parts := digestParts(t, msgs[0])
+	txt := parts["text/plain; charset=utf-8"]
+	if !strings.Contains(txt, "fresh news") || strings.Contains(txt, "old news") {
+		t.Errorf("digest text = %q, want only fresh news", txt)
+	}
+
+	// once per period
+	d.check(t1.Add(time.Minute))
+	if msgs, _ := sink.received(); len(msgs) != 1 {
+		t.Errorf("second check in the period sent %d digests, want 1 in total", len(msgs))
+	}
+
+	// the next period has nothing new, nothing is sent but the watermark moves
+	t2 := t1.Add(time.Hour)
+	d.check(t2)
+	if msgs, _ := sink.received(); len(msgs) != 1 {
+		t.Errorf("empty period sent %d digests, want 1 in total", len(msgs))
+	}
+	st = d.state[Recipient{Email: "me@example.com", User: "alice"}.key()]
+	if !st.Until.Equal(t2) || !st.Run.Equal(t2.Truncate(time.Hour)) {
+		t.Errorf("state after empty period = %+v", st)
+	}
+
+	// the state survives restarts
+	d = newDigester(svr, filepath.Join(dir, "digest.json"))
+	if got := d.state[Recipient{Email: "me@example.com", User: "alice"}.key()]; !got.Until.Equal(t2) {
+		t.Errorf("reloaded state = %+v, want until %v", got, t2)
+	}
+}
+
+func TestDigesterCheckSendFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	digests := filepath.Join(dir, "digests.csv")
+	if err := ioutil.WriteFile(digests, []byte("me@example.com,,every 1h\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(addr, digests string) { SMTPAddr, Digests = addr, digests }(SMTPAddr, Digests)
+	SMTPAddr, Digests = addr, digests
+
+	const feed = "https://example.com/feed"
+	svr := &Server{
+		health: newHealth(),
+		store:  NewStore(""),
+		users:  map[string][]Sub{"": {{Name: "Some Blog", URL: feed}}},
+	}
+	svr.setReady()
+	d := newDigester(svr, "")
+	key := Recipient{Email: "me@example.com"}.key()
+
+	t0 := time.Now()
+	d.check(t0)
+	svr.store.Add([]*Entry{{ID: "new", Feed: feed, Title: "fresh news", Time: time.Now()}})
+	t1 := t0.Add(time.Hour)
+	d.check(t1)
+	if st := d.state[key]; !st.Until.Equal(t0) {
+		t.Errorf("state after failed send = %+v, want unchanged", st)
+	}
+	if !d.failed[key].Equal(t1) {
+		t.Errorf("failed = %v, want %v", d.failed[key], t1)
+	}
+}
+
+func TestSMTPSinkDotStuffing(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.l.Close()
+	defer func(addr string) { SMTPAddr = addr }(SMTPAddr)
+	SMTPAddr = sink.l.Addr().String()
+
+	msg := []byte("Subject: x\r\n\r\n.leading dot\r\n")
+	if err := sendMail("a@example.com", "b@example.com", msg); err != nil {
+		t.Fatalf("sendMail: %v", err)
+	}
+	msgs, _ := sink.received()
+	if len(msgs) != 1 || !bytes.Equal([]byte(msgs[0]), msg) {
+		t.Errorf("received %q, want %q", msgs, msg)
+	}
+}
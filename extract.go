@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	extractClient = &http.Client{Timeout: 30 * time.Second}
+	// extractWorkers limits the number of pages fetched at once
+	extractWorkers = 4
+	// extractMaxBytes limits the size of the pages read
+	extractMaxBytes int64 = 5 << 20
+	// extractRetry is how long to wait before fetching a page that failed again,
+	// doubling with each failure, until extractTries have failed
+	extractRetry = time.Hour
+	extractTries = 5
+
+	unlikelyRe = regexp.MustCompile(`(?i)ad-break|agegate|banner|combx|comment|community|cookie|disqus|extra|footer|header|menu|modal|nav|pager|popup|related|remark|replies|rss|share|shoutbox|sidebar|social|sponsor|subscribe|tags|tool|widget`)
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|story|text|blog`)
+	negativeRe = regexp.MustCompile(`(?i)hidden|byline|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+)
+
+// extractFailed holds the article ids of pages that couldn't be extracted,
+// so they are retried with backoff instead of on every refresh.
+var extractFailed = extractFailures{m: make(map[string]extractFailure)}
+
+type extractFailures struct {
+	mu sync.Mutex
+	m  map[string]extractFailure
+}
+
+type extractFailure struct {
+	tries int
+	next  time.Time
+}
+
+// due reports whether the page of the article with id k should be fetched.
+func (f *extractFailures) due(k string, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff, ok := f.m[k]
+	return !ok || ff.tries < extractTries && !now.Before(ff.next)
+}
+
+// fail records that the page of the article with id k wasn't extracted.
+func (f *extractFailures) fail(k string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ff := f.m[k]
+	ff.next = time.Now().Add(extractRetry << uint(ff.tries))
+	ff.tries++
+	f.m[k] = ff
+}
+
+func (f *extractFailures) ok(k string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.m, k)
+}
+
+// prune forgets the failures of articles no longer in store.
+func (f *extractFailures) prune(store *Store) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k := range f.m {
+		if store.Get(k) == nil {
+			delete(f.m, k)
+		}
+	}
+}
+
+// extractEntries fills in Extracted for new entries
+// of subscriptions with extract=1,
+// reusing previously extracted content from the store
+// and skipping pages that failed until they are due to be retried.
+func extractEntries(ctx context.Context, users map[string][]Sub, feeds map[string][]*Entry, store *Store) {
+	ctx, sp := startSpan(ctx, "extract")
+	defer sp.End()
+	extractFailed.prune(store)
+
+	extract := make(map[string]bool)
+	for _, subs := range users {
+		for _, sub := range subs {
+			if sub.Extract {
+				extract[sub.feed()] = true
+			}
+		}
+	}
+
+	var todo []*Entry
+	now := time.Now()
+	for u, es := range feeds {
+		if !extract[u] {
+			continue
+		}
+		for _, e := range es {
+			if old := store.Get(e.key()); old != nil && old.Extracted != "" {
+				e.Extracted = old.Extracted
+				continue
+			}
+			if e.URL != "" && extractFailed.due(e.key(), now) {
+				todo = append(todo, e)
+			}
+		}
+	}
+
+	sp.SetAttributes("pages", len(todo))
+	c := make(chan *Entry)
+	var wg sync.WaitGroup
+	for i := 0; i < extractWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range c {
+				s, err := extractURL(ctx, e.URL)
+				if err != nil {
+					logger.Warn("extract content", "url", e.URL, "error", err)
+					if ctx.Err() == nil {
+						extractFailed.fail(e.key())
+					}
+					continue
+				}
+				extractFailed.ok(e.key())
+				e.Extracted = s
+			}
+		}()
+	}
+loop:
+	for _, e := range todo {
+		select {
+		case c <- e:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(c)
+	wg.Wait()
+}
+
+// extractURL fetches a page and extracts its sanitized main content.
+func extractURL(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := extractClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %v", res.Status)
+	}
+	ct := res.Header.Get("Content-Type")
+	if ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("content type %v", ct)
+	}
+	r, err := charset.NewReader(io.LimitReader(res.Body, extractMaxBytes), ct)
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return "", err
+	}
+	s, err := extract(doc)
+	if err != nil {
+		return "", err
+	}
+	return sanitize(s, res.Request.URL.String()), nil
+}
+
+// extract finds the main content of a page in the style of readability:
+// paragraphs score their ancestors by the amount of text they hold,
+// the best scoring node is kept, along with siblings that look related.
+func extract(doc *goquery.Document) (string, error) {
+	doc.Find("script, style, noscript, iframe, form, nav, aside, header, footer, object, embed, svg, button, input, select, textarea").Remove()
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "html" {
+			return
+		}
+		class := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyRe.MatchString(class) && !positiveRe.MatchString(class) {
+			s.Remove()
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	score := func(n *html.Node, add float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(goquery.NewDocumentFromNode(n).Selection)
+			candidates = append(candidates, n)
+		}
+		scores[n] += add
+	}
+	doc.Find("p, pre, td").Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if len(text) < 25 {
+			return
+		}
+		add := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		p := s.Nodes[0].Parent
+		score(p, add)
+		if p != nil {
+			score(p.Parent, add/2)
+		}
+	})
+
+	var top *html.Node
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(goquery.NewDocumentFromNode(n).Selection)
+		if top == nil || scores[n] > scores[top] {
+			top = n
+		}
+	}
+	if top == nil {
+		return "", fmt.Errorf("no content found")
+	}
+
+	threshold := math.Max(10, scores[top]*0.2)
+	var b strings.Builder
+	for n := top.Parent.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		s := goquery.NewDocumentFromNode(n).Selection
+		keep := n == top
+		if sc, ok := scores[n]; ok && sc >= threshold {
+			keep = true
+		}
+		if n.Data == "p" {
+			text := strings.TrimSpace(s.Text())
+			ld := linkDensity(s)
+			if len(text) > 80 && ld < 0.25 || len(text) > 0 && ld == 0 && strings.Contains(text, ". ") {
+				keep = true
+			}
+		}
+		if !keep {
+			continue
+		}
+		if err := html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func initialScore(s *goquery.Selection) float64 {
+	var sc float64
+	switch goquery.NodeName(s) {
+	case "div", "article", "section", "main":
+		sc = 5
+	case "pre", "td", "blockquote":
+		sc = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		sc = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		sc = -5
+	}
+	for _, a := range []string{s.AttrOr("class", ""), s.AttrOr("id", "")} {
+		if a == "" {
+			continue
+		}
+		if negativeRe.MatchString(a) {
+			sc -= 25
+		}
+		if positiveRe.MatchString(a) {
+			sc += 25
+		}
+	}
+	return sc
+}
+
+// linkDensity is the fraction of text in s that is part of a link.
+func linkDensity(s *goquery.Selection) float64 {
+	total := len(strings.TrimSpace(s.Text()))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		links += len(strings.TrimSpace(a.Text()))
+	})
+	return float64(links) / float64(total)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testArticlePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>A post</title>
+<style>body { color: red }</style>
+<script>track()</script>
+</head><body>
+<header><nav><a href="/">Home</a> <a href="/about">About</a></nav></header>
+<div class="sidebar"><p>Subscribe to the newsletter for more posts like this one, every week.</p></div>
+<div id="main">
+<article class="post">
+<h1>A post</h1>
+<p>The first paragraph of the post talks about things, at length, with commas, and more words than a caption.</p>
+<p>The second paragraph goes on, with a <a href="/more">relative link</a>, and continues for a while longer.</p>
+<p><img src="/img/figure.png" alt="figure" onerror="alert(1)"></p>
+<p>The last paragraph wraps it all up, finally, so the reader can move on with their day.</p>
+</article>
+<div class="comments"><p>A comment that is long enough to be counted as a paragraph, but is not content.</p></div>
+</div>
+<footer><p>Copyright notice for the site, all rights reserved, no reproduction without permission.</p></footer>
+</body></html>`
+
+func TestExtractURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/post":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(testArticlePage))
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(testArticlePage))
+		case "/empty":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body><p>short</p></body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	got, err := extractURL(context.Background(), ts.URL+"/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"The first paragraph",
+		"The second paragraph",
+		"The last paragraph",
+		`href="` + ts.URL + `/more"`,
+		`src="` + ts.URL + `/img/figure.png"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("extracted content lacks %q:\n%s", want, got)
+		}
+	}
+	for _, bad := range []string{"Home", "newsletter", "A comment", "Copyright", "track()", "color: red", "onerror"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("extracted content has %q:\n%s", bad, got)
+		}
+	}
+
+	for _, path := range []string{"/feed", "/empty", "/missing"} {
+		if s, err := extractURL(context.Background(), ts.URL+path); err == nil {
+			t.Errorf("extractURL(%v) = %q, want error", path, s)
+		}
+	}
+}
+
+func TestExtractEntriesRetry(t *testing.T) {
+	defer func(r time.Duration, n int) { extractRetry, extractTries = r, n }(extractRetry, extractTries)
+	defer func(m map[string]extractFailure) { extractFailed.m = m }(extractFailed.m)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path != "/post" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testArticlePage))
+	}))
+	defer ts.Close()
+
+	feed := ts.URL + "/feed"
+	users := map[string][]Sub{"": {{Name: "Blog", URL: feed, Extract: true}}}
+	tcs := []struct {
+		name  string
+		retry time.Duration
+		tries int
+		runs  int
+		bad   int
+	}{
+		{name: "backoff", retry: time.Hour, tries: 5, runs: 3, bad: 1},
+		{name: "due", retry: 0, tries: 5, runs: 3, bad: 3},
+		{name: "give up", retry: 0, tries: 2, runs: 4, bad: 2},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			extractRetry, extractTries = tc.retry, tc.tries
+			extractFailed.m = make(map[string]extractFailure)
+			hits = make(map[string]int)
+			store := NewStore("")
+
+			var good *Entry
+			for i := 0; i < tc.runs; i++ {
+				es := []*Entry{
+					{ID: "good", Feed: feed, URL: ts.URL + "/post"},
+					{ID: "bad", Feed: feed, URL: ts.URL + "/bad"},
+				}
+				extractEntries(context.Background(), users, map[string][]*Entry{feed: es}, store)
+				store.Add(es)
+				good = es[0]
+				if es[1].Extracted != "" {
+					t.Errorf("run %d extracted the broken page: %q", i, es[1].Extracted)
+				}
+			}
+			if !strings.Contains(good.Extracted, "The first paragraph") {
+				t.Errorf("extracted = %q, want the post", good.Extracted)
+			}
+			if hits["/post"] != 1 {
+				t.Errorf("fetched the extracted page %d times, want once", hits["/post"])
+			}
+			if hits["/bad"] != tc.bad {
+				t.Errorf("fetched the broken page %d times, want %d", hits["/bad"], tc.bad)
+			}
+		})
+	}
+
+	// failures are forgotten with their articles
+	extractFailed.prune(NewStore(""))
+	if len(extractFailed.m) != 0 {
+		t.Errorf("failures after prune = %v, want none", extractFailed.m)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feverPage is the most items returned by a single request.
+const feverPage = 50
+
+// fever serves the Fever API for client apps, in json only.
+// https://web.archive.org/web/20230616124016/https://feedafever.com/api
+//
+// Item ids are entry Seqs, feed and group ids are hashes of feed keys and tags.
+func (s *Server) fever(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	res := map[string]interface{}{
+		"api_version": 3,
+		"auth":        0,
+	}
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}()
+
+	a, ok := s.feverAccount(r.Form.Get("api_key"))
+	if !ok {
+		logFrom(r.Context()).Debug("fever auth failed", "peer", r.RemoteAddr)
+		return
+	}
+	subs, rules, ok := s.accountSubs(a)
+	if !ok {
+		return
+	}
+	res["auth"] = 1
+	s.mu.RLock()
+	refreshed := s.refreshed
+	s.mu.RUnlock()
+	if !refreshed.IsZero() {
+		res["last_refreshed_on_time"] = refreshed.Unix()
+	}
+
+	items := userItems(s.store, subs, rules)
+	if r.Form.Get("mark") != "" {
+		s.feverMark(r, a.User, items)
+	}
+	read, saved := s.state.get(a.User)
+
+	_, hasGroups := r.Form["groups"]
+	_, hasFeeds := r.Form["feeds"]
+	if hasGroups || hasFeeds {
+		var groups []map[string]interface{}
+		var feeds []map[string]interface{}
+		tagFeeds := make(map[string][]string)
+		updated := make(map[string]int64)
+		for _, it := range items {
+			if t := it.e.Time.Unix(); t > updated[it.sub.feed()] {
+				updated[it.sub.feed()] = t
+			}
+		}
+		seen := make(map[string]bool)
+		for _, sub := range subs {
+			if seen[sub.feed()] {
+				continue
+			}
+			seen[sub.feed()] = true
+			id := strconv.FormatInt(feverID(sub.feed()), 10)
+			for _, t := range sub.Tags {
+				if len(tagFeeds[t]) == 0 {
+					groups = append(groups, map[string]interface{}{"id": feverID("tag:" + t), "title": t})
+				}
+				tagFeeds[t] = append(tagFeeds[t], id)
+			}
+			var favicon int64
+			if icons.url(sub.feed()) != "" {
+				favicon = feverID(sub.feed())
+			}
+			feeds = append(feeds, map[string]interface{}{
+				"id":                   feverID(sub.feed()),
+				"favicon_id":           favicon,
+				"title":                sub.Name,
+				"url":                  sub.URL,
+				"site_url":             sub.URL,
+				"is_spark":             0,
+				"last_updated_on_time": updated[sub.feed()],
+			})
+		}
+		feedsGroups := make([]map[string]interface{}, 0, len(groups))
+		for _, g := range groups {
+			t := g["title"].(string)
+			feedsGroups = append(feedsGroups, map[string]interface{}{"group_id": g["id"], "feed_ids": strings.Join(tagFeeds[t], ",")})
+		}
+		if groups == nil {
+			groups = []map[string]interface{}{}
+		}
+		if feeds == nil {
+			feeds = []map[string]interface{}{}
+		}
+		if hasGroups {
+			res["groups"] = groups
+		}
+		if hasFeeds {
+			res["feeds"] = feeds
+		}
+		res["feeds_groups"] = feedsGroups
+	}
+	if _, ok := r.Form["favicons"]; ok {
+		favicons := []interface{}{}
+		seen := make(map[string]bool)
+		for _, sub := range subs {
+			if seen[sub.feed()] {
+				continue
+			}
+			seen[sub.feed()] = true
+			if b := icons.data(sub.feed()); b != nil {
+				favicons = append(favicons, map[string]interface{}{
+					"id":   feverID(sub.feed()),
+					"data": "image/png;base64," + base64.StdEncoding.EncodeToString(b),
+				})
+			}
+		}
+		res["favicons"] = favicons
+	}
+	if _, ok := r.Form["links"]; ok {
+		res["links"] = []interface{}{}
+	}
+
+	if _, ok := r.Form["items"]; ok {
+		res["total_items"] = len(items)
+		res["items"] = feverItems(r, items, read, saved)
+	}
+	if _, ok := r.Form["unread_item_ids"]; ok || r.Form.Get("mark") != "" {
+		var ids []string
+		for _, it := range items {
+			if !read[it.e.key()] {
+				ids = append(ids, strconv.FormatInt(it.e.Seq, 10))
+			}
+		}
+		res["unread_item_ids"] = strings.Join(ids, ",")
+	}
+	if _, ok := r.Form["saved_item_ids"]; ok || r.Form.Get("mark") != "" {
+		var ids []string
+		for _, it := range items {
+			if saved[it.e.key()] {
+				ids = append(ids, strconv.FormatInt(it.e.Seq, 10))
+			}
+		}
+		res["saved_item_ids"] = strings.Join(ids, ",")
+	}
+}
+
+// feverAccount finds the account with an api key of md5(login:password).
+func (s *Server) feverAccount(key string) (Account, bool) {
+	if key == "" {
+		return Account{}, false
+	}
+	key = strings.ToLower(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, a := range s.accounts {
+		h := md5.Sum([]byte(a.Login + ":" + a.Password))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(h[:])), []byte(key)) == 1 {
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
+// feverID is a stable positive integer id for s.
+func feverID(s string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return int64(h.Sum32() & 0x7fffffff)
+}
+
+// feverItems selects a page of items with with_ids, since_id (ascending),
+// or max_id (descending), defaulting to the newest.
+func feverItems(r *http.Request, items []userItem, read, saved map[string]bool) []map[string]interface{} {
+	var page []userItem
+	switch {
+	case r.Form.Get("with_ids") != "":
+		want := make(map[int64]bool)
+		for _, id := range strings.Split(r.Form.Get("with_ids"), ",") {
+			n, _ := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+			want[n] = true
+		}
+		for _, it := range items {
+			if want[it.e.Seq] && len(page) < feverPage {
+				page = append(page, it)
+			}
+		}
+	case r.Form.Get("since_id") != "":
+		since, _ := strconv.ParseInt(r.Form.Get("since_id"), 10, 64)
+		i := sort.Search(len(items), func(i int) bool { return items[i].e.Seq > since })
+		page = items[i:]
+		if len(page) > feverPage {
+			page = page[:feverPage]
+		}
+	default:
+		max := int64(-1)
+		if r.Form.Get("max_id") != "" {
+			max, _ = strconv.ParseInt(r.Form.Get("max_id"), 10, 64)
+		}
+		for i := len(items) - 1; i >= 0 && len(page) < feverPage; i-- {
+			if max < 0 || items[i].e.Seq < max {
+				page = append(page, items[i])
+			}
+		}
+	}
+
+	out := make([]map[string]interface{}, 0, len(page))
+	for _, it := range page {
+		k := it.e.key()
+		out = append(out, map[string]interface{}{
+			"id":              it.e.Seq,
+			"feed_id":         feverID(it.sub.feed()),
+			"title":           it.e.Title,
+			"author":          it.e.Author,
+			"html":            it.e.body(),
+			"url":             it.e.URL,
+			"is_saved":        feverBool(saved[k]),
+			"is_read":         feverBool(read[k]),
+			"created_on_time": it.e.Time.Unix(),
+		})
+	}
+	return out
+}
+
+// feverIn reports whether sub is the feed id, or in the group id.
+func feverIn(mark string, id int64, sub Sub) bool {
+	if mark == "feed" {
+		return feverID(sub.feed()) == id
+	}
+	if id == 0 {
+		return true
+	}
+	for _, t := range sub.Tags {
+		if feverID("tag:"+t) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func feverBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// feverMark handles mark=item|feed|group with as=read|unread|saved|unsaved.
+// Feeds and groups are marked read up to their before timestamp,
+// group 0 is every feed.
+func (s *Server) feverMark(r *http.Request, user string, items []userItem) {
+	id, _ := strconv.ParseInt(r.Form.Get("id"), 10, 64)
+	mark, as := r.Form.Get("mark"), r.Form.Get("as")
+	var keys []string
+	switch mark {
+	case "item":
+		for _, it := range items {
+			if it.e.Seq == id {
+				keys = append(keys, it.e.key())
+			}
+		}
+	case "feed", "group":
+		before := time.Now()
+		if b, err := strconv.ParseInt(r.Form.Get("before"), 10, 64); err == nil {
+			before = time.Unix(b, 0)
+		}
+		for _, it := range items {
+			if !it.e.Added.After(before) && feverIn(mark, id, it.sub) {
+				keys = append(keys, it.e.key())
+			}
+		}
+		as = "read"
+	}
+	if len(keys) == 0 {
+		return
+	}
+	switch as {
+	case "read", "unread":
+		s.state.mark(user, false, as == "read", keys...)
+	case "saved", "unsaved":
+		s.state.mark(user, true, as == "saved", keys...)
+	default:
+		return
+	}
+	if err := s.state.save(s.store); err != nil {
+		logger.Error("save read state", "file", s.state.fn, "error", err)
+	}
+}
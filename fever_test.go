@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	feverFeed  = "https://example.com/feed"
+	feverOther = "https://example.com/other"
+)
+
+// testFeverServer serves alice the feeds feverFeed with n entries,
+// the same url fetched with credentials with one entry, and feverOther with one entry.
+func testFeverServer(n int) (*Server, Sub) {
+	private := Sub{Name: "Private", URL: feverFeed, Header: http.Header{"Authorization": {"Bearer x"}}}
+	s := &Server{
+		accounts: map[string]Account{"me@x": {Login: "me@x", Password: "pw", User: "alice"}},
+		users: map[string][]Sub{"alice": {
+			{Name: "Public", URL: feverFeed, Tags: []string{"blogs"}},
+			private,
+			{Name: "Other", URL: feverOther},
+		}},
+		store: NewStore(""),
+		state: loadReadState(""),
+	}
+	t0 := time.Now().Add(-time.Hour)
+	for i := 1; i <= n; i++ {
+		s.store.Add([]*Entry{{ID: fmt.Sprint(i), Feed: feverFeed, Title: fmt.Sprint("post ", i), Time: t0.Add(time.Duration(i) * time.Second)}})
+	}
+	s.store.Add([]*Entry{{ID: "private", Feed: private.feed(), Title: "private post", Time: t0}})
+	s.store.Add([]*Entry{{ID: "other", Feed: feverOther, Title: "other post", Time: t0}})
+	return s, private
+}
+
+func feverKey(login, password string) string {
+	h := md5.Sum([]byte(login + ":" + password))
+	return hex.EncodeToString(h[:])
+}
+
+type feverRes struct {
+	Auth          int    `json:"auth"`
+	TotalItems    int    `json:"total_items"`
+	UnreadItemIDs string `json:"unread_item_ids"`
+	SavedItemIDs  string `json:"saved_item_ids"`
+	Items         []struct {
+		ID     int64  `json:"id"`
+		FeedID int64  `json:"feed_id"`
+		Title  string `json:"title"`
+		IsRead int    `json:"is_read"`
+	} `json:"items"`
+	Feeds []struct {
+		ID    int64  `json:"id"`
+		Title string `json:"title"`
+	} `json:"feeds"`
+}
+
+func feverDo(t *testing.T, s *Server, form url.Values) feverRes {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/fever/?api&"+form.Encode(), nil)
+	w := httptest.NewRecorder()
+	s.fever(w, r)
+	var res feverRes
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return res
+}
+
+func TestFeverAuth(t *testing.T) {
+	s, _ := testFeverServer(1)
+	tcs := []struct {
+		name string
+		key  string
+		auth int
+	}{
+		{name: "no key"},
+		{name: "wrong password", key: feverKey("me@x", "nope")},
+		{name: "unknown login", key: feverKey("who", "pw")},
+		{name: "good key", key: feverKey("me@x", "pw"), auth: 1},
+		{name: "upper case key", key: strings.ToUpper(feverKey("me@x", "pw")), auth: 1},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			res := feverDo(t, s, url.Values{"api_key": {tc.key}, "items": {""}})
+			if res.Auth != tc.auth {
+				t.Errorf("auth = %d, want %d", res.Auth, tc.auth)
+			}
+			if tc.auth == 0 && res.Items != nil {
+				t.Errorf("items = %v without auth, want none", res.Items)
+			}
+		})
+	}
+}
+
+func TestFeverItems(t *testing.T) {
+	// seqs 1 to 55 in feverFeed, 56 private, 57 other
+	s, _ := testFeverServer(55)
+	seqs := func(from, to int64) []int64 {
+		var ids []int64
+		for i := from; ; {
+			ids = append(ids, i)
+			if i == to {
+				return ids
+			}
+			if from < to {
+				i++
+			} else {
+				i--
+			}
+		}
+	}
+	tcs := []struct {
+		name string
+		form url.Values
+		want []int64
+	}{
+		{name: "newest", want: seqs(57, 8)},
+		{name: "since_id start", form: url.Values{"since_id": {"0"}}, want: seqs(1, 50)},
+		{name: "since_id next page", form: url.Values{"since_id": {"50"}}, want: seqs(51, 57)},
+		{name: "since_id end", form: url.Values{"since_id": {"57"}}},
+		{name: "max_id", form: url.Values{"max_id": {"8"}}, want: seqs(7, 1)},
+		{name: "with_ids", form: url.Values{"with_ids": {"3, 56,99"}}, want: []int64{3, 56}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{"api_key": {feverKey("me@x", "pw")}, "items": {""}}
+			for k, v := range tc.form {
+				form[k] = v
+			}
+			res := feverDo(t, s, form)
+			if res.TotalItems != 57 {
+				t.Errorf("total_items = %d, want 57", res.TotalItems)
+			}
+			var got []int64
+			for _, it := range res.Items {
+				got = append(got, it.ID)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("item ids = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFeverFeeds(t *testing.T) {
+	s, private := testFeverServer(1)
+	res := feverDo(t, s, url.Values{"api_key": {feverKey("me@x", "pw")}, "feeds": {""}, "items": {""}})
+
+	ids := make(map[string]int64)
+	for _, f := range res.Feeds {
+		ids[f.Title] = f.ID
+	}
+	if len(ids) != 3 || ids["Public"] == ids["Private"] {
+		t.Fatalf("feeds = %v, want 3 distinct feeds", res.Feeds)
+	}
+	if ids["Private"] != feverID(private.feed()) {
+		t.Errorf("private feed id = %d, want the id of its feed key", ids["Private"])
+	}
+	want := map[string]int64{"post 1": ids["Public"], "private post": ids["Private"], "other post": ids["Other"]}
+	for _, it := range res.Items {
+		if it.FeedID != want[it.Title] {
+			t.Errorf("item %q feed_id = %d, want %d", it.Title, it.FeedID, want[it.Title])
+		}
+	}
+}
+
+func TestFeverMark(t *testing.T) {
+	// seqs 1 to 3 in feverFeed, 4 private, 5 other
+	tcs := []struct {
+		name   string
+		form   url.Values
+		unread string
+		saved  string
+	}{
+		{name: "item read", form: url.Values{"mark": {"item"}, "as": {"read"}, "id": {"2"}}, unread: "1,3,4,5"},
+		{name: "item saved", form: url.Values{"mark": {"item"}, "as": {"saved"}, "id": {"2"}}, unread: "1,2,3,4,5", saved: "2"},
+		{name: "unknown item", form: url.Values{"mark": {"item"}, "as": {"read"}, "id": {"9"}}, unread: "1,2,3,4,5"},
+		{name: "feed", form: url.Values{"mark": {"feed"}, "as": {"read"}, "id": {strconv.FormatInt(feverID(feverFeed), 10)}}, unread: "4,5"},
+		{name: "feed before added", form: url.Values{"mark": {"feed"}, "as": {"read"}, "id": {strconv.FormatInt(feverID(feverFeed), 10)}, "before": {"1"}}, unread: "1,2,3,4,5"},
+		{name: "group", form: url.Values{"mark": {"group"}, "as": {"read"}, "id": {strconv.FormatInt(feverID("tag:blogs"), 10)}}, unread: "4,5"},
+		{name: "group 0", form: url.Values{"mark": {"group"}, "as": {"read"}, "id": {"0"}}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := testFeverServer(3)
+			form := url.Values{"api_key": {feverKey("me@x", "pw")}}
+			for k, v := range tc.form {
+				form[k] = v
+			}
+			res := feverDo(t, s, form)
+			if res.UnreadItemIDs != tc.unread {
+				t.Errorf("unread_item_ids = %q, want %q", res.UnreadItemIDs, tc.unread)
+			}
+			if res.SavedItemIDs != tc.saved {
+				t.Errorf("saved_item_ids = %q, want %q", res.SavedItemIDs, tc.saved)
+			}
+
+			res = feverDo(t, s, url.Values{"api_key": {feverKey("me@x", "pw")}, "unread_item_ids": {""}})
+			if res.UnreadItemIDs != tc.unread {
+				t.Errorf("unread_item_ids after = %q, want %q", res.UnreadItemIDs, tc.unread)
+			}
+		})
+	}
+}
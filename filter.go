@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule matches entries by a field and drops, hides or tags them.
+type Rule struct {
+	// Scope is * for all subscriptions,
+	// otherwise a subscription name or feed url.
+	Scope string
+	// Field is one of title, category, author, url
+	Field   string
+	Keyword string
+	Regexp  *regexp.Regexp
+	// Action is one of drop, hide, tag
+	Action string
+	Tag    string
+}
+
+// Verdict is the combined result of all rules matching an entry.
+type Verdict struct {
+	// Drop removes the entry everywhere
+	Drop bool
+	// Hide removes the entry from List, it can still be found by Search
+	Hide bool
+	Tags []string
+}
+
+type Rules []Rule
+
+// Apply runs all rules against e as seen through sub.
+func (rs Rules) Apply(e *Entry, sub Sub) Verdict {
+	var v Verdict
+	for _, r := range rs {
+		if r.Scope != "*" && r.Scope != sub.Name && r.Scope != sub.URL {
+			continue
+		}
+		if !r.matches(e) {
+			continue
+		}
+		switch r.Action {
+		case "drop":
+			v.Drop = true
+		case "hide":
+			v.Hide = true
+		case "tag":
+			v.Tags = append(v.Tags, r.Tag)
+		}
+	}
+	return v
+}
+
+func (r Rule) matches(e *Entry) bool {
+	var vals []string
+	switch r.Field {
+	case "title":
+		vals = []string{e.Title}
+	case "category":
+		vals = e.Categories
+	case "author":
+		vals = []string{e.Author}
+	case "url":
+		vals = []string{e.URL}
+	}
+	for _, v := range vals {
+		if r.Regexp != nil && r.Regexp.MatchString(v) {
+			return true
+		}
+		if r.Keyword != "" && strings.Contains(strings.ToLower(v), r.Keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRules reads a csv file of filter rules,
+// each record is: scope, field, keyword|regex, pattern, drop|hide|tag[, tag]
+// Keywords match case insensitively anywhere in the field.
+func parseRules(fn string) Rules {
+	rs, errs := readRules(fn)
+	for _, err := range errs {
+		logger.Warn("parse filters", "file", fn, "error", err)
+	}
+	return rs
+}
+
+// readRules is parseRules, returning the problems it skipped.
+func readRules(fn string) (Rules, []error) {
+	if fn == "" {
+		return nil, nil
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rr, err := cr.ReadAll()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	rs := make(Rules, 0, len(rr))
+	for _, r := range rr {
+		rule, err := parseRule(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rs = append(rs, rule)
+	}
+	return rs, errs
+}
+
+func parseRule(r []string) (Rule, error) {
+	if len(r) < 5 {
+		return Rule{}, fmt.Errorf("short record %v", r)
+	}
+	rule := Rule{
+		Scope:  r[0],
+		Field:  r[1],
+		Action: r[4],
+	}
+	switch rule.Field {
+	case "title", "category", "author", "url":
+	default:
+		return rule, fmt.Errorf("unknown field %q", rule.Field)
+	}
+	switch r[2] {
+	case "keyword":
+		rule.Keyword = strings.ToLower(r[3])
+	case "regex":
+		re, err := regexp.Compile(r[3])
+		if err != nil {
+			return rule, fmt.Errorf("compile %q: %v", r[3], err)
+		}
+		rule.Regexp = re
+	default:
+		return rule, fmt.Errorf("unknown match %q", r[2])
+	}
+	switch rule.Action {
+	case "drop", "hide":
+	case "tag":
+		if len(r) < 6 || r[5] == "" {
+			return rule, fmt.Errorf("tag rule without tag %v", r)
+		}
+		rule.Tag = r[5]
+	default:
+		return rule, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	return rule, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tcs := []struct {
+		name string
+		in   []string
+		want Rule
+		err  string
+	}{
+		{
+			name: "keyword",
+			in:   []string{"*", "title", "keyword", "Sponsored", "drop"},
+			want: Rule{Scope: "*", Field: "title", Keyword: "sponsored", Action: "drop"},
+		},
+		{
+			name: "tag",
+			in:   []string{"Some Blog", "category", "keyword", "go", "tag", "golang"},
+			want: Rule{Scope: "Some Blog", Field: "category", Keyword: "go", Action: "tag", Tag: "golang"},
+		},
+		{
+			name: "regex",
+			in:   []string{"*", "url", "regex", `^https://example\.com/ads/`, "hide"},
+		},
+		{
+			name: "short",
+			in:   []string{"*", "title", "keyword", "x"},
+			err:  "short record [* title keyword x]",
+		},
+		{
+			name: "unknown field",
+			in:   []string{"*", "body", "keyword", "x", "drop"},
+			err:  `unknown field "body"`,
+		},
+		{
+			name: "unknown match",
+			in:   []string{"*", "title", "glob", "x*", "drop"},
+			err:  `unknown match "glob"`,
+		},
+		{
+			name: "bad regex",
+			in:   []string{"*", "title", "regex", "(", "drop"},
+			err:  "compile \"(\": error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name: "unknown action",
+			in:   []string{"*", "title", "keyword", "x", "star"},
+			err:  `unknown action "star"`,
+		},
+		{
+			name: "tag without tag",
+			in:   []string{"*", "title", "keyword", "x", "tag"},
+			err:  "tag rule without tag [* title keyword x tag]",
+		},
+		{
+			name: "tag with empty tag",
+			in:   []string{"*", "title", "keyword", "x", "tag", ""},
+			err:  "tag rule without tag [* title keyword x tag ]",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseRule(tc.in)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("parseRule error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRule: %v", err)
+			}
+			if got.Regexp != nil {
+				if got.Regexp.String() != tc.in[3] {
+					t.Errorf("parseRule regexp = %v, want %v", got.Regexp, tc.in[3])
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseRule = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "filters.csv")
+	err = ioutil.WriteFile(fn, []byte(`*,title,keyword,sponsored,drop
+*,title,keyword,x
+Some Blog,author,regex,^bot$,hide
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, errs := readRules(fn)
+	if len(rs) != 2 || rs[0].Action != "drop" || rs[1].Action != "hide" {
+		t.Errorf("readRules = %+v", rs)
+	}
+	if len(errs) != 1 || errs[0].Error() != "short record [* title keyword x]" {
+		t.Errorf("readRules errors = %v", errs)
+	}
+
+	if rs, errs := readRules(""); rs != nil || errs != nil {
+		t.Errorf("readRules no file = %v, %v", rs, errs)
+	}
+	if _, errs := readRules(filepath.Join(dir, "missing.csv")); len(errs) != 1 {
+		t.Errorf("readRules missing file errors = %v", errs)
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	rule := func(r ...string) Rule {
+		rule, err := parseRule(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rule
+	}
+	rs := Rules{
+		rule("*", "title", "keyword", "sponsored", "drop"),
+		rule("Some Blog", "author", "regex", "^bot$", "hide"),
+		rule("https://example.com/feed", "category", "keyword", "GO", "tag", "golang"),
+		rule("*", "url", "regex", `/ads/`, "tag", "ad"),
+		rule("*", "url", "keyword", "/ads/", "tag", "ad again"),
+	}
+	blog := Sub{Name: "Some Blog", URL: "https://example.com/feed"}
+	other := Sub{Name: "Other", URL: "https://other.example/feed"}
+	tcs := []struct {
+		name string
+		e    Entry
+		sub  Sub
+		want Verdict
+	}{
+		{"nothing", Entry{Title: "Hello"}, blog, Verdict{}},
+		{"keyword any case", Entry{Title: "A SPONSORED post"}, other, Verdict{Drop: true}},
+		{"scoped by name", Entry{Author: "bot"}, blog, Verdict{Hide: true}},
+		{"out of scope", Entry{Author: "bot"}, other, Verdict{}},
+		{"regex anchored", Entry{Author: "robot"}, blog, Verdict{}},
+		{"scoped by url", Entry{Categories: []string{"rust", "golang"}}, blog, Verdict{Tags: []string{"golang"}}},
+		{"scoped by url other", Entry{Categories: []string{"golang"}}, other, Verdict{}},
+		{"all rules", Entry{Title: "Sponsored", Author: "bot", URL: "https://example.com/ads/1"}, blog, Verdict{Drop: true, Hide: true, Tags: []string{"ad", "ad again"}}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := rs.Apply(&tc.e, tc.sub); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Apply = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google Reader stream ids and item id prefix.
+const (
+	greaderReadingList = "user/-/state/com.google/reading-list"
+	greaderRead        = "user/-/state/com.google/read"
+	greaderStarred     = "user/-/state/com.google/starred"
+	greaderLabel       = "user/-/label/"
+	greaderFeed        = "feed/"
+	greaderItem        = "tag:google.com,2005:reader/item/"
+)
+
+// greaderEdits are the calls that change state,
+// they must be posted with the token from /reader/api/0/token as T.
+var greaderEdits = map[string]bool{
+	"/reader/api/0/edit-tag":         true,
+	"/reader/api/0/mark-all-as-read": true,
+}
+
+// greader serves the Google Reader API for client apps under /greader/,
+// as implemented by FreshRSS and Miniflux.
+// Item ids are entry Seqs, labels are the tags of subscriptions and articles,
+// and read and starred are the read state shared with the fever api.
+func (s *Server) greader(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	p := strings.TrimPrefix(r.URL.Path, "/greader")
+	if p == "/accounts/ClientLogin" {
+		s.greaderLogin(w, r)
+		return
+	}
+
+	a, ok := s.greaderAuth(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	subs, rules, ok := s.accountSubs(a)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if greaderEdits[p] {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if t := r.Form.Get("T"); t == "" || !hmac.Equal([]byte(t), []byte(greaderToken(a, "T"))) {
+			w.Header().Set("X-Reader-Google-Bad-Token", "true")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	switch {
+	case p == "/reader/api/0/token":
+		fmt.Fprint(w, greaderToken(a, "T"))
+	case p == "/reader/api/0/user-info":
+		greaderJSON(w, map[string]interface{}{
+			"userId":        a.Login,
+			"userName":      a.Login,
+			"userProfileId": a.Login,
+			"userEmail":     a.Login,
+		})
+	case p == "/reader/api/0/subscription/list":
+		greaderJSON(w, map[string]interface{}{"subscriptions": greaderSubs(subs)})
+	case p == "/reader/api/0/tag/list":
+		tags := []map[string]string{{"id": greaderStarred}}
+		for _, t := range greaderTags(subs) {
+			tags = append(tags, map[string]string{"id": greaderLabel + t, "type": "folder"})
+		}
+		greaderJSON(w, map[string]interface{}{"tags": tags})
+	case p == "/reader/api/0/unread-count":
+		s.greaderUnread(w, a, subs, rules)
+	case p == "/reader/api/0/stream/items/ids":
+		items, cont := s.greaderSelect(r, a, r.Form.Get("s"), userItems(s.store, subs, rules))
+		refs := make([]map[string]interface{}, 0, len(items))
+		for _, it := range items {
+			refs = append(refs, map[string]interface{}{
+				"id":              strconv.FormatInt(it.e.Seq, 10),
+				"directStreamIds": []string{},
+				"timestampUsec":   strconv.FormatInt(it.e.Added.UnixNano()/1e3, 10),
+			})
+		}
+		res := map[string]interface{}{"itemRefs": refs}
+		if cont != "" {
+			res["continuation"] = cont
+		}
+		greaderJSON(w, res)
+	case p == "/reader/api/0/stream/items/contents":
+		want := make(map[int64]bool)
+		for _, id := range r.Form["i"] {
+			if n, ok := greaderItemID(id); ok {
+				want[n] = true
+			}
+		}
+		var items []userItem
+		for _, it := range userItems(s.store, subs, rules) {
+			if want[it.e.Seq] {
+				items = append(items, it)
+			}
+		}
+		s.greaderContents(w, a, greaderReadingList, items, "")
+	case strings.HasPrefix(p, "/reader/api/0/stream/contents/"):
+		stream := strings.TrimPrefix(r.URL.EscapedPath(), "/greader/reader/api/0/stream/contents/")
+		if st, err := url.PathUnescape(stream); err == nil {
+			stream = st
+		}
+		if stream == "" {
+			stream = r.Form.Get("s")
+		}
+		items, cont := s.greaderSelect(r, a, stream, userItems(s.store, subs, rules))
+		s.greaderContents(w, a, stream, items, cont)
+	case p == "/reader/api/0/edit-tag":
+		var keys []string
+		byID := make(map[int64]string)
+		for _, it := range userItems(s.store, subs, rules) {
+			byID[it.e.Seq] = it.e.key()
+		}
+		for _, id := range r.Form["i"] {
+			if n, ok := greaderItemID(id); ok && byID[n] != "" {
+				keys = append(keys, byID[n])
+			}
+		}
+		for _, t := range r.Form["a"] {
+			s.greaderTag(a.User, greaderStream(t), true, keys)
+		}
+		for _, t := range r.Form["r"] {
+			s.greaderTag(a.User, greaderStream(t), false, keys)
+		}
+		s.greaderSave()
+		fmt.Fprint(w, "OK")
+	case p == "/reader/api/0/mark-all-as-read":
+		stream := greaderStream(r.Form.Get("s"))
+		before := time.Now()
+		if ts, err := strconv.ParseInt(r.Form.Get("ts"), 10, 64); err == nil && ts > 0 {
+			before = time.Unix(0, ts*1e3)
+		}
+		read, saved := s.state.get(a.User)
+		var keys []string
+		for _, it := range userItems(s.store, subs, rules) {
+			if !it.e.Added.After(before) && greaderIn(stream, it, read, saved) {
+				keys = append(keys, it.e.key())
+			}
+		}
+		s.state.mark(a.User, false, true, keys...)
+		s.greaderSave()
+		fmt.Fprint(w, "OK")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// greaderLogin checks Email and Passwd and returns an auth token
+// derived from the account, so it outlives restarts.
+func (s *Server) greaderLogin(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.checkAccount(r.Form.Get("Email"), r.Form.Get("Passwd"))
+	if !ok {
+		logFrom(r.Context()).Debug("greader login failed", "login", r.Form.Get("Email"), "peer", r.RemoteAddr)
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+	auth := a.Login + "/" + greaderToken(a, "auth")
+	if r.Form.Get("output") == "json" {
+		greaderJSON(w, map[string]string{"SID": auth, "LSID": auth, "Auth": auth})
+		return
+	}
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", auth, auth, auth)
+}
+
+// greaderAuth finds the account of an Authorization: GoogleLogin auth=<token> header.
+func (s *Server) greaderAuth(r *http.Request) (Account, bool) {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "GoogleLogin auth=")
+	i := strings.LastIndex(auth, "/")
+	if i < 0 {
+		return Account{}, false
+	}
+	a, ok := s.account(auth[:i])
+	if !ok || !hmac.Equal([]byte(auth[i+1:]), []byte(greaderToken(a, "auth"))) {
+		return Account{}, false
+	}
+	return a, true
+}
+
+// greaderToken is a token of kind for a, that changes with its password.
+func greaderToken(a Account, kind string) string {
+	mac := hmac.New(sha256.New, []byte(a.Password))
+	mac.Write([]byte(kind + "\x00" + a.Login))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func greaderJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// greaderStream normalizes a stream id to use - for the user.
+func greaderStream(s string) string {
+	if strings.HasPrefix(s, "user/") {
+		if i := strings.Index(s[len("user/"):], "/"); i >= 0 {
+			return "user/-" + s[len("user/")+i:]
+		}
+	}
+	return s
+}
+
+// greaderItemID parses the long (hex) and short (decimal) forms of item ids.
+func greaderItemID(id string) (int64, bool) {
+	if strings.HasPrefix(id, greaderItem) {
+		n, err := strconv.ParseUint(strings.TrimPrefix(id, greaderItem), 16, 64)
+		return int64(n), err == nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	return n, err == nil
+}
+
+// greaderTags are the tags of subs, sorted.
+func greaderTags(subs []Sub) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, sub := range subs {
+		for _, t := range sub.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func greaderSubs(subs []Sub) []map[string]interface{} {
+	seen := make(map[string]bool)
+	out := make([]map[string]interface{}, 0, len(subs))
+	for _, sub := range subs {
+		if seen[sub.URL] {
+			continue
+		}
+		seen[sub.URL] = true
+		cats := make([]map[string]string, 0, len(sub.Tags))
+		for _, t := range sub.Tags {
+			cats = append(cats, map[string]string{"id": greaderLabel + t, "label": t})
+		}
+		out = append(out, map[string]interface{}{
+			"id":         greaderFeed + sub.URL,
+			"title":      sub.Name,
+			"categories": cats,
+			"url":        sub.URL,
+			"htmlUrl":    sub.URL,
+			"iconUrl":    icons.url(sub.feed()),
+		})
+	}
+	return out
+}
+
+// greaderIn reports whether it is in stream.
+func greaderIn(stream string, it userItem, read, saved map[string]bool) bool {
+	switch {
+	case stream == greaderReadingList:
+		return true
+	case stream == greaderRead:
+		return read[it.e.key()]
+	case stream == greaderStarred:
+		return saved[it.e.key()]
+	case strings.HasPrefix(stream, greaderFeed):
+		return it.sub.URL == strings.TrimPrefix(stream, greaderFeed)
+	case strings.HasPrefix(stream, greaderLabel):
+		t := strings.TrimPrefix(stream, greaderLabel)
+		for _, tag := range it.tags {
+			if tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// greaderSelect selects the items in stream, filtered by
+// xt (exclude stream), it (include stream), ot (newer than), nt (older than),
+// ordered newest first or oldest first with r=o,
+// and paged with n and the continuation c.
+func (s *Server) greaderSelect(r *http.Request, a Account, stream string, items []userItem) ([]userItem, string) {
+	stream = greaderStream(stream)
+	if stream == "" {
+		stream = greaderReadingList
+	}
+	read, saved := s.state.get(a.User)
+	var ot, nt time.Time
+	if n, err := strconv.ParseInt(r.Form.Get("ot"), 10, 64); err == nil {
+		ot = time.Unix(n, 0)
+	}
+	if n, err := strconv.ParseInt(r.Form.Get("nt"), 10, 64); err == nil {
+		nt = time.Unix(n, 0)
+	}
+
+	var out []userItem
+	for _, it := range items {
+		if !greaderIn(stream, it, read, saved) {
+			continue
+		}
+		if xt := r.Form.Get("xt"); xt != "" && greaderIn(greaderStream(xt), it, read, saved) {
+			continue
+		}
+		if in := r.Form.Get("it"); in != "" && !greaderIn(greaderStream(in), it, read, saved) {
+			continue
+		}
+		if (!ot.IsZero() && it.e.Added.Before(ot)) || (!nt.IsZero() && it.e.Added.After(nt)) {
+			continue
+		}
+		out = append(out, it)
+	}
+	if r.Form.Get("r") != "o" {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+
+	n, err := strconv.Atoi(r.Form.Get("n"))
+	if err != nil || n <= 0 {
+		n = 20
+	}
+	if n > 10000 {
+		n = 10000
+	}
+	c, _ := strconv.Atoi(r.Form.Get("c"))
+	if c < 0 || c > len(out) {
+		c = len(out)
+	}
+	out = out[c:]
+	var cont string
+	if len(out) > n {
+		out = out[:n]
+		cont = strconv.Itoa(c + n)
+	}
+	return out, cont
+}
+
+// greaderContents writes items in the stream contents format.
+func (s *Server) greaderContents(w http.ResponseWriter, a Account, stream string, items []userItem, cont string) {
+	read, saved := s.state.get(a.User)
+	out := make([]map[string]interface{}, 0, len(items))
+	for _, it := range items {
+		cats := []string{greaderReadingList}
+		if read[it.e.key()] {
+			cats = append(cats, greaderRead)
+		}
+		if saved[it.e.key()] {
+			cats = append(cats, greaderStarred)
+		}
+		for _, t := range it.tags {
+			cats = append(cats, greaderLabel+t)
+		}
+		var enclosures []map[string]string
+		if ep := it.e.episode(); ep != nil {
+			enclosures = append(enclosures, map[string]string{
+				"href":   ep.AudioUrl,
+				"type":   ep.AudioType,
+				"length": strconv.FormatInt(ep.AudioLength, 10),
+			})
+		}
+		out = append(out, map[string]interface{}{
+			"id":            fmt.Sprintf("%s%016x", greaderItem, it.e.Seq),
+			"crawlTimeMsec": strconv.FormatInt(it.e.Added.UnixNano()/1e6, 10),
+			"timestampUsec": strconv.FormatInt(it.e.Added.UnixNano()/1e3, 10),
+			"published":     it.e.Time.Unix(),
+			"updated":       it.e.Time.Unix(),
+			"title":         it.e.Title,
+			"author":        it.e.Author,
+			"canonical":     []map[string]string{{"href": it.e.URL}},
+			"alternate":     []map[string]string{{"href": it.e.URL, "type": "text/html"}},
+			"categories":    cats,
+			"origin": map[string]string{
+				"streamId": greaderFeed + it.sub.URL,
+				"title":    it.sub.Name,
+				"htmlUrl":  it.sub.URL,
+			},
+			"summary": map[string]string{
+				"direction": "ltr",
+				"content":   it.e.body(),
+			},
+			"enclosure": enclosures,
+		})
+	}
+	res := map[string]interface{}{
+		"id":      stream,
+		"updated": time.Now().Unix(),
+		"items":   out,
+	}
+	if cont != "" {
+		res["continuation"] = cont
+	}
+	greaderJSON(w, res)
+}
+
+// greaderUnread counts unread items by feed and label.
+func (s *Server) greaderUnread(w http.ResponseWriter, a Account, subs []Sub, rules Rules) {
+	read, _ := s.state.get(a.User)
+	counts := make(map[string]int)
+	newest := make(map[string]time.Time)
+	count := func(id string, e *Entry) {
+		counts[id]++
+		if e.Added.After(newest[id]) {
+			newest[id] = e.Added
+		}
+	}
+	for _, it := range userItems(s.store, subs, rules) {
+		if read[it.e.key()] {
+			continue
+		}
+		count(greaderReadingList, it.e)
+		count(greaderFeed+it.sub.URL, it.e)
+		for _, t := range it.tags {
+			count(greaderLabel+t, it.e)
+		}
+	}
+	out := make([]map[string]interface{}, 0, len(counts))
+	for id, n := range counts {
+		out = append(out, map[string]interface{}{
+			"id":                      id,
+			"count":                   n,
+			"newestItemTimestampUsec": strconv.FormatInt(newest[id].UnixNano()/1e3, 10),
+		})
+	}
+	greaderJSON(w, map[string]interface{}{"max": 10000, "unreadcounts": out})
+}
+
+// greaderTag adds or removes the read or starred state, other tags are ignored.
+func (s *Server) greaderTag(user, tag string, add bool, keys []string) {
+	switch tag {
+	case greaderRead:
+		s.state.mark(user, false, add, keys...)
+	case greaderStarred:
+		s.state.mark(user, true, add, keys...)
+	}
+}
+
+func (s *Server) greaderSave() {
+	if err := s.state.save(s.store); err != nil {
+		logger.Error("save read state", "file", s.state.fn, "error", err)
+	}
+}
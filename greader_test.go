@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	greaderFeedA = "https://example.com/a"
+	greaderFeedB = "https://example.com/b"
+)
+
+// testGreaderServer serves alice greaderFeedA tagged blogs with seqs 1 to 5,
+// and greaderFeedB with seq 6.
+func testGreaderServer() *Server {
+	s := &Server{
+		accounts: map[string]Account{"me@x": {Login: "me@x", Password: "pw", User: "alice"}},
+		users: map[string][]Sub{"alice": {
+			{Name: "A", URL: greaderFeedA, Tags: []string{"blogs"}},
+			{Name: "B", URL: greaderFeedB},
+		}},
+		store: NewStore(""),
+		state: loadReadState(""),
+	}
+	t0 := time.Now().Add(-time.Hour)
+	for i := 1; i <= 5; i++ {
+		s.store.Add([]*Entry{{ID: fmt.Sprint(i), Feed: greaderFeedA, Title: fmt.Sprint("a ", i), Time: t0}})
+	}
+	s.store.Add([]*Entry{{ID: "6", Feed: greaderFeedB, Title: "b 6", Time: t0}})
+	return s
+}
+
+var greaderAuthHeader = "GoogleLogin auth=me@x/" + greaderToken(Account{Login: "me@x", Password: "pw"}, "auth")
+
+// greaderDo calls the api at path, with form in the query for GET or the body for POST.
+func greaderDo(s *Server, method, path string, form url.Values, auth string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if method == http.MethodGet {
+		if len(form) > 0 {
+			path += "?" + form.Encode()
+		}
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		r = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	s.greader(w, r)
+	return w
+}
+
+// greaderIDs returns the seqs of the items in a stream contents or item ids response.
+func greaderIDs(t *testing.T, w *httptest.ResponseRecorder) ([]int64, string) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %v: %s", w.Code, w.Body.String())
+	}
+	var res struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+		ItemRefs []struct {
+			ID string `json:"id"`
+		} `json:"itemRefs"`
+		Continuation string `json:"continuation"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	var ids []int64
+	for _, it := range append(res.Items, res.ItemRefs...) {
+		n, ok := greaderItemID(it.ID)
+		if !ok {
+			t.Fatalf("bad item id %q", it.ID)
+		}
+		ids = append(ids, n)
+	}
+	return ids, res.Continuation
+}
+
+func TestGreaderLogin(t *testing.T) {
+	s := testGreaderServer()
+	auth := "me@x/" + greaderToken(Account{Login: "me@x", Password: "pw"}, "auth")
+	tcs := []struct {
+		name   string
+		form   url.Values
+		status int
+		body   string
+	}{
+		{name: "text", form: url.Values{"Email": {"me@x"}, "Passwd": {"pw"}}, status: http.StatusOK, body: fmt.Sprintf("SID=%s\nLSID=%s\nAuth=%s\n", auth, auth, auth)},
+		{name: "json", form: url.Values{"Email": {"me@x"}, "Passwd": {"pw"}, "output": {"json"}}, status: http.StatusOK, body: fmt.Sprintf(`{"Auth":%q,"LSID":%q,"SID":%q}`+"\n", auth, auth, auth)},
+		{name: "bad password", form: url.Values{"Email": {"me@x"}, "Passwd": {"nope"}}, status: http.StatusUnauthorized, body: "Error=BadAuthentication\n"},
+		{name: "unknown login", form: url.Values{"Email": {"who"}, "Passwd": {"pw"}}, status: http.StatusUnauthorized, body: "Error=BadAuthentication\n"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			w := greaderDo(s, http.MethodPost, "/greader/accounts/ClientLogin", tc.form, "")
+			if w.Code != tc.status || w.Body.String() != tc.body {
+				t.Errorf("login = %v %q, want %v %q", w.Code, w.Body.String(), tc.status, tc.body)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		auth   string
+		status int
+	}{
+		{"GoogleLogin auth=" + auth, http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"GoogleLogin auth=me@x/" + strings.Repeat("0", 64), http.StatusUnauthorized},
+		{"GoogleLogin auth=who/" + greaderToken(Account{Login: "who", Password: "pw"}, "auth"), http.StatusUnauthorized},
+		{"GoogleLogin auth=me@x/" + greaderToken(Account{Login: "me@x", Password: "pw"}, "T"), http.StatusUnauthorized},
+	} {
+		w := greaderDo(s, http.MethodGet, "/greader/reader/api/0/user-info", nil, tc.auth)
+		if w.Code != tc.status {
+			t.Errorf("user-info with %q = %v, want %v", tc.auth, w.Code, tc.status)
+		}
+	}
+
+	// changing the password logs clients out
+	s.accounts["me@x"] = Account{Login: "me@x", Password: "new", User: "alice"}
+	if w := greaderDo(s, http.MethodGet, "/greader/reader/api/0/user-info", nil, "GoogleLogin auth="+auth); w.Code != http.StatusUnauthorized {
+		t.Errorf("user-info after password change = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestGreaderToken(t *testing.T) {
+	s := testGreaderServer()
+	w := greaderDo(s, http.MethodGet, "/greader/reader/api/0/token", nil, greaderAuthHeader)
+	token := w.Body.String()
+	if w.Code != http.StatusOK || token != greaderToken(Account{Login: "me@x", Password: "pw"}, "T") {
+		t.Fatalf("token = %v %q", w.Code, token)
+	}
+	if w := greaderDo(s, http.MethodGet, "/greader/reader/api/0/token", nil, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("token without auth = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	tcs := []struct {
+		name   string
+		method string
+		path   string
+		t      string
+		status int
+	}{
+		{name: "edit-tag", method: http.MethodPost, path: "edit-tag", t: token, status: http.StatusOK},
+		{name: "edit-tag no token", method: http.MethodPost, path: "edit-tag", status: http.StatusUnauthorized},
+		{name: "edit-tag bad token", method: http.MethodPost, path: "edit-tag", t: token + "0", status: http.StatusUnauthorized},
+		{name: "edit-tag get", method: http.MethodGet, path: "edit-tag", t: token, status: http.StatusMethodNotAllowed},
+		{name: "mark-all-as-read", method: http.MethodPost, path: "mark-all-as-read", t: token, status: http.StatusOK},
+		{name: "mark-all-as-read no token", method: http.MethodPost, path: "mark-all-as-read", status: http.StatusUnauthorized},
+		{name: "mark-all-as-read get", method: http.MethodGet, path: "mark-all-as-read", t: token, status: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{"i": {"1"}, "a": {greaderRead}, "s": {greaderFeed + greaderFeedB}}
+			if tc.t != "" {
+				form.Set("T", tc.t)
+			}
+			w := greaderDo(s, tc.method, "/greader/reader/api/0/"+tc.path, form, greaderAuthHeader)
+			if w.Code != tc.status {
+				t.Fatalf("status = %v, want %v: %s", w.Code, tc.status, w.Body.String())
+			}
+			bad := w.Header().Get("X-Reader-Google-Bad-Token")
+			if (tc.status == http.StatusUnauthorized) != (bad == "true") {
+				t.Errorf("X-Reader-Google-Bad-Token = %q", bad)
+			}
+		})
+	}
+	read, _ := s.state.get("alice")
+	if len(read) != 2 {
+		t.Errorf("read = %v, want only the edits with a token", read)
+	}
+}
+
+func TestGreaderStreamContents(t *testing.T) {
+	s := testGreaderServer()
+	for _, e := range s.store.Feeds()[greaderFeedA] {
+		if e.Seq == 1 {
+			s.state.mark("alice", false, true, e.key())
+		}
+	}
+	contents := "/greader/reader/api/0/stream/contents/"
+	tcs := []struct {
+		name string
+		path string
+		form url.Values
+		want []int64
+		cont string
+	}{
+		{name: "reading list", path: contents + greaderReadingList, want: []int64{6, 5, 4, 3, 2, 1}},
+		{name: "default stream", path: contents, want: []int64{6, 5, 4, 3, 2, 1}},
+		{name: "first page", path: contents + greaderReadingList, form: url.Values{"n": {"2"}}, want: []int64{6, 5}, cont: "2"},
+		{name: "continuation", path: contents + greaderReadingList, form: url.Values{"n": {"2"}, "c": {"2"}}, want: []int64{4, 3}, cont: "4"},
+		{name: "last page", path: contents + greaderReadingList, form: url.Values{"n": {"2"}, "c": {"4"}}, want: []int64{2, 1}},
+		{name: "past the end", path: contents + greaderReadingList, form: url.Values{"c": {"9"}}},
+		{name: "oldest first", path: contents + greaderReadingList, form: url.Values{"r": {"o"}, "n": {"3"}}, want: []int64{1, 2, 3}, cont: "3"},
+		{name: "feed escaped", path: contents + url.PathEscape(greaderFeed+greaderFeedB), want: []int64{6}},
+		{name: "feed unescaped", path: contents + greaderFeed + greaderFeedB, want: []int64{6}},
+		{name: "feed param", path: contents, form: url.Values{"s": {greaderFeed + greaderFeedA}, "n": {"2"}}, want: []int64{5, 4}, cont: "2"},
+		{name: "label", path: contents + "user/-/label/blogs", want: []int64{5, 4, 3, 2, 1}},
+		{name: "label of user id", path: contents + "user/1234/label/blogs", want: []int64{5, 4, 3, 2, 1}},
+		{name: "exclude read", path: contents + greaderReadingList, form: url.Values{"xt": {"user/-/state/com.google/read"}}, want: []int64{6, 5, 4, 3, 2}},
+		{name: "read", path: contents + greaderRead, want: []int64{1}},
+		{name: "unknown feed", path: contents + greaderFeed + "https://example.com/c"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ids, cont := greaderIDs(t, greaderDo(s, http.MethodGet, tc.path, tc.form, greaderAuthHeader))
+			if !reflect.DeepEqual(ids, tc.want) || cont != tc.cont {
+				t.Errorf("items = %v continuation %q, want %v %q", ids, cont, tc.want, tc.cont)
+			}
+		})
+	}
+}
+
+func TestGreaderEditTag(t *testing.T) {
+	s := testGreaderServer()
+	token := greaderToken(Account{Login: "me@x", Password: "pw"}, "T")
+	long := func(n int64) string { return fmt.Sprintf("%s%016x", greaderItem, n) }
+	steps := []struct {
+		form    url.Values
+		read    []int64
+		starred []int64
+	}{
+		{form: url.Values{"i": {long(2), "3"}, "a": {greaderRead}}, read: []int64{3, 2}},
+		{form: url.Values{"i": {"3"}, "r": {"user/1234/state/com.google/read"}}, read: []int64{2}},
+		{form: url.Values{"i": {long(6), "99"}, "a": {greaderStarred, "user/-/label/ignored"}}, read: []int64{2}, starred: []int64{6}},
+		{form: url.Values{"i": {"6", long(2)}, "a": {greaderRead}, "r": {greaderStarred}}, read: []int64{6, 2}},
+	}
+	for i, st := range steps {
+		st.form.Set("T", token)
+		w := greaderDo(s, http.MethodPost, "/greader/reader/api/0/edit-tag", st.form, greaderAuthHeader)
+		if w.Code != http.StatusOK || w.Body.String() != "OK" {
+			t.Fatalf("step %d edit-tag = %v %q", i, w.Code, w.Body.String())
+		}
+		read, _ := greaderIDs(t, greaderDo(s, http.MethodGet, "/greader/reader/api/0/stream/items/ids", url.Values{"s": {greaderRead}}, greaderAuthHeader))
+		starred, _ := greaderIDs(t, greaderDo(s, http.MethodGet, "/greader/reader/api/0/stream/items/ids", url.Values{"s": {greaderStarred}}, greaderAuthHeader))
+		if !reflect.DeepEqual(read, st.read) || !reflect.DeepEqual(starred, st.starred) {
+			t.Errorf("step %d read %v starred %v, want %v %v", i, read, starred, st.read, st.starred)
+		}
+	}
+}
+
+func TestGreaderMarkAllAsRead(t *testing.T) {
+	token := greaderToken(Account{Login: "me@x", Password: "pw"}, "T")
+	tcs := []struct {
+		name   string
+		form   url.Values
+		unread []int64
+	}{
+		{name: "feed", form: url.Values{"s": {greaderFeed + greaderFeedA}}, unread: []int64{6}},
+		{name: "label", form: url.Values{"s": {"user/1234/label/blogs"}}, unread: []int64{6}},
+		{name: "reading list", form: url.Values{"s": {greaderReadingList}}},
+		{name: "before added", form: url.Values{"s": {greaderReadingList}, "ts": {"1"}}, unread: []int64{6, 5, 4, 3, 2, 1}},
+		{name: "after added", form: url.Values{"s": {greaderFeed + greaderFeedB}, "ts": {fmt.Sprint(time.Now().Add(time.Minute).UnixNano() / 1e3)}}, unread: []int64{5, 4, 3, 2, 1}},
+		{name: "unknown feed", form: url.Values{"s": {greaderFeed + "https://example.com/c"}}, unread: []int64{6, 5, 4, 3, 2, 1}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			s := testGreaderServer()
+			tc.form.Set("T", token)
+			w := greaderDo(s, http.MethodPost, "/greader/reader/api/0/mark-all-as-read", tc.form, greaderAuthHeader)
+			if w.Code != http.StatusOK || w.Body.String() != "OK" {
+				t.Fatalf("mark-all-as-read = %v %q", w.Code, w.Body.String())
+			}
+			unread, _ := greaderIDs(t, greaderDo(s, http.MethodGet, "/greader/reader/api/0/stream/items/ids", url.Values{"s": {greaderReadingList}, "xt": {greaderRead}}, greaderAuthHeader))
+			if !reflect.DeepEqual(unread, tc.unread) {
+				t.Errorf("unread = %v, want %v", unread, tc.unread)
+			}
+		})
+	}
+}
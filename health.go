@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// setReady marks the server as ready to serve,
+// once the config is loaded and articles are available,
+// either from a completed refresh or from the store.
+func (s *Server) setReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return
+	}
+	s.ready = true
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus("readss.Lister", healthpb.HealthCheckResponse_SERVING)
+}
+
+// setDraining marks the server as no longer ready as it shuts down.
+func (s *Server) setDraining() {
+	s.mu.Lock()
+	s.ready, s.draining = false, true
+	s.mu.Unlock()
+	// sets every service to NOT_SERVING and ignores later updates
+	s.health.Shutdown()
+}
+
+func (s *Server) isReady() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ready
+}
+
+func newHealth() *health.Server {
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus("readss.Lister", healthpb.HealthCheckResponse_NOT_SERVING)
+	return hs
+}
+
+// healthz reports the process is alive.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz reports whether the server is ready to serve.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.isReady() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
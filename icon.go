@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	iconClient = &http.Client{Timeout: 30 * time.Second}
+	// iconSize is the width and height of normalized icons
+	iconSize = 64
+	// iconMaxBytes limits the size of icons and pages read looking for them
+	iconMaxBytes int64 = 2 << 20
+	// iconMaxPixels limits the width and height of decoded icons,
+	// small files can claim large images
+	iconMaxPixels = 1024
+	// iconRefresh is how often icons are fetched again
+	iconRefresh = 7 * 24 * time.Hour
+	// iconRetry is how long to wait before looking again for an icon that wasn't found
+	iconRetry = 24 * time.Hour
+)
+
+// icons resolves and caches an icon for each feed,
+// it is nil if there is no IconDir.
+var icons *iconCache
+
+// iconCache keeps icons in dir/<pushID of feed>.png.
+type iconCache struct {
+	dir string
+	c   chan iconJob
+
+	mu sync.RWMutex
+	// fetched maps feed ids to when their icon was written
+	fetched map[string]time.Time
+	// tried maps feed ids to when their icon was last looked for
+	tried map[string]time.Time
+}
+
+// iconJob lists where the icon of a feed may be found:
+// icons, then those declared by the site page, then favicon.
+type iconJob struct {
+	feed    string
+	icons   []string
+	page    string
+	favicon string
+}
+
+func newIconCache(dir string) *iconCache {
+	ic := &iconCache{
+		dir:     dir,
+		c:       make(chan iconJob, 64),
+		fetched: make(map[string]time.Time),
+		tried:   make(map[string]time.Time),
+	}
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if strings.HasSuffix(fi.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		if strings.HasSuffix(fi.Name(), ".png") {
+			ic.fetched[strings.TrimSuffix(fi.Name(), ".png")] = fi.ModTime()
+		}
+	}
+	return ic
+}
+
+// url is where the icon of feed u is served, if there is one.
+// It changes when the icon is refreshed so it can be cached for long.
+func (ic *iconCache) url(u string) string {
+	if ic == nil {
+		return ""
+	}
+	id := pushID(u)
+	ic.mu.RLock()
+	t, ok := ic.fetched[id]
+	ic.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(PublicURL, "/") + "/icons/" + id + ".png?v=" + strconv.FormatInt(t.Unix(), 36)
+}
+
+// data is the png icon of feed u, if there is one.
+func (ic *iconCache) data(u string) []byte {
+	if ic == nil {
+		return nil
+	}
+	id := pushID(u)
+	ic.mu.RLock()
+	_, ok := ic.fetched[id]
+	ic.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(ic.dir, id+".png"))
+	return b
+}
+
+// discover queues a lookup of the icon of feed u if it has none or it is stale,
+// from the atom icon, the feed image, then the icons declared by the site.
+func (ic *iconCache) discover(u string, feed *gofeed.Feed) {
+	if ic == nil {
+		return
+	}
+	id := pushID(u)
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	if time.Since(ic.fetched[id]) < iconRefresh || time.Since(ic.tried[id]) < iconRetry {
+		return
+	}
+	ic.tried[id] = time.Now()
+
+	j := iconJob{feed: u}
+	if s := feed.Custom["icon"]; s != "" {
+		j.icons = append(j.icons, s)
+	}
+	if feed.Image != nil && feed.Image.URL != "" {
+		j.icons = append(j.icons, resolveRef(u, feed.Image.URL))
+	}
+	site := u
+	if feed.Link != "" {
+		site = resolveRef(u, feed.Link)
+	}
+	if su, err := url.Parse(site); err == nil && (su.Scheme == "http" || su.Scheme == "https") {
+		j.page = site
+		if feed.Link == "" {
+			// the feed itself isn't a page, try the home page
+			j.page = su.Scheme + "://" + su.Host + "/"
+		}
+		j.favicon = su.Scheme + "://" + su.Host + "/favicon.ico"
+	}
+	select {
+	case ic.c <- j:
+	default:
+		// looked for again after iconRetry
+	}
+}
+
+// run looks up queued icons one at a time until ctx is done.
+func (ic *iconCache) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-ic.c:
+			if err := ic.fetch(ctx, j); err != nil {
+				iconFetches.Add(1, "error")
+				logger.Debug("fetch icon", "url", redactURL(j.feed), "error", err)
+			}
+		}
+	}
+}
+
+// fetch tries the icons of j in order, writing the first that decodes.
+func (ic *iconCache) fetch(ctx context.Context, j iconJob) error {
+	ctx, sp := startSpan(ctx, "fetch icon", "url", redactURL(j.feed))
+	sp.kind = spanClient
+	defer sp.End()
+
+	err := errors.New("no icon")
+	cands := j.icons
+	for i := 0; i < len(cands) || j.page != ""; i++ {
+		if i == len(cands) {
+			// only look at the site once the feed's own icons failed
+			site, serr := siteIcons(ctx, j.page)
+			if serr != nil {
+				logger.Debug("find site icons", "url", j.page, "error", serr)
+			}
+			cands = append(append(cands, site...), j.favicon)
+			j.page = ""
+		}
+		c := cands[i]
+		var b []byte
+		b, _, err = iconGet(ctx, c)
+		if err != nil {
+			continue
+		}
+		var img image.Image
+		img, err = decodeIcon(b)
+		if err != nil {
+			err = fmt.Errorf("%v: %v", c, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, scaleIcon(img, iconSize)); err != nil {
+			break
+		}
+		if err = os.MkdirAll(ic.dir, 0755); err != nil {
+			break
+		}
+		id := pushID(j.feed)
+		fn := filepath.Join(ic.dir, id+".png")
+		if err = ioutil.WriteFile(fn+".tmp", buf.Bytes(), 0644); err == nil {
+			err = os.Rename(fn+".tmp", fn)
+		}
+		if err != nil {
+			os.Remove(fn + ".tmp")
+			break
+		}
+		ic.mu.Lock()
+		ic.fetched[id] = time.Now()
+		ic.mu.Unlock()
+		iconFetches.Add(1, "ok")
+		sp.SetAttributes("icon", c)
+		logger.Debug("fetched icon", "url", redactURL(j.feed), "icon", c)
+		return nil
+	}
+	sp.SetError(err)
+	return err
+}
+
+// siteIcons finds the apple-touch-icons and icons declared by page u,
+// largest first.
+func siteIcons(ctx context.Context, u string) ([]string, error) {
+	b, ct, err := iconGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if ct != "" && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("%v: content type %v", u, ct)
+	}
+	r, err := charset.NewReader(bytes.NewReader(b), ct)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	type cand struct {
+		url  string
+		size int
+	}
+	var cs []cand
+	doc.Find("link[rel][href]").Each(func(i int, s *goquery.Selection) {
+		var ok bool
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			switch rel {
+			case "icon", "apple-touch-icon", "apple-touch-icon-precomposed":
+				ok = true
+			}
+		}
+		if !ok || strings.Contains(s.AttrOr("type", ""), "svg") || strings.HasSuffix(s.AttrOr("href", ""), ".svg") {
+			return
+		}
+		// apple-touch-icons are 180x180 unless stated
+		size := 16
+		if strings.Contains(s.AttrOr("rel", ""), "apple") {
+			size = 180
+		}
+		for _, sz := range strings.Fields(s.AttrOr("sizes", "")) {
+			if n, err := strconv.Atoi(strings.SplitN(strings.ToLower(sz), "x", 2)[0]); err == nil && n > 0 {
+				size = n
+			}
+		}
+		cs = append(cs, cand{resolveRef(u, s.AttrOr("href", "")), size})
+	})
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].size > cs[j].size })
+	us := make([]string, 0, len(cs))
+	for _, c := range cs {
+		us = append(us, c.url)
+	}
+	return us, nil
+}
+
+// iconGet fetches u, returning its content type.
+// The content type of icons isn't checked, favicons are often served as anything,
+// they just have to decode.
+func iconGet(ctx context.Context, u string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "readss")
+	res, err := iconClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%v: status %v", u, res.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, iconMaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(b)) > iconMaxBytes {
+		return nil, "", fmt.Errorf("%v: over %v bytes", u, iconMaxBytes)
+	}
+	return b, res.Header.Get("Content-Type"), nil
+}
+
+// decodeIcon decodes png, jpeg, gif or ico images,
+// using the largest image in an ico.
+func decodeIcon(b []byte) (image.Image, error) {
+	if len(b) < 6 || binary.LittleEndian.Uint16(b[0:]) != 0 || binary.LittleEndian.Uint16(b[2:]) != 1 {
+		if err := checkIconSize(b); err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(b))
+		return img, err
+	}
+
+	n := int(binary.LittleEndian.Uint16(b[4:]))
+	var best []byte
+	var bestSize int
+	if len(b) < 6+16*n {
+		return nil, errors.New("short ico directory")
+	}
+	for i := 0; i < n; i++ {
+		e := b[6+16*i:]
+		// 0 means 256
+		w, h := int(e[0]), int(e[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		size, off := binary.LittleEndian.Uint32(e[8:]), binary.LittleEndian.Uint32(e[12:])
+		if w > iconMaxPixels || h > iconMaxPixels || uint64(off)+uint64(size) > uint64(len(b)) {
+			continue
+		}
+		if w > bestSize {
+			best, bestSize = b[off:off+size], w
+		}
+	}
+	if best == nil {
+		return nil, errors.New("no image in ico")
+	}
+	// the directory sizes needn't match the images
+	if bytes.HasPrefix(best, []byte("\x89PNG")) {
+		if err := checkIconSize(best); err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(best))
+	}
+	return decodeDIB(best)
+}
+
+// checkIconSize checks the dimensions in an image header before it is decoded.
+func checkIconSize(b []byte) error {
+	c, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if c.Width > iconMaxPixels || c.Height > iconMaxPixels {
+		return fmt.Errorf("image %vx%v over %vx%v", c.Width, c.Height, iconMaxPixels, iconMaxPixels)
+	}
+	return nil
+}
+
+// decodeDIB decodes the 8, 24 or 32 bit bitmaps in ico files,
+// which are stored bottom up at double height to make room for a 1 bit mask.
+func decodeDIB(b []byte) (image.Image, error) {
+	if len(b) < 40 {
+		return nil, errors.New("short bitmap header")
+	}
+	hdr := int(binary.LittleEndian.Uint32(b[0:]))
+	w := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	h := int(int32(binary.LittleEndian.Uint32(b[8:]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(b[14:]))
+	// bitmaps in ico files are at most 256 pixels, well under iconMaxPixels
+	if w <= 0 || h <= 0 || w > 256 || h > 256 || hdr < 40 || hdr > len(b) {
+		return nil, errors.New("bad bitmap size")
+	}
+	var palette []color.NRGBA
+	data := b[hdr:]
+	if bpp == 8 {
+		colors := int(binary.LittleEndian.Uint32(b[32:]))
+		if colors == 0 || colors > 256 {
+			colors = 256
+		}
+		if len(data) < 4*colors {
+			return nil, errors.New("short palette")
+		}
+		for i := 0; i < colors; i++ {
+			p := data[4*i:]
+			palette = append(palette, color.NRGBA{p[2], p[1], p[0], 0xff})
+		}
+		data = data[4*colors:]
+	} else if bpp != 24 && bpp != 32 {
+		return nil, fmt.Errorf("unsupported %v bit bitmap", bpp)
+	}
+
+	stride := (w*bpp/8 + 3) &^ 3
+	maskStride := ((w+7)/8 + 3) &^ 3
+	if len(data) < stride*h {
+		return nil, errors.New("short bitmap")
+	}
+	mask := data[stride*h:]
+	hasMask := len(mask) >= maskStride*h
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		row := data[(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 8:
+				if i := int(row[x]); i < len(palette) {
+					c = palette[i]
+				}
+			case 24:
+				c = color.NRGBA{row[3*x+2], row[3*x+1], row[3*x], 0xff}
+			case 32:
+				c = color.NRGBA{row[4*x+2], row[4*x+1], row[4*x], row[4*x+3]}
+			}
+			if bpp != 32 && hasMask && mask[(h-1-y)*maskStride+x/8]&(0x80>>uint(x%8)) != 0 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// scaleIcon fits img into a transparent size x size square,
+// averaging the pixels that fall in each output pixel when shrinking.
+func scaleIcon(img image.Image, size int) image.Image {
+	src := image.NewRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := size, size
+	if sw > sh {
+		dh = max1(size * sh / sw)
+	} else if sh > sw {
+		dw = max1(size * sw / sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	ox, oy := (size-dw)/2, (size-dh)/2
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			// premultiplied, so transparent pixels don't darken edges
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.RGBAAt(src.Bounds().Min.X+sx, src.Bounds().Min.Y+sy)
+					r, g, b, a, n = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A), n+1
+				}
+			}
+			dst.SetRGBA(ox+x, oy+y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// feedIcon finds the atom icon of a feed, which gofeed doesn't keep.
+func feedIcon(u string, b []byte) string {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "item", "entry":
+			return ""
+		case "icon":
+			var s string
+			if d.DecodeElement(&s, &se) != nil || strings.TrimSpace(s) == "" {
+				return ""
+			}
+			return resolveRef(u, strings.TrimSpace(s))
+		}
+	}
+}
+
+// ServeHTTP serves icons under /icons/.
+func (ic *iconCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	ic.mu.RLock()
+	_, ok := ic.fetched[strings.TrimSuffix(name, ".png")]
+	ic.mu.RUnlock()
+	if !ok || !strings.HasSuffix(name, ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=604800")
+	http.ServeFile(w, r, filepath.Join(ic.dir, name))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// dib builds an ico bitmap of w×h pixels, rows given top down.
+func dib(w, h, bpp int, palette []color.NRGBA, rows [][]byte, mask [][]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 40)
+	binary.LittleEndian.PutUint32(hdr[0:], 40)
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(w))
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(2*h))
+	binary.LittleEndian.PutUint16(hdr[12:], 1)
+	binary.LittleEndian.PutUint16(hdr[14:], uint16(bpp))
+	binary.LittleEndian.PutUint32(hdr[32:], uint32(len(palette)))
+	b.Write(hdr)
+	for _, c := range palette {
+		b.Write([]byte{c.B, c.G, c.R, 0})
+	}
+	pad := func(row []byte) []byte {
+		for len(row)%4 != 0 {
+			row = append(row, 0)
+		}
+		return row
+	}
+	for y := len(rows) - 1; y >= 0; y-- {
+		b.Write(pad(append([]byte(nil), rows[y]...)))
+	}
+	for y := len(mask) - 1; y >= 0; y-- {
+		b.Write(pad(append([]byte(nil), mask[y]...)))
+	}
+	return b.Bytes()
+}
+
+func TestDecodeDIB(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xff, 0xff}
+	clear := color.NRGBA{}
+	tcs := []struct {
+		name string
+		b    []byte
+		// want is the pixels, top down
+		want [][]color.NRGBA
+		err  string
+	}{
+		{
+			name: "24 bit",
+			b: dib(2, 2, 24, nil, [][]byte{
+				{0, 0, 0xff, 0xff, 0, 0},
+				{0xff, 0, 0, 0, 0, 0xff},
+			}, nil),
+			want: [][]color.NRGBA{{red, blue}, {blue, red}},
+		},
+		{
+			name: "24 bit masked",
+			b: dib(2, 1, 24, nil, [][]byte{
+				{0, 0, 0xff, 0xff, 0, 0},
+			}, [][]byte{{0x40}}),
+			want: [][]color.NRGBA{{red, {0, 0, 0xff, 0}}},
+		},
+		{
+			name: "32 bit alpha",
+			b: dib(1, 2, 32, nil, [][]byte{
+				{0, 0, 0xff, 0x80},
+				{0xff, 0, 0, 0xff},
+			}, nil),
+			want: [][]color.NRGBA{{{0xff, 0, 0, 0x80}}, {blue}},
+		},
+		{
+			name: "8 bit palette",
+			b:    dib(3, 1, 8, []color.NRGBA{red, blue}, [][]byte{{1, 0, 7}}, nil),
+			want: [][]color.NRGBA{{blue, red, clear}},
+		},
+		{
+			name: "short header",
+			b:    make([]byte, 20),
+			err:  "short bitmap header",
+		},
+		{
+			name: "too wide",
+			b:    dib(300, 1, 24, nil, nil, nil),
+			err:  "bad bitmap size",
+		},
+		{
+			name: "zero height",
+			b:    dib(1, 0, 24, nil, nil, nil),
+			err:  "bad bitmap size",
+		},
+		{
+			name: "4 bit",
+			b:    dib(1, 1, 4, nil, [][]byte{{0}}, nil),
+			err:  "unsupported 4 bit bitmap",
+		},
+		{
+			name: "short pixels",
+			b:    dib(4, 4, 24, nil, [][]byte{{0, 0, 0}}, nil),
+			err:  "short bitmap",
+		},
+		{
+			name: "short palette",
+			b:    dib(1, 1, 8, nil, nil, nil)[:40],
+			err:  "short palette",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := decodeDIB(tc.b)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("decodeDIB error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeDIB: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != len(tc.want[0]) || b.Dy() != len(tc.want) {
+				t.Fatalf("decodeDIB bounds = %v", b)
+			}
+			for y, row := range tc.want {
+				for x, want := range row {
+					if got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA); got != want {
+						t.Errorf("pixel %v,%v = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeIcon(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var b bytes.Buffer
+		png.Encode(&b, image.NewGray(image.Rect(0, 0, w, h)))
+		return b.Bytes()
+	}
+	// ico wraps images in an ico directory claiming size w
+	ico := func(w byte, imgs ...[]byte) []byte {
+		b := []byte{0, 0, 1, 0, byte(len(imgs)), 0}
+		off := 6 + 16*len(imgs)
+		for _, img := range imgs {
+			e := make([]byte, 16)
+			e[0], e[1] = w, w
+			binary.LittleEndian.PutUint32(e[8:], uint32(len(img)))
+			binary.LittleEndian.PutUint32(e[12:], uint32(off))
+			b = append(b, e...)
+			off += len(img)
+		}
+		for _, img := range imgs {
+			b = append(b, img...)
+		}
+		return b
+	}
+	tcs := []struct {
+		name string
+		b    []byte
+		size int
+		err  string
+	}{
+		{"png", encode(32, 16), 32, ""},
+		{"large png", encode(1024, 1024), 1024, ""},
+		{"huge png", encode(2000, 1), 0, "image 2000x1 over 1024x1024"},
+		{"ico png", ico(16, encode(16, 16)), 16, ""},
+		{"ico bitmap", ico(1, dib(1, 1, 24, nil, [][]byte{{0, 0, 0}}, nil)), 1, ""},
+		{"ico huge png", ico(16, encode(1, 4096)), 0, "image 1x4096 over 1024x1024"},
+		{"ico short directory", []byte{0, 0, 1, 0, 2, 0}, 0, "short ico directory"},
+		{"ico out of bounds", append(ico(16, encode(1, 1))[:6+16], 0), 0, "no image in ico"},
+		{"not an image", []byte("<html></html>"), 0, "image: unknown format"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := decodeIcon(tc.b)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("decodeIcon error = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeIcon: %v", err)
+			}
+			if w := img.Bounds().Dx(); w != tc.size {
+				t.Errorf("decodeIcon width = %v, want %v", w, tc.size)
+			}
+		})
+	}
+}
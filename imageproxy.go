@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// imageClient only connects to public addresses,
+	// as anyone who can get a url into a feed can have it signed
+	imageClient = &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+				Control:   publicOnly,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+	// imageMaxBytes limits the size of proxied images
+	imageMaxBytes int64 = 10 << 20
+	// imageCacheFor is how long cached images are kept after they were last served
+	imageCacheFor = 30 * 24 * time.Hour
+
+	// imageTypes are the content types proxied and their extensions in the cache,
+	// svg is left out as it can carry scripts
+	imageTypes = map[string]string{
+		"image/avif":               ".avif",
+		"image/bmp":                ".bmp",
+		"image/gif":                ".gif",
+		"image/jpeg":               ".jpg",
+		"image/png":                ".png",
+		"image/webp":               ".webp",
+		"image/x-icon":             ".ico",
+		"image/vnd.microsoft.icon": ".ico",
+	}
+)
+
+// privateNets are the ranges of private addresses.
+var privateNets = func() []*net.IPNet {
+	var ns []*net.IPNet
+	for _, s := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(s)
+		ns = append(ns, n)
+	}
+	return ns
+}()
+
+// publicOnly is a net.Dialer Control that refuses to connect to
+// loopback, link local, private, unspecified and multicast addresses.
+// It runs after name resolution, for every connection including redirects.
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to connect to %v", host)
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return fmt.Errorf("refusing to connect to private address %v", host)
+		}
+	}
+	return nil
+}
+
+// images proxies the images in article output through readss,
+// it is nil if there is no ImageProxyKey and images are linked directly.
+var images *imageProxy
+
+// imageProxy serves images from /images/<signature>/<base64 url>,
+// caching them in dir/<hash of url><ext> if dir is set.
+// Only urls signed with key are fetched, so it can't be used as an open proxy.
+type imageProxy struct {
+	key []byte
+	dir string
+
+	mu sync.RWMutex
+	// files maps hashes of urls to file names in dir
+	files map[string]string
+}
+
+func newImageProxy(key, dir string) *imageProxy {
+	p := &imageProxy{
+		key:   []byte(key),
+		dir:   dir,
+		files: make(map[string]string),
+	}
+	if dir == "" {
+		return p
+	}
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if strings.HasSuffix(fi.Name(), ".tmp") {
+			os.Remove(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		p.files[strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))] = fi.Name()
+	}
+	return p
+}
+
+// sign is the signature of u in proxy urls.
+func (p *imageProxy) sign(u string) string {
+	m := hmac.New(sha256.New, p.key)
+	m.Write([]byte(u))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil)[:16])
+}
+
+// url is the proxied form of the image at u,
+// or u itself if there is no proxy or it isn't a remote url.
+func (p *imageProxy) url(u string) string {
+	if p == nil || !(strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")) {
+		return u
+	}
+	base := strings.TrimSuffix(PublicURL, "/")
+	if base != "" && strings.HasPrefix(u, base+"/") {
+		return u
+	}
+	return base + "/images/" + p.sign(u) + "/" + base64.RawURLEncoding.EncodeToString([]byte(u))
+}
+
+// rewrite points the images and video posters in the html fragment s at the proxy.
+func (p *imageProxy) rewrite(s string) string {
+	if p == nil || !(strings.Contains(s, "<img") || strings.Contains(s, "poster=")) {
+		return s
+	}
+	ctx := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	ns, err := html.ParseFragment(strings.NewReader(s), ctx)
+	if err != nil {
+		return s
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, a := range n.Attr {
+				if (n.Data == "img" && a.Key == "src") || (n.Data == "video" && a.Key == "poster") {
+					n.Attr[i].Val = p.url(a.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	var b strings.Builder
+	for _, n := range ns {
+		walk(n)
+		if err := html.Render(&b, n); err != nil {
+			return s
+		}
+	}
+	return b.String()
+}
+
+// run removes images not served in imageCacheFor, hourly until ctx is done.
+func (p *imageProxy) run(ctx context.Context) {
+	if p.dir == "" {
+		return
+	}
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		p.mu.Lock()
+		for k, name := range p.files {
+			fn := filepath.Join(p.dir, name)
+			fi, err := os.Stat(fn)
+			if err == nil && time.Since(fi.ModTime()) < imageCacheFor {
+				continue
+			}
+			if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+				logger.Warn("remove cached image", "file", name, "error", err)
+				continue
+			}
+			delete(p.files, k)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// ServeHTTP serves proxied images, from the cache if possible.
+func (p *imageProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/images/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal([]byte(parts[0]), []byte(p.sign(string(b)))) {
+		imageProxied.Add(1, "forbidden")
+		http.Error(w, "bad signature", http.StatusForbidden)
+		return
+	}
+	u := string(b)
+
+	w.Header().Set("Cache-Control", "public, max-age=2592000, immutable")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	h := sha256.Sum256(b)
+	k := hex.EncodeToString(h[:12])
+	p.mu.RLock()
+	name, ok := p.files[k]
+	p.mu.RUnlock()
+	if ok {
+		fn := filepath.Join(p.dir, name)
+		now := time.Now()
+		os.Chtimes(fn, now, now)
+		imageProxied.Add(1, "hit")
+		http.ServeFile(w, r, fn)
+		return
+	}
+
+	img, ct, err := p.fetch(r.Context(), u)
+	if err != nil {
+		imageProxied.Add(1, "error")
+		logFrom(r.Context()).Debug("proxy image", "url", u, "error", err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+		return
+	}
+	imageProxied.Add(1, "miss")
+	if p.dir != "" {
+		if err := p.cache(k+imageTypes[ct], img); err != nil {
+			logger.Warn("cache image", "url", u, "error", err)
+		}
+	}
+	w.Header().Set("Content-Type", ct)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img))
+}
+
+// fetch gets the image at u, checking it is an allowed type within the size limit.
+func (p *imageProxy) fetch(ctx context.Context, u string) ([]byte, string, error) {
+	ctx, sp := startSpan(ctx, "proxy image", "url", u)
+	sp.kind = spanClient
+	defer sp.End()
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "readss")
+	req.Header.Set("Accept", "image/avif,image/webp,image/*;q=0.8")
+	res, err := imageClient.Do(req.WithContext(ctx))
+	if err != nil {
+		sp.SetError(err)
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	sp.SetAttributes("http.status_code", res.StatusCode)
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("status %v", res.Status)
+		sp.SetError(err)
+		return nil, "", err
+	}
+	ct, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if _, ok := imageTypes[ct]; !ok {
+		err = fmt.Errorf("content type %v", res.Header.Get("Content-Type"))
+		sp.SetError(err)
+		return nil, "", err
+	}
+	if res.ContentLength > imageMaxBytes {
+		err = fmt.Errorf("over %v bytes", imageMaxBytes)
+		sp.SetError(err)
+		return nil, "", err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, imageMaxBytes+1))
+	if err == nil && int64(len(b)) > imageMaxBytes {
+		err = fmt.Errorf("over %v bytes", imageMaxBytes)
+	}
+	// don't trust the declared type for things that look like documents
+	if sniffed := http.DetectContentType(b); err == nil && !strings.HasPrefix(sniffed, "image/") && sniffed != "application/octet-stream" {
+		err = fmt.Errorf("content looks like %v", sniffed)
+	}
+	if err != nil {
+		sp.SetError(err)
+		return nil, "", err
+	}
+	sp.SetAttributes("bytes", len(b))
+	return b, ct, nil
+}
+
+// cache writes an image to dir.
+func (p *imageProxy) cache(name string, b []byte) error {
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+	fn := filepath.Join(p.dir, name)
+	if err := ioutil.WriteFile(fn+".tmp", b, 0644); err != nil {
+		os.Remove(fn + ".tmp")
+		return err
+	}
+	if err := os.Rename(fn+".tmp", fn); err != nil {
+		os.Remove(fn + ".tmp")
+		return err
+	}
+	p.mu.Lock()
+	p.files[strings.TrimSuffix(name, filepath.Ext(name))] = name
+	p.mu.Unlock()
+	return nil
+}
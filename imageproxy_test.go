@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testPNG = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89"
+
+func TestPublicOnly(t *testing.T) {
+	tcs := []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"172.31.255.255:80", false},
+		{"172.32.0.1:80", true},
+		{"192.168.1.1:80", false},
+		{"100.64.0.1:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"224.0.0.1:80", false},
+		{"example.com:80", false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.address, func(t *testing.T) {
+			err := publicOnly("tcp", tc.address, nil)
+			if (err == nil) != tc.ok {
+				t.Errorf("publicOnly(%q) = %v, want ok %v", tc.address, err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestImageClientLoopback(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+	if _, err := imageClient.Get(ts.URL); err == nil {
+		t.Error("imageClient connected to a loopback server")
+	}
+	if hits != 0 {
+		t.Errorf("loopback server got %d requests, want none", hits)
+	}
+}
+
+func TestImageProxyURL(t *testing.T) {
+	defer func(old string) { PublicURL = old }(PublicURL)
+	PublicURL = "https://readss.example/"
+
+	p := newImageProxy("key", "")
+	u := "https://example.com/a.png"
+	got := p.url(u)
+	want := "https://readss.example/images/" + p.sign(u) + "/" + base64.RawURLEncoding.EncodeToString([]byte(u))
+	if got != want {
+		t.Errorf("url(%q) = %q, want %q", u, got, want)
+	}
+	if p.sign(u) == newImageProxy("other", "").sign(u) {
+		t.Error("signatures with different keys are equal")
+	}
+	if p.sign(u) == p.sign(u+"?") {
+		t.Error("signatures of different urls are equal")
+	}
+	for _, u := range []string{"data:image/png;base64,AAAA", "/local.png", "https://readss.example/images/x/y"} {
+		if got := p.url(u); got != u {
+			t.Errorf("url(%q) = %q, want unchanged", u, got)
+		}
+	}
+	var nilp *imageProxy
+	if got := nilp.url(u); got != u {
+		t.Errorf("nil url(%q) = %q, want unchanged", u, got)
+	}
+}
+
+func TestImageProxyServe(t *testing.T) {
+	defer func(c *http.Client, n int64) { imageClient, imageMaxBytes = c, n }(imageClient, imageMaxBytes)
+	imageMaxBytes = 1 << 10
+
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/a.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testPNG))
+		case "/svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(testPNG))
+		case "/disguised":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<!DOCTYPE html><html><script>alert(1)</script></html>"))
+		case "/big":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testPNG + strings.Repeat("x", 2<<10)))
+		case "/big-chunked":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testPNG))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 2<<10)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	imageClient = ts.Client()
+
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := newImageProxy("key", dir)
+	path := func(u string) string {
+		return "/images/" + p.sign(u) + "/" + base64.RawURLEncoding.EncodeToString([]byte(u))
+	}
+	b64 := func(u string) string { return base64.RawURLEncoding.EncodeToString([]byte(u)) }
+
+	tcs := []struct {
+		name   string
+		path   string
+		status int
+		ct     string
+	}{
+		{name: "image", path: path(ts.URL + "/a.png"), status: http.StatusOK, ct: "image/png"},
+		{name: "cached image", path: path(ts.URL + "/a.png"), status: http.StatusOK, ct: "image/png"},
+		{name: "missing signature", path: "/images//" + b64(ts.URL+"/a.png"), status: http.StatusForbidden},
+		{name: "bad signature", path: "/images/" + p.sign(ts.URL+"/b.png") + "/" + b64(ts.URL+"/a.png"), status: http.StatusForbidden},
+		{name: "other key", path: "/images/" + newImageProxy("other", "").sign(ts.URL+"/a.png") + "/" + b64(ts.URL+"/a.png"), status: http.StatusForbidden},
+		{name: "bad base64", path: "/images/" + p.sign(ts.URL+"/a.png") + "/!!!", status: http.StatusForbidden},
+		{name: "no url", path: "/images/" + p.sign(ts.URL+"/a.png"), status: http.StatusNotFound},
+		{name: "svg", path: path(ts.URL + "/svg"), status: http.StatusBadGateway},
+		{name: "not image", path: path(ts.URL + "/html"), status: http.StatusBadGateway},
+		{name: "html declared as image", path: path(ts.URL + "/disguised"), status: http.StatusBadGateway},
+		{name: "oversized", path: path(ts.URL + "/big"), status: http.StatusBadGateway},
+		{name: "oversized chunked", path: path(ts.URL + "/big-chunked"), status: http.StatusBadGateway},
+		{name: "not found", path: path(ts.URL + "/missing"), status: http.StatusBadGateway},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if w.Code != tc.status {
+				t.Fatalf("status = %v, want %v: %s", w.Code, tc.status, w.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tc.ct {
+				t.Errorf("content type = %q, want %q", ct, tc.ct)
+			}
+			if w.Body.String() != testPNG {
+				t.Errorf("body = %q, want the image", w.Body.String())
+			}
+		})
+	}
+	// the cached image and bad requests are not fetched
+	if hits != 7 {
+		t.Errorf("fetched %d times, want 7", hits)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Level is the severity of a log line.
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses one of debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), n) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+var (
+	logLevel = int32(LevelInfo)
+	logMu    sync.Mutex
+	logOut   io.Writer = os.Stderr
+
+	// logger is the root logger, without any fields
+	logger = &Logger{}
+)
+
+func getLevel() Level  { return Level(atomic.LoadInt32(&logLevel)) }
+func setLevel(l Level) { atomic.StoreInt32(&logLevel, int32(l)) }
+
+func init() {
+	// route anything using the standard logger, like net/http, through ours
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+}
+
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	logger.Info(string(bytes.TrimSpace(p)))
+	return len(p), nil
+}
+
+// Logger writes json lines with a set of fields attached.
+// Fields are alternating keys and values.
+// Commonly used keys are source, url, duration and error.
+type Logger struct {
+	fields []interface{}
+}
+
+// With returns a Logger that adds kvs to every line.
+func (l *Logger) With(kvs ...interface{}) *Logger {
+	fs := make([]interface{}, 0, len(l.fields)+len(kvs))
+	fs = append(fs, l.fields...)
+	fs = append(fs, kvs...)
+	return &Logger{fs}
+}
+
+func (l *Logger) Debug(msg string, kvs ...interface{}) { l.log(LevelDebug, msg, kvs) }
+func (l *Logger) Info(msg string, kvs ...interface{})  { l.log(LevelInfo, msg, kvs) }
+func (l *Logger) Warn(msg string, kvs ...interface{})  { l.log(LevelWarn, msg, kvs) }
+func (l *Logger) Error(msg string, kvs ...interface{}) { l.log(LevelError, msg, kvs) }
+
+func (l *Logger) log(lvl Level, msg string, kvs []interface{}) {
+	if lvl < getLevel() {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, lvl.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for _, fs := range [][]interface{}{l.fields, kvs} {
+		for i := 0; i < len(fs); i += 2 {
+			b.WriteByte(',')
+			writeJSON(&b, fmt.Sprint(fs[i]))
+			b.WriteByte(':')
+			if i+1 < len(fs) {
+				writeJSON(&b, fs[i+1])
+			} else {
+				b.WriteString(`"MISSING"`)
+			}
+		}
+	}
+	b.WriteString("}\n")
+
+	logMu.Lock()
+	logOut.Write(b.Bytes())
+	logMu.Unlock()
+}
+
+// writeJSON encodes v, with errors and stringers as strings
+// and durations as seconds.
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	switch vv := v.(type) {
+	case nil:
+		b.WriteString("null")
+		return
+	case error:
+		v = vv.Error()
+	case time.Duration:
+		v = vv.Seconds()
+	case time.Time:
+		v = vv.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		v = vv.String()
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		buf, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(buf)
+}
+
+type loggerKey struct{}
+
+func withLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// logFrom returns the request scoped logger in ctx, or the root logger.
+func logFrom(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// logInterceptor attaches a logger with request scoped fields to the context
+// and logs the outcome of every rpc.
+func logInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	l := logger.With("request_id", hex.EncodeToString(id), "method", info.FullMethod)
+	if p, ok := peer.FromContext(ctx); ok {
+		l = l.With("peer", p.Addr.String())
+	}
+	if r, ok := req.(interface{ GetUser() string }); ok {
+		l = l.With("user", r.GetUser())
+	}
+	if sp := spanFrom(ctx); sp != nil {
+		l = l.With("trace_id", sp.TraceID())
+	}
+
+	t := time.Now()
+	res, err := handler(withLogger(ctx, l), req)
+	kvs := []interface{}{"code", status.Code(err).String(), "duration", time.Since(t)}
+	if err != nil {
+		l.Warn("rpc", append(kvs, "error", err)...)
+	} else {
+		l.Info("rpc", kvs...)
+	}
+	return res, err
+}
+
+// logLevelHandler reports the log level on GET
+// and changes it on PUT or POST with a level form value.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		lvl, err := ParseLevel(r.FormValue("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		old := getLevel()
+		setLevel(lvl)
+		logger.Info("log level changed", "from", old, "to", lvl)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fmt.Fprintln(w, getLevel())
+}
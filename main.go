@@ -1,51 +1,162 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
-	"log"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"github.com/mmcdole/gofeed"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	"seankhliao.com/readss/readss"
 )
 
 var (
 	// grpc stuff
-	Debug   = false
 	Headers = []string{"*"}
 	Origins = make(map[string]struct{})
 	Port    = os.Getenv("PORT")
 
+	// GrpcPort serves native grpc, for clients such as readss tui
+	GrpcPort = os.Getenv("GRPC_PORT")
+
+	// admin stuff
+	AdminPort = os.Getenv("ADMIN_PORT")
+
+	// ShutdownTimeout is how long to wait for in flight requests on exit
+	ShutdownTimeout = 30 * time.Second
+
+	// tracing, spans are written to stdout if no endpoint is set
+	OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	ServiceName  = os.Getenv("OTEL_SERVICE_NAME")
+	TraceFlush   = 5 * time.Second
+
 	// service stuff
-	Config = os.Getenv("CONFIG")
-	Tick   = 30 * time.Minute
+	Config    = os.Getenv("CONFIG")
+	Tick      = 30 * time.Minute
+	Filters   = os.Getenv("FILTERS")
+	StoreFile = os.Getenv("STORE")
+	Retain    = 30 * 24 * time.Hour
+
+	// url canonicalization
+	TrackingParams   = []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "igshid", "_hsenc", "_hsmi", "mkt_tok", "ref_src"}
+	ResolveRedirects = false
+
+	// ranking
+	Rank       = os.Getenv("RANK")
+	RankCap    = 5
+	RankWindow = 24 * time.Hour
+
+	// clustering
+	ClusterThreshold = 0.5
+	ClusterWindow    = 48 * time.Hour
+
+	// Accounts log in as users other than the anonymous user
+	Accounts = os.Getenv("ACCOUNTS")
+
+	// digests, DigestState defaults to next to the store
+	Digests         = os.Getenv("DIGESTS")
+	DigestState     = os.Getenv("DIGEST_STATE")
+	DigestTemplates = os.Getenv("DIGEST_TEMPLATES")
+	SMTPAddr        = os.Getenv("SMTP_ADDR")
+	SMTPUser        = os.Getenv("SMTP_USER")
+	SMTPPassword    = os.Getenv("SMTP_PASSWORD")
+	SMTPFrom        = os.Getenv("SMTP_FROM")
+
+	// webhooks, WebhookDeadLetter defaults to next to the store
+	Webhooks          = os.Getenv("WEBHOOKS")
+	WebhookDeadLetter = os.Getenv("WEBHOOK_DEADLETTER")
+	WebhookRetries    = 3
+
+	// websub, WebSubCallback is the public url of the grpc-web port,
+	// hubs push to WebSubCallback/websub/
+	WebSubCallback = os.Getenv("WEBSUB_CALLBACK")
+	WebSubLease    = 10 * 24 * time.Hour
+
+	// client apps, ReadState defaults to next to the store
+	ReadState = os.Getenv("READ_STATE")
+
+	// PublicURL is the url of the grpc-web port as seen by clients,
+	// for links to files served by readss
+	PublicURL = os.Getenv("PUBLIC_URL")
+
+	// podcasts, PodcastDir defaults to next to the store,
+	// downloads over PodcastMaxBytes or PodcastTimeout are abandoned
+	PodcastDir            = os.Getenv("PODCAST_DIR")
+	PodcastMaxBytes int64 = 1 << 30
+	PodcastTimeout        = 30 * time.Minute
+
+	// page snapshots, ArchiveDir defaults to next to the store
+	ArchiveDir = os.Getenv("ARCHIVE_DIR")
+
+	// feed icons, IconDir defaults to next to the store
+	IconDir = os.Getenv("ICON_DIR")
+
+	// image proxy, enabled by ImageProxyKey to sign urls with,
+	// ImageCacheDir defaults to next to the store
+	ImageProxyKey = os.Getenv("IMAGE_PROXY_KEY")
+	ImageCacheDir = os.Getenv("IMAGE_CACHE_DIR")
 )
 
 func init() {
-	// grpc stuff
+	// logging
 	if os.Getenv("DEBUG") == "1" {
-		Debug = true
+		setLevel(LevelDebug)
+	}
+	if s := os.Getenv("LOG_LEVEL"); s != "" {
+		lvl, err := ParseLevel(s)
+		if err != nil {
+			logger.Warn("parse LOG_LEVEL", "error", err)
+		} else {
+			setLevel(lvl)
+		}
 	}
 
+	// grpc stuff
+
 	for _, o := range strings.Split(os.Getenv("ORIGINS"), ",") {
 		Origins[strings.TrimSpace(o)] = struct{}{}
 	}
 
-	if Port == "" {
-		Port = ":8080"
+	Port = listenAddr(Port, ":8080")
+	GrpcPort = listenAddr(GrpcPort, ":8081")
+
+	// admin stuff
+	AdminPort = listenAddr(AdminPort, ":8090")
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+		ShutdownTimeout = d
+	}
+
+	// tracing
+	if ServiceName == "" {
+		ServiceName = "readss"
 	}
-	if Port[0] != ':' {
-		Port = ":" + Port
+	if d, err := time.ParseDuration(os.Getenv("TRACE_FLUSH")); err == nil && d > 0 {
+		TraceFlush = d
 	}
 
 	// service stuff
@@ -56,140 +167,1035 @@ func init() {
 	if d, err := time.ParseDuration(os.Getenv("TICK")); err == nil {
 		Tick = d
 	}
+	if d, err := time.ParseDuration(os.Getenv("RETAIN")); err == nil {
+		Retain = d
+	}
+
+	// url canonicalization
+	if tp := os.Getenv("TRACKING_PARAMS"); tp != "" {
+		TrackingParams = nil
+		for _, p := range strings.Split(tp, ",") {
+			if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+				TrackingParams = append(TrackingParams, p)
+			}
+		}
+	}
+	if os.Getenv("RESOLVE_REDIRECTS") == "1" {
+		ResolveRedirects = true
+	}
+
+	// ranking
+	switch Rank {
+	case "time", "cap", "roundrobin":
+	default:
+		if Rank != "" {
+			logger.Warn("unknown RANK, using time", "rank", Rank)
+		}
+		Rank = "time"
+	}
+	if n, err := strconv.Atoi(os.Getenv("RANK_CAP")); err == nil && n > 0 {
+		RankCap = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("RANK_WINDOW")); err == nil {
+		RankWindow = d
+	}
+
+	// clustering
+	if f, err := strconv.ParseFloat(os.Getenv("CLUSTER_THRESHOLD"), 64); err == nil {
+		ClusterThreshold = f
+	}
+	if d, err := time.ParseDuration(os.Getenv("CLUSTER_WINDOW")); err == nil {
+		ClusterWindow = d
+	}
+
+	// digests
+	if SMTPAddr == "" {
+		SMTPAddr = "localhost:25"
+	}
+	if SMTPFrom == "" {
+		SMTPFrom = "readss@localhost"
+	}
+
+	// webhooks
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRIES")); err == nil && n >= 0 {
+		WebhookRetries = n
+	}
+
+	// websub
+	if d, err := time.ParseDuration(os.Getenv("WEBSUB_LEASE")); err == nil && d > 0 {
+		WebSubLease = d
+	}
+
+	// podcasts
+	if n, err := strconv.ParseInt(os.Getenv("PODCAST_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		PodcastMaxBytes = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("PODCAST_TIMEOUT")); err == nil && d > 0 {
+		PodcastTimeout = d
+	}
 }
 
 func allowOrigin(o string) bool {
 	_, ok := Origins[o]
-	if Debug {
-		log.Printf("origin filter %v allowed: %v\n", o, ok)
-	}
+	logger.Debug("origin filter", "origin", o, "allowed", ok)
 	return ok
 }
 
+// listenAddr turns a port into an address to listen on, using def if it is empty.
+func listenAddr(p, def string) string {
+	if p == "" {
+		return def
+	}
+	if p[0] != ':' {
+		return ":" + p
+	}
+	return p
+}
+
 func main() {
-	svr := NewServer(Config, Tick)
-	gsvr := grpc.NewServer()
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	if cmd == "help" {
+		usage()
+		return
+	}
+	for _, c := range commands {
+		if c.name != cmd {
+			continue
+		}
+		if err := c.run(args); err != nil {
+			fmt.Fprintf(os.Stderr, "readss %v: %v\n", cmd, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "readss: unknown command %q\n\n", cmd)
+	usage()
+	os.Exit(2)
+}
+
+// serve runs the server until it receives SIGINT or SIGTERM.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFlags(fs)
+	fs.DurationVar(&Tick, "tick", Tick, "time between refreshes (TICK)")
+	fs.StringVar(&Port, "port", Port, "port to serve grpc-web on (PORT)")
+	fs.StringVar(&GrpcPort, "grpc-port", GrpcPort, "port to serve native grpc on (GRPC_PORT)")
+	fs.StringVar(&AdminPort, "admin-port", AdminPort, "port to serve metrics and health checks on (ADMIN_PORT)")
+	fs.StringVar(&StoreFile, "store", StoreFile, "file to persist articles in (STORE)")
+	fs.StringVar(&Accounts, "accounts", Accounts, "client app accounts csv file, enables the fever and google reader apis and logins for grpc users (ACCOUNTS)")
+	fs.StringVar(&Digests, "digests", Digests, "digest recipients csv file (DIGESTS)")
+	fs.StringVar(&Webhooks, "webhooks", Webhooks, "webhooks csv file (WEBHOOKS)")
+	fs.StringVar(&WebSubCallback, "websub-callback", WebSubCallback, "public url of this server to receive websub pushes on (WEBSUB_CALLBACK)")
+	fs.StringVar(&PublicURL, "public-url", PublicURL, "url clients reach the grpc-web port on (PUBLIC_URL)")
+	fs.StringVar(&PodcastDir, "podcast-dir", PodcastDir, "directory to download podcast episodes to (PODCAST_DIR)")
+	fs.Int64Var(&PodcastMaxBytes, "podcast-max-bytes", PodcastMaxBytes, "largest podcast episode to download (PODCAST_MAX_BYTES)")
+	fs.DurationVar(&PodcastTimeout, "podcast-timeout", PodcastTimeout, "time limit for downloading a podcast episode (PODCAST_TIMEOUT)")
+	fs.StringVar(&ArchiveDir, "archive-dir", ArchiveDir, "directory to keep snapshots of archived pages in (ARCHIVE_DIR)")
+	fs.StringVar(&IconDir, "icon-dir", IconDir, "directory to cache feed icons in (ICON_DIR)")
+	fs.StringVar(&ImageCacheDir, "image-cache-dir", ImageCacheDir, "directory to cache proxied images in (IMAGE_CACHE_DIR)")
+	fs.Parse(args)
+	Port = listenAddr(Port, ":8080")
+	GrpcPort = listenAddr(GrpcPort, ":8081")
+	AdminPort = listenAddr(AdminPort, ":8090")
+
+	if PodcastDir == "" && StoreFile != "" {
+		PodcastDir = StoreFile + ".podcasts"
+	}
+	if PodcastDir != "" {
+		podcasts = newPodcaster(PodcastDir)
+	}
+	if ArchiveDir == "" && StoreFile != "" {
+		ArchiveDir = StoreFile + ".archive"
+	}
+	if ArchiveDir != "" {
+		archives = newArchiver(ArchiveDir)
+	}
+	if IconDir == "" && StoreFile != "" {
+		IconDir = StoreFile + ".icons"
+	}
+	if IconDir != "" {
+		icons = newIconCache(IconDir)
+	}
+	if ImageProxyKey != "" {
+		if ImageCacheDir == "" && StoreFile != "" {
+			ImageCacheDir = StoreFile + ".images"
+		}
+		images = newImageProxy(ImageProxyKey, ImageCacheDir)
+	}
+	svr := NewServer(Config, Tick, NewStore(StoreFile))
+	if Accounts != "" {
+		if ReadState == "" && StoreFile != "" {
+			ReadState = StoreFile + ".read"
+		}
+		svr.state = loadReadState(ReadState)
+	}
+	if WebSubCallback != "" {
+		websub = newWebSub(strings.TrimSuffix(WebSubCallback, "/")+"/websub/", WebSubLease, svr.ingest)
+	}
+	gsvr := grpc.NewServer(grpc.UnaryInterceptor(chainUnary(traceInterceptor, logInterceptor, metricsInterceptor, svr.authInterceptor)))
 	readss.RegisterListerServer(gsvr, svr)
+	healthpb.RegisterHealthServer(gsvr, svr.health)
 	wsvr := grpcweb.WrapServer(gsvr,
 		grpcweb.WithOriginFunc(allowOrigin),
 		grpcweb.WithAllowedRequestHeaders(Headers),
 	)
 
-	if Debug {
-		log.Printf("read config at %v, ticking at %v\n", Config, Tick)
-		log.Printf("starting on %v\nallowing headers: %v\nallowing origins: %v\n",
-			Port, Headers, Origins)
+	logger.Info("starting",
+		"port", Port,
+		"grpc_port", GrpcPort,
+		"admin_port", AdminPort,
+		"config", Config,
+		"tick", Tick,
+		"digests", Digests,
+		"webhooks", Webhooks,
+		"websub_callback", WebSubCallback,
+		"public_url", PublicURL,
+		"podcast_dir", PodcastDir,
+		"podcast_max_bytes", PodcastMaxBytes,
+		"podcast_timeout", PodcastTimeout,
+		"archive_dir", ArchiveDir,
+		"icon_dir", IconDir,
+		"image_proxy", images != nil,
+		"image_cache_dir", ImageCacheDir,
+		"log_level", getLevel(),
+		"otlp_endpoint", OTLPEndpoint,
+	)
+	logger.Debug("settings",
+		"filters", Filters,
+		"accounts", Accounts,
+		"store", StoreFile,
+		"retain", Retain,
+		"rank", Rank,
+		"rank_cap", RankCap,
+		"rank_window", RankWindow,
+		"cluster_threshold", ClusterThreshold,
+		"cluster_window", ClusterWindow,
+		"tracking_params", TrackingParams,
+		"resolve_redirects", ResolveRedirects,
+		"headers", Headers,
+		"origins", Origins,
+		"shutdown_timeout", ShutdownTimeout,
+		"webhook_retries", WebhookRetries,
+		"websub_lease", WebSubLease,
+	)
+
+	tctx, tcancel := context.WithCancel(context.Background())
+	tdone := make(chan struct{})
+	go func() {
+		tracer.run(tctx, newExporter(OTLPEndpoint), TraceFlush)
+		close(tdone)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svr.Run(ctx)
+		close(done)
+	}()
+	if websub != nil {
+		go websub.run(ctx)
+	}
+	if podcasts != nil {
+		go podcasts.run(ctx, svr)
+	}
+	if archives != nil {
+		go archives.run(ctx)
+	}
+	if icons != nil {
+		go icons.run(ctx)
+	}
+	if images != nil {
+		go images.run(ctx)
+	}
+	if Digests != "" {
+		if DigestState == "" && StoreFile != "" {
+			DigestState = StoreFile + ".digests"
+		}
+		go newDigester(svr, DigestState).run(ctx)
+	}
+	if WebhookDeadLetter == "" && StoreFile != "" {
+		WebhookDeadLetter = StoreFile + ".deadletter"
+	}
+
+	amux := http.NewServeMux()
+	amux.HandleFunc("/metrics", metricsHandler)
+	amux.HandleFunc("/healthz", healthz)
+	amux.HandleFunc("/readyz", svr.readyz)
+	amux.HandleFunc("/loglevel", logLevelHandler)
+	asvr := &http.Server{
+		Addr:    AdminPort,
+		Handler: amux,
+	}
+	hsvr := &http.Server{
+		Addr: Port,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if websub != nil && strings.HasPrefix(r.URL.Path, "/websub/") {
+				websub.ServeHTTP(w, r)
+				return
+			}
+			if svr.state != nil && strings.HasPrefix(r.URL.Path, "/fever/") {
+				svr.fever(w, r)
+				return
+			}
+			if svr.state != nil && strings.HasPrefix(r.URL.Path, "/greader/") {
+				svr.greader(w, r)
+				return
+			}
+			if podcasts != nil && strings.HasPrefix(r.URL.Path, "/podcasts/") {
+				podcasts.ServeHTTP(w, r)
+				return
+			}
+			if archives != nil && strings.HasPrefix(r.URL.Path, "/archive/") {
+				archives.ServeHTTP(w, r)
+				return
+			}
+			if icons != nil && strings.HasPrefix(r.URL.Path, "/icons/") {
+				icons.ServeHTTP(w, r)
+				return
+			}
+			if images != nil && strings.HasPrefix(r.URL.Path, "/images/") {
+				images.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Cache-Control", "max-age=600")
+			wsvr.ServeHTTP(w, r)
+		}),
+	}
+
+	errc := make(chan error, 3)
+	for _, hs := range []*http.Server{asvr, hsvr} {
+		go func(hs *http.Server) {
+			if err := hs.ListenAndServe(); err != http.ErrServerClosed {
+				errc <- fmt.Errorf("serve %v: %v", hs.Addr, err)
+			}
+		}(hs)
 	}
-	http.ListenAndServe(Port, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "max-age=600")
-		wsvr.ServeHTTP(w, r)
-	}))
+	go func() {
+		lis, err := net.Listen("tcp", GrpcPort)
+		if err == nil {
+			err = gsvr.Serve(lis)
+		}
+		if err != nil {
+			errc <- fmt.Errorf("serve %v: %v", GrpcPort, err)
+		}
+	}()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	var err error
+	select {
+	case sig := <-sigc:
+		logger.Info("shutting down", "signal", sig)
+	case err = <-errc:
+		logger.Error("shutting down", "error", err)
+	}
+	// fail health checks first so load balancers stop sending requests while draining
+	svr.setDraining()
+
+	// drain http, then stop refreshing and flush the store
+	sctx, scancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer scancel()
+	for _, hs := range []*http.Server{hsvr, asvr} {
+		if err := hs.Shutdown(sctx); err != nil {
+			logger.Error("shutdown", "addr", hs.Addr, "error", err)
+		}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		gsvr.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-sctx.Done():
+		logger.Error("shutdown", "addr", GrpcPort, "error", sctx.Err())
+		gsvr.Stop()
+	}
+	cancel()
+	<-done
+	tcancel()
+	<-tdone
+	return err
 }
 
 type Server struct {
-	ats  []*readss.Article
-	fn   string
-	tick time.Duration
+	mu    sync.RWMutex
+	ready bool
+	// draining is set on shutdown, the server is never ready again
+	draining bool
+	health   *health.Server
+	lists    map[string]*readss.ListReply
+	users    map[string][]Sub
+	rules    Rules
+	// accounts are the Accounts by login
+	accounts map[string]Account
+	store    *Store
+	fn       string
+	tick     time.Duration
+	// feeds are the entries of each feed from the last refresh and pushes since
+	feeds map[string][]*Entry
+	// refreshed is when the last refresh finished
+	refreshed time.Time
+	// state is what client apps have read and saved, nil if there are no Accounts
+	state *readState
+	// deliveries are webhooks still being sent
+	deliveries sync.WaitGroup
 }
 
-func NewServer(fn string, tick time.Duration) *Server {
+func NewServer(fn string, tick time.Duration, store *Store) *Server {
 	svr := &Server{
-		fn:   fn,
-		tick: tick,
+		health: newHealth(),
+		store:  store,
+		fn:     fn,
+		tick:   tick,
 	}
-	go svr.updater()
+	svr.warm()
 	return svr
 }
 
-func (s *Server) List(context.Context, *readss.ListRequest) (*readss.ListReply, error) {
+// warm serves the articles in the store until the first refresh completes.
+func (s *Server) warm() {
+	s.loadAccounts()
+	users := parseUsers(s.fn)
+	feeds := s.store.Feeds()
+	if users == nil || len(feeds) == 0 {
+		return
+	}
+	rules := parseRules(Filters)
+	lists := userArticles(users, feeds, rules)
+
+	s.mu.Lock()
+	s.users = users
+	s.rules = rules
+	s.lists = lists
+	s.feeds = feeds
+	s.mu.Unlock()
+	s.setReady()
+}
+
+// subs returns the user an rpc is served as, their subscriptions and the filter rules.
+func (s *Server) subs(ctx context.Context, requested string) (string, []Sub, Rules, error) {
+	user, err := rpcUser(ctx, requested)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	s.mu.RLock()
+	subs, ok := s.users[user]
+	rules, loaded := s.rules, s.users != nil
+	s.mu.RUnlock()
+	if !ok && !loaded {
+		// users in the config have nothing until the first refresh
+		files, _ := userFiles(s.fn)
+		_, ok = files[user]
+	}
+	if !ok {
+		return "", nil, nil, status.Errorf(codes.NotFound, "unknown user %q", user)
+	}
+	return user, subs, rules, nil
+}
+
+func (s *Server) List(ctx context.Context, r *readss.ListRequest) (*readss.ListReply, error) {
+	user, _, _, err := s.subs(ctx, r.User)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	l, ok := s.lists[user]
+	s.mu.RUnlock()
+	if !ok {
+		return &readss.ListReply{}, nil
+	}
 	return &readss.ListReply{
-		Articles: s.ats,
+		Articles: l.Articles,
+		Clusters: l.Clusters,
+	}, nil
+}
+
+func (s *Server) Search(ctx context.Context, r *readss.SearchRequest) (*readss.SearchReply, error) {
+	_, subs, rules, err := s.subs(ctx, r.User)
+	if err != nil {
+		return nil, err
+	}
+	q, err := ParseQuery(r.Query)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parse query: %v", err)
+	}
+	rs := s.store.Search(q, subs, rules)
+	logFrom(ctx).Debug("search", "query", r.Query, "results", len(rs))
+	return &readss.SearchReply{
+		Results: rs,
 	}, nil
 }
 
-func (s *Server) updater() {
-	s.ats = getArticles(parseSubs(s.fn))
-	for range time.NewTicker(s.tick).C {
-		s.ats = getArticles(parseSubs(s.fn))
+func (s *Server) GetArticle(ctx context.Context, r *readss.GetArticleRequest) (*readss.GetArticleReply, error) {
+	_, subs, rules, err := s.subs(ctx, r.User)
+	if err != nil {
+		return nil, err
+	}
+	e := s.store.Get(r.Id)
+	if e == nil {
+		return nil, status.Errorf(codes.NotFound, "unknown article %q", r.Id)
 	}
+	for _, sub := range subs {
+		if sub.feed() != e.Feed {
+			continue
+		}
+		v := rules.Apply(e, sub)
+		if v.Drop {
+			break
+		}
+		at := e.Article(sub)
+		at.Tags = append(at.Tags, v.Tags...)
+		return &readss.GetArticleReply{
+			Article: at,
+			Content: e.body(),
+		}, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "unknown article %q", r.Id)
+}
+
+// Run refreshes articles every tick until ctx is done,
+// then waits for webhook deliveries and saves the store.
+func (s *Server) Run(ctx context.Context) {
+	defer func() {
+		s.deliveries.Wait()
+		if err := s.store.Save(); err != nil {
+			logger.Error("save store", "file", s.store.fn, "error", err)
+		}
+	}()
+
+	s.update(ctx)
+	t := time.NewTicker(s.tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.update(ctx)
+		}
+	}
+}
+
+func (s *Server) update(ctx context.Context) {
+	t := time.Now()
+	defer func() { refreshDuration.Observe(time.Since(t).Seconds()) }()
+	ctx, sp := startSpan(ctx, "refresh")
+	defer sp.End()
+	logger.Debug("refresh started", "trace_id", sp.TraceID())
+
+	s.loadAccounts()
+	users := parseUsers(s.fn)
+	if users == nil {
+		logger.Warn("no config loaded, keeping previous articles", "config", s.fn)
+		return
+	}
+	rules := parseRules(Filters)
+	feeds := fetchEntries(ctx, users)
+	if ResolveRedirects {
+		resolveEntries(ctx, feeds, s.store)
+	}
+	extractEntries(ctx, users, feeds, s.store)
+	if ctx.Err() != nil {
+		sp.SetError(ctx.Err())
+		logger.Warn("discarding partial refresh", "duration", time.Since(t), "error", ctx.Err())
+		return
+	}
+	lists := userArticles(users, feeds, rules)
+
+	s.mu.Lock()
+	s.users = users
+	s.rules = rules
+	s.lists = lists
+	s.feeds = feeds
+	s.refreshed = time.Now()
+	s.mu.Unlock()
+	s.setReady()
+	websub.keep(feeds)
+
+	// the first refresh into an empty store has nothing to compare against
+	baseline := s.store.Len() == 0
+	cutoff := time.Now().Add(-Retain)
+	var added []*Entry
+	for _, es := range feeds {
+		added = append(added, s.add(es, cutoff)...)
+	}
+	s.store.Prune(cutoff)
+	archives.prune(s.store)
+	storeArticles.Set(float64(s.store.Len()))
+	if err := s.store.Save(); err != nil {
+		logger.Error("save store", "file", s.store.fn, "error", err)
+	}
+	if s.state != nil {
+		if err := s.state.save(s.store); err != nil {
+			logger.Error("save read state", "file", s.state.fn, "error", err)
+		}
+	}
+	archives.queue(users, append(added, archives.retries(s.store)...))
+	if Webhooks != "" && !baseline && len(added) > 0 {
+		s.notifyWebhooks(ctx, added, users, rules)
+	}
+	sp.SetAttributes("feeds", len(feeds), "stored", s.store.Len(), "added", len(added))
+	logger.Info("refresh finished", "duration", time.Since(t), "feeds", len(feeds), "stored", s.store.Len(), "added", len(added), "trace_id", sp.TraceID())
+}
+
+// add stores es, returning the entries no previous refresh has seen.
+// Entries older than cutoff are pruned and would be seen again every refresh,
+// so are never new.
+func (s *Server) add(es []*Entry, cutoff time.Time) []*Entry {
+	var added []*Entry
+	for _, e := range s.store.Add(es) {
+		if e.Time.After(cutoff) {
+			added = append(added, e)
+		}
+	}
+	return added
 }
 
 type Sub struct {
-	Name     string
-	URL      string
-	Articles []*readss.Article
+	Name    string
+	URL     string
+	Tags    []string
+	Weight  int
+	Extract bool
+	// Download keeps the newest Download episodes, or those published within DownloadFor,
+	// in PodcastDir
+	Download    int
+	DownloadFor time.Duration
+	// Archive snapshots linked pages into ArchiveDir as html or warc
+	Archive string
+	// Header is sent when fetching the feed, for private feeds
+	Header http.Header
+}
+
+func (s Sub) weight() int {
+	if s.Weight < 1 {
+		return 1
+	}
+	return s.Weight
+}
+
+// parseUsers reads the subscriptions for every user.
+// If fn is a directory, each <user>.csv in it holds the subscriptions of that user,
+// otherwise fn is a single csv file owned by the anonymous user "".
+func parseUsers(fn string) map[string][]Sub {
+	files, err := userFiles(fn)
+	if err != nil {
+		logger.Error("read config", "file", fn, "error", err)
+		return nil
+	}
+	users := make(map[string][]Sub, len(files))
+	for user, f := range files {
+		users[user] = parseSubs(f)
+	}
+	return users
 }
 
+// userFiles maps users to their subscription files, as described by parseUsers.
+func userFiles(fn string) (map[string]string, error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return map[string]string{"": fn}, nil
+	}
+
+	fns, err := filepath.Glob(filepath.Join(fn, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(fns))
+	for _, f := range fns {
+		files[strings.TrimSuffix(filepath.Base(f), ".csv")] = f
+	}
+	return files, nil
+}
+
+// parseSubs reads a csv file of subscriptions,
+// each record is: name, url, followed by optional key=value options:
+//
+//	tags=space separated list of tags
+//	weight=relative share of the list when ranking by cap or roundrobin
+//	extract=1 to fetch and extract the full content of linked pages
+//	download=number of podcast episodes, or duration, to keep downloaded
+//	archive=html or warc to snapshot linked pages when first seen
+//
+// Private feeds can be fetched with credentials,
+// secrets are read from an environment variable (_env) or a file (_file):
+//
+//	user=user name for basic auth, with password_env or password_file
+//	token_env, token_file=bearer token
+//	cookie_env, cookie_file=cookie header value
+//	header=Name: value, an extra request header, may be repeated
+//	header_env, header_file=Name: variable or file holding the header value
 func parseSubs(fn string) []Sub {
+	subs, errs := readSubs(fn)
+	for _, err := range errs {
+		logger.Warn("parse subscriptions", "file", fn, "error", err)
+	}
+	return subs
+}
+
+// readSubs is parseSubs, returning the problems it skipped.
+func readSubs(fn string) ([]Sub, []error) {
 	f, err := os.Open(fn)
 	if err != nil {
-		log.Printf("parseSubs open %v: %v\n", fn, err)
-		return nil
+		return nil, []error{err}
 	}
 	defer f.Close()
 
-	rr, err := csv.NewReader(f).ReadAll()
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rr, err := cr.ReadAll()
 	if err != nil {
-		log.Printf("parseSubs readall %v\n", err)
-		return nil
+		return nil, []error{err}
+	}
+
+	var errs []error
+	subs := make([]Sub, 0, len(rr))
+	for _, r := range rr {
+		if len(r) < 2 {
+			errs = append(errs, fmt.Errorf("short record %v", r))
+			continue
+		}
+		sub := Sub{
+			Name: r[0],
+			URL:  r[1],
+		}
+		var user, password string
+		secret := func(opt, ref string) string {
+			v, err := readSecret(opt, ref)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v: %v", sub.Name, err))
+			}
+			return v
+		}
+		for _, opt := range r[2:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				errs = append(errs, fmt.Errorf("%v: bad option %q", sub.Name, opt))
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "tags":
+				sub.Tags = strings.Fields(kv[1])
+			case "weight":
+				sub.Weight, err = strconv.Atoi(kv[1])
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%v: bad weight: %v", sub.Name, err))
+				}
+			case "extract":
+				sub.Extract, err = strconv.ParseBool(kv[1])
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%v: bad extract: %v", sub.Name, err))
+				}
+			case "download":
+				if n, err := strconv.Atoi(kv[1]); err == nil && n >= 0 {
+					sub.Download = n
+				} else if d, err := time.ParseDuration(kv[1]); err == nil && d >= 0 {
+					sub.DownloadFor = d
+				} else {
+					errs = append(errs, fmt.Errorf("%v: bad download %q", sub.Name, kv[1]))
+				}
+			case "archive":
+				switch kv[1] {
+				case "html", "warc", "":
+					sub.Archive = kv[1]
+				default:
+					errs = append(errs, fmt.Errorf("%v: bad archive %q", sub.Name, kv[1]))
+				}
+			case "user":
+				user = kv[1]
+			case "password_env", "password_file":
+				password = secret(kv[0], kv[1])
+			case "token_env", "token_file":
+				if token := secret(kv[0], kv[1]); token != "" {
+					sub.setHeader("Authorization", "Bearer "+token)
+				}
+			case "cookie_env", "cookie_file":
+				if cookie := secret(kv[0], kv[1]); cookie != "" {
+					sub.setHeader("Cookie", cookie)
+				}
+			case "header", "header_env", "header_file":
+				hkv := strings.SplitN(kv[1], ":", 2)
+				if len(hkv) != 2 || strings.TrimSpace(hkv[0]) == "" {
+					errs = append(errs, fmt.Errorf("%v: bad %v %q", sub.Name, kv[0], kv[1]))
+					continue
+				}
+				v := strings.TrimSpace(hkv[1])
+				if kv[0] != "header" {
+					v = secret(kv[0], v)
+				}
+				if v != "" {
+					sub.setHeader(strings.TrimSpace(hkv[0]), v)
+				}
+			default:
+				errs = append(errs, fmt.Errorf("%v: unknown option %q", sub.Name, kv[0]))
+			}
+		}
+		if user != "" || password != "" {
+			sub.setHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)))
+		}
+		subs = append(subs, sub)
 	}
+	return subs, errs
+}
 
-	subs := make([]Sub, len(rr))
-	for i := range subs {
-		subs[i] = Sub{
-			Name: rr[i][0],
-			URL:  rr[i][1],
+// feed identifies the feed of s in fetches and the store.
+// Feeds fetched with credentials are told apart by a hash of them,
+// in the fragment so it still reads as the url,
+// and never share entries with subscriptions using other or no credentials.
+func (s Sub) feed() string {
+	if s.Header == nil {
+		return s.URL
+	}
+	keys := make([]string, 0, len(s.Header))
+	for k := range s.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\x00%s\x00", k, strings.Join(s.Header[k], "\x00"))
+	}
+	return s.URL + "#readss-auth=" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// setHeader sets a header sent when fetching the feed.
+func (s *Sub) setHeader(k, v string) {
+	if s.Header == nil {
+		s.Header = make(http.Header)
+	}
+	s.Header.Set(k, v)
+}
+
+// readSecret reads the value of a _env or _file option,
+// from the environment variable or file named by ref.
+func readSecret(opt, ref string) (string, error) {
+	var v string
+	if strings.HasSuffix(opt, "_file") {
+		b, err := ioutil.ReadFile(ref)
+		if err != nil {
+			return "", err
 		}
+		v = strings.TrimSpace(string(b))
+	} else {
+		v = os.Getenv(ref)
 	}
-	return subs
+	if v == "" {
+		return "", fmt.Errorf("%v: %v is empty", opt, ref)
+	}
+	return v, nil
+}
+
+func getArticles(subs []Sub, rules Rules) []*readss.Article {
+	users := map[string][]Sub{"": subs}
+	return userArticles(users, fetchEntries(context.Background(), users), rules)[""].Articles
 }
 
-func getArticles(subs []Sub) []*readss.Article {
-	if Debug {
-		log.Printf("starting getArticles")
-		defer log.Printf("finsihed getArticles")
+// fetchEntries fetches every distinct feed once,
+// returning the entries keyed by Sub.feed.
+func fetchEntries(ctx context.Context, users map[string][]Sub) map[string][]*Entry {
+	feeds := make(map[string][]*Entry)
+	subs := make(map[string]Sub)
+	for _, us := range users {
+		for _, sub := range us {
+			feeds[sub.feed()] = nil
+			subs[sub.feed()] = sub
+		}
 	}
+
+	var mu sync.Mutex
 	var wg sync.WaitGroup
-	for s, sub := range subs {
+	for u := range subs {
 		wg.Add(1)
-		go func(s int, sub Sub) {
+		go func(u string) {
 			defer wg.Done()
 
-			feed, err := gofeed.NewParser().ParseURL(sub.URL)
+			t := time.Now()
+			sub := subs[u]
+			name, ru := sub.Name, redactURL(u)
+			ctx, sp := startSpan(ctx, "fetch", "source", name, "url", ru)
+			sp.kind = spanClient
+			defer sp.End()
+			feed, err := fetchFeed(ctx, sub)
 			if err != nil {
-				log.Printf("getSubs get feed %v: %v\n", sub.Name, err)
+				sp.SetError(err)
+				logger.Warn("fetch feed", "source", name, "url", ru, "duration", time.Since(t), "error", err)
 				return
 			}
-			ats := make([]*readss.Article, len(feed.Items))
-			for i, it := range feed.Items {
-				ts := it.PublishedParsed
-				if it.UpdatedParsed != nil {
-					ts = it.UpdatedParsed
-				}
-				ats[i] = &readss.Article{
-					Title:   it.Title,
-					Url:     it.Link,
-					Source:  sub.Name,
-					Time:    ts.Format("2006-01-02 15:04"),
-					Reltime: humanTime(*ts),
-				}
+			es := feedEntries(u, feed)
+			if sub.Header == nil {
+				// hubs can't fetch private feeds
+				websub.discover(u, feed)
 			}
-
-			subs[s].Articles = ats
-		}(s, sub)
+			icons.discover(u, feed)
+			sp.SetAttributes("items", len(es))
+			logger.Debug("fetched feed", "source", name, "url", ru, "duration", time.Since(t), "items", len(es))
+			mu.Lock()
+			feeds[u] = es
+			mu.Unlock()
+		}(u)
 	}
 	wg.Wait()
+	return feeds
+}
+
+var feedClient = &http.Client{Timeout: 60 * time.Second}
+
+// fetchFeed gets and parses the feed of sub, recording fetch metrics by its redacted url.
+// A WebSub hub and self link, and an atom icon, found in the feed are in feed.Custom.
+func fetchFeed(ctx context.Context, sub Sub) (*gofeed.Feed, error) {
+	u, h := sub.URL, sub.Header
+	label := redactURL(u)
+	t := time.Now()
+	defer func() { fetchDuration.Observe(time.Since(t).Seconds(), label) }()
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "readss")
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	res, err := feedClient.Do(req.WithContext(httptrace.WithClientTrace(ctx, clientTrace(ctx))))
+	if err != nil {
+		fetchStatus.Add(1, label, "error")
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = redactURL(ue.URL)
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+	fetchStatus.Add(1, label, strconv.Itoa(res.StatusCode))
+	spanFrom(ctx).SetAttributes("http.status_code", res.StatusCode)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("status %v", res.Status)
+	}
 
-	var ats []*readss.Article
-	for _, s := range subs {
-		ats = append(ats, s.Articles...)
+	// read fully before parsing so download and parse time are separate
+	_, sp := startSpan(ctx, "download")
+	cr := &countingReader{r: res.Body}
+	b, err := ioutil.ReadAll(cr)
+	fetchBytes.Add(float64(cr.n), label)
+	sp.SetAttributes("bytes", cr.n)
+	sp.SetError(err)
+	sp.End()
+	if err != nil {
+		return nil, err
 	}
-	sort.Sort(Articles(ats))
-	if len(ats) > 100 {
-		ats = ats[:100]
+
+	_, sp = startSpan(ctx, "parse")
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(b))
+	sp.SetError(err)
+	sp.End()
+	if err != nil {
+		return nil, err
+	}
+	if hub, self := feedLinks(u, res.Header, b); hub != "" {
+		feed.Custom = map[string]string{"hub": hub, "self": self}
+	}
+	if icon := feedIcon(u, b); icon != "" {
+		if feed.Custom == nil {
+			feed.Custom = make(map[string]string)
+		}
+		feed.Custom["icon"] = icon
 	}
-	return ats
+	fetchItems.Set(float64(len(feed.Items)), label)
+	return feed, nil
 }
+
+// redactURL strips the credentials private feeds may carry in their url,
+// the userinfo and query, for logs and traces.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<unparsable url>"
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// userArticles fans the fetched entries out to the users subscribed to them,
+// filtering them through rules, dropping duplicate urls, ranking them by Rank
+// and clustering similar stories.
+func userArticles(users map[string][]Sub, feeds map[string][]*Entry, rules Rules) map[string]*readss.ListReply {
+	lists := make(map[string]*readss.ListReply, len(users))
+	for user, subs := range users {
+		var rs []ranked
+		seen := make(map[string]bool)
+		for _, sub := range subs {
+			for _, e := range feeds[sub.feed()] {
+				if e.URL != "" && seen[e.URL] {
+					continue
+				}
+				v := rules.Apply(e, sub)
+				if v.Drop || v.Hide {
+					continue
+				}
+				seen[e.URL] = true
+				at := e.Article(sub)
+				at.Tags = append(at.Tags, v.Tags...)
+				rs = append(rs, ranked{e, at, sub})
+			}
+		}
+		rs = rank(rs, Rank, 100)
+		l := &readss.ListReply{
+			Articles: make([]*readss.Article, len(rs)),
+			Clusters: cluster(rs),
+		}
+		for i, r := range rs {
+			l.Articles[i] = r.at
+		}
+		lists[user] = l
+	}
+	return lists
+}
+
+func feedEntries(u string, feed *gofeed.Feed) []*Entry {
+	now := time.Now()
+	es := make([]*Entry, len(feed.Items))
+	for i, it := range feed.Items {
+		ts := now
+		if it.UpdatedParsed != nil {
+			ts = *it.UpdatedParsed
+		} else if it.PublishedParsed != nil {
+			ts = *it.PublishedParsed
+		}
+		id := it.GUID
+		if id == "" {
+			id = it.Link
+		}
+		var author string
+		if it.Author != nil {
+			author = it.Author.Name
+		}
+		es[i] = &Entry{
+			ID:         id,
+			Feed:       u,
+			Title:      it.Title,
+			URL:        canonicalURL(it.Link),
+			OrigURL:    it.Link,
+			Summary:    sanitize(it.Description, it.Link),
+			Content:    sanitize(it.Content, it.Link),
+			Author:     author,
+			Categories: it.Categories,
+			Time:       ts,
+			Episode:    feedEpisode(feed, it),
+		}
+	}
+	return es
+}
+
 func humanTime(t time.Time) string {
 	d := time.Now().Sub(t)
 	var ago string
@@ -206,9 +1212,3 @@ func humanTime(t time.Time) string {
 	}
 	return ago
 }
-
-type Articles []*readss.Article
-
-func (a Articles) Len() int           { return len(a) }
-func (a Articles) Less(i, j int) bool { return a[i].Time > a[j].Time }
-func (a Articles) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
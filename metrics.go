@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// fetch metrics are labelled by the redacted feed url,
+	// subscriptions sharing a feed share its fetches
+	fetchDuration = newHistogram("readss_fetch_duration_seconds", "Time taken to fetch and parse a feed.", defBuckets, "feed")
+	fetchStatus   = newCounter("readss_fetch_responses_total", "Feed fetches by http status code, or error if no response was received.", "feed", "code")
+	fetchBytes    = newCounter("readss_fetch_bytes_total", "Bytes read from feeds.", "feed")
+	fetchItems    = newGauge("readss_fetch_items", "Items in the last fetch of a feed.", "feed")
+
+	refreshDuration = newHistogram("readss_refresh_duration_seconds", "Time taken by a refresh round.", []float64{1, 2.5, 5, 10, 30, 60, 120, 300})
+	storeArticles   = newGauge("readss_store_articles", "Articles held in the store.")
+
+	rpcRequests = newCounter("readss_rpc_requests_total", "RPCs handled by method and status code.", "method", "code")
+	rpcDuration = newHistogram("readss_rpc_duration_seconds", "Time taken to handle RPCs.", defBuckets, "method")
+
+	websubPushes      = newCounter("readss_websub_pushes_total", "Content pushed by websub hubs by result.", "result")
+	podcastDownloads  = newCounter("readss_podcast_downloads_total", "Podcast episode downloads by result.", "result")
+	archivePages      = newCounter("readss_archive_pages_total", "Page snapshots by result.", "result")
+	iconFetches       = newCounter("readss_icon_fetches_total", "Feed icon lookups by result.", "result")
+	imageProxied      = newCounter("readss_image_proxy_requests_total", "Image proxy requests by result, hit, miss, error or forbidden.", "result")
+	webhookDeliveries = newCounter("readss_webhook_deliveries_total", "Webhook deliveries by result, ok or failed after retries.", "webhook", "result")
+)
+
+var defBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricsInterceptor records rpcRequests and rpcDuration.
+func metricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	t := time.Now()
+	res, err := handler(ctx, req)
+	rpcDuration.Observe(time.Since(t).Seconds(), info.FullMethod)
+	rpcRequests.Add(1, info.FullMethod, status.Code(err).String())
+	return res, err
+}
+
+// chainUnary runs interceptors in order, the first being the outermost.
+func chainUnary(is ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		h := handler
+		for i := len(is) - 1; i >= 0; i-- {
+			next, in := h, is[i]
+			h = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return in(ctx, req, info, next)
+			}
+		}
+		return h(ctx, req)
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// registry holds all metrics in the order they were created.
+var registry struct {
+	sync.Mutex
+	ms []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+func register(m metric) {
+	registry.Lock()
+	registry.ms = append(registry.ms, m)
+	registry.Unlock()
+}
+
+// metricsHandler serves all metrics in the prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	registry.Lock()
+	ms := registry.ms
+	registry.Unlock()
+	for _, m := range ms {
+		m.write(w)
+	}
+}
+
+// desc is the name and labels shared by all metric types.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+}
+
+// key joins label values into a map key.
+func (d desc) key(lvs []string) string {
+	if len(lvs) != len(d.labels) {
+		panic(fmt.Sprintf("%v: got %d label values for %d labels", d.name, len(lvs), len(d.labels)))
+	}
+	return strings.Join(lvs, "\xff")
+}
+
+// labelPairs formats the labels for key, with optional extra pairs.
+func (d desc) labelPairs(key string, extra ...string) string {
+	var ps []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			ps = append(ps, d.labels[i]+`="`+labelEscaper.Replace(v)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		ps = append(ps, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+	if len(ps) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(ps, ",") + "}"
+}
+
+func sortedKeys(m map[string]float64) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// counter is a monotonically increasing value per set of label values.
+type counter struct {
+	desc
+	mu   sync.Mutex
+	vals map[string]float64
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	c := &counter{
+		desc: desc{name, help, "counter", labels},
+		vals: make(map[string]float64),
+	}
+	register(c)
+	return c
+}
+
+func (c *counter) Add(v float64, lvs ...string) {
+	k := c.key(lvs)
+	c.mu.Lock()
+	c.vals[k] += v
+	c.mu.Unlock()
+}
+
+func (c *counter) write(w io.Writer) {
+	c.header(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.vals) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(k), formatFloat(c.vals[k]))
+	}
+}
+
+// gauge is a value that can go up and down per set of label values.
+type gauge struct {
+	counter
+}
+
+func newGauge(name, help string, labels ...string) *gauge {
+	g := &gauge{counter{
+		desc: desc{name, help, "gauge", labels},
+		vals: make(map[string]float64),
+	}}
+	register(g)
+	return g
+}
+
+func (g *gauge) Set(v float64, lvs ...string) {
+	k := g.key(lvs)
+	g.mu.Lock()
+	g.vals[k] = v
+	g.mu.Unlock()
+}
+
+// histogram counts observations into cumulative buckets per set of label values.
+type histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	counts  map[string][]uint64
+	sums    map[string]float64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	h := &histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: buckets,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+	}
+	register(h)
+	return h
+}
+
+func (h *histogram) Observe(v float64, lvs ...string) {
+	k := h.key(lvs)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cs, ok := h.counts[k]
+	if !ok {
+		// one extra bucket for +Inf
+		cs = make([]uint64, len(h.buckets)+1)
+		h.counts[k] = cs
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			cs[i]++
+		}
+	}
+	cs[len(h.buckets)]++
+	h.sums[k] += v
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.sums) {
+		cs := h.counts[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", formatFloat(b)), cs[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(k, "le", "+Inf"), cs[len(h.buckets)])
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(k), formatFloat(h.sums[k]))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(k), cs[len(h.buckets)])
+	}
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
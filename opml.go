@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type opml struct {
+	XMLName  xml.Name  `xml:"opml"`
+	Version  string    `xml:"version,attr"`
+	Title    string    `xml:"head>title"`
+	Outlines []outline `xml:"body>outline"`
+}
+
+// outline is either a feed, with an XMLURL,
+// or a folder of more outlines.
+type outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []outline `xml:"outline"`
+}
+
+// importOPML prints the feeds in an OPML file as subscription records,
+// skipping those the user is already subscribed to.
+// Folders and categories become tags.
+func importOPML(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configFlags(fs)
+	user := fs.String("user", "", "user whose existing subscriptions to skip, if -config is a directory")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: readss import [flags] [file.opml] >> subs.csv\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if fn := fs.Arg(0); fn != "" && fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var doc opml
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("decode opml: %v", err)
+	}
+
+	have := make(map[string]bool)
+	if subs, err := userSubs(*user); err == nil {
+		for _, sub := range subs {
+			have[sub.URL] = true
+		}
+	}
+
+	var subs []Sub
+	var walk func(ols []outline, tags []string)
+	walk = func(ols []outline, tags []string) {
+		for _, o := range ols {
+			ts := append(tags[:len(tags):len(tags)], opmlTags(o.Category)...)
+			if o.XMLURL == "" {
+				if t := opmlTag(o.Text); t != "" {
+					ts = append(ts, t)
+				}
+				walk(o.Outlines, ts)
+				continue
+			}
+			name := o.Title
+			if name == "" {
+				name = o.Text
+			}
+			if name == "" {
+				name = o.XMLURL
+			}
+			subs = append(subs, Sub{Name: name, URL: o.XMLURL, Tags: ts})
+		}
+	}
+	walk(doc.Outlines, nil)
+
+	w := csv.NewWriter(os.Stdout)
+	var skipped int
+	for _, sub := range subs {
+		if have[sub.URL] {
+			skipped++
+			continue
+		}
+		have[sub.URL] = true
+		r := []string{sub.Name, sub.URL}
+		if len(sub.Tags) > 0 {
+			r = append(r, "tags="+strings.Join(sub.Tags, " "))
+		}
+		w.Write(r)
+	}
+	w.Flush()
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d existing subscriptions\n", skipped)
+	}
+	return w.Error()
+}
+
+// opmlTags splits a comma separated list of /slash/delimited categories.
+func opmlTags(cs string) []string {
+	var ts []string
+	for _, c := range strings.Split(cs, ",") {
+		if t := opmlTag(c); t != "" {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// opmlTag makes a folder or category name usable as a tag.
+func opmlTag(s string) string {
+	return strings.Join(strings.Fields(strings.Trim(strings.TrimSpace(s), "/")), "-")
+}
+
+// exportOPML prints the subscriptions of a user as an OPML file,
+// tags are written as categories.
+// Other options have no equivalent in OPML and are not exported.
+func exportOPML(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configFlags(fs)
+	user := fs.String("user", "", "user to export, if -config is a directory")
+	fs.Parse(args)
+
+	subs, err := userSubs(*user)
+	if err != nil {
+		return err
+	}
+	doc := opml{
+		Version: "2.0",
+		Title:   "readss subscriptions",
+	}
+	for _, sub := range subs {
+		doc.Outlines = append(doc.Outlines, outline{
+			Text:     sub.Name,
+			Title:    sub.Name,
+			Type:     "rss",
+			XMLURL:   sub.URL,
+			Category: strings.Join(sub.Tags, ","),
+		})
+	}
+
+	fmt.Fprint(os.Stdout, xml.Header)
+	enc := xml.NewEncoder(os.Stdout)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout)
+	return nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// feedEpisode returns the podcast episode of it, if it has an audio or video enclosure.
+func feedEpisode(feed *gofeed.Feed, it *gofeed.Item) *Episode {
+	var enc *gofeed.Enclosure
+	for _, e := range it.Enclosures {
+		if e.URL != "" && (strings.HasPrefix(e.Type, "audio/") || strings.HasPrefix(e.Type, "video/")) {
+			enc = e
+			break
+		}
+	}
+	if enc == nil {
+		return nil
+	}
+	ep := &Episode{
+		URL:    enc.URL,
+		Type:   enc.Type,
+		Number: itunesInt(it, "episode"),
+		Season: itunesInt(it, "season"),
+	}
+	ep.Length, _ = strconv.ParseInt(enc.Length, 10, 64)
+	if it.ITunesExt != nil {
+		ep.Duration = itunesDuration(it.ITunesExt.Duration)
+		ep.Image = it.ITunesExt.Image
+	}
+	if ep.Image == "" && it.Image != nil {
+		ep.Image = it.Image.URL
+	}
+	if ep.Image == "" && feed.ITunesExt != nil {
+		ep.Image = feed.ITunesExt.Image
+	}
+	if ep.Image == "" && feed.Image != nil {
+		ep.Image = feed.Image.URL
+	}
+	return ep
+}
+
+// itunesInt reads the integer itunes:name element of it,
+// which gofeed doesn't parse into ITunesExt.
+func itunesInt(it *gofeed.Item, name string) int {
+	for _, e := range it.Extensions["itunes"][name] {
+		if n, err := strconv.Atoi(strings.TrimSpace(e.Value)); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// itunesDuration parses seconds, mm:ss or hh:mm:ss.
+func itunesDuration(s string) time.Duration {
+	var d time.Duration
+	for _, p := range strings.Split(strings.TrimSpace(s), ":") {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0
+		}
+		d = d*60 + time.Duration(f*float64(time.Second))
+	}
+	return d
+}
+
+// podcasts downloads episodes of subscriptions with the download option,
+// it is nil if there is no PodcastDir.
+var podcasts *podcaster
+
+// podcaster keeps episodes downloaded in dir/<show>/<article id><ext>,
+// where show is the pushID of the feed.
+type podcaster struct {
+	dir string
+
+	mu sync.RWMutex
+	// files maps article ids to paths relative to dir
+	files map[string]string
+}
+
+// podcastClient has no overall timeout, downloads are limited by PodcastTimeout
+var podcastClient = &http.Client{}
+
+func newPodcaster(dir string) *podcaster {
+	p := &podcaster{
+		dir:   dir,
+		files: make(map[string]string),
+	}
+	fns, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	for _, fn := range fns {
+		if strings.HasSuffix(fn, ".tmp") {
+			os.Remove(fn)
+			continue
+		}
+		rel, _ := filepath.Rel(dir, fn)
+		base := filepath.Base(fn)
+		p.files[strings.TrimSuffix(base, filepath.Ext(base))] = filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// url is where the downloaded copy of e is served, if there is one.
+func (p *podcaster) url(e *Entry) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.RLock()
+	rel, ok := p.files[e.key()]
+	p.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(PublicURL, "/") + "/podcasts/" + rel
+}
+
+// run syncs downloads with the store every minute until ctx is done.
+func (p *podcaster) run(ctx context.Context, svr *Server) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.sync(ctx, svr)
+		}
+	}
+}
+
+// sync downloads the episodes each show should keep and deletes the rest,
+// along with shows no longer subscribed to.
+func (p *podcaster) sync(ctx context.Context, svr *Server) {
+	svr.mu.RLock()
+	users := svr.users
+	svr.mu.RUnlock()
+	if users == nil {
+		// not loaded yet, don't mistake it for no subscriptions
+		return
+	}
+
+	// the most generous retention of any user subscribed to a feed
+	shows := make(map[string][]Sub)
+	for _, subs := range users {
+		for _, sub := range subs {
+			if sub.Download > 0 || sub.DownloadFor > 0 {
+				shows[sub.feed()] = append(shows[sub.feed()], sub)
+			}
+		}
+	}
+
+	keep := make(map[string]bool)
+	feeds := svr.store.Feeds()
+	for u, subs := range shows {
+		var eps []*Entry
+		for _, e := range feeds[u] {
+			if e.Episode != nil {
+				eps = append(eps, e)
+			}
+		}
+		sort.Slice(eps, func(i, j int) bool { return eps[i].Time.After(eps[j].Time) })
+		for i, e := range eps {
+			for _, sub := range subs {
+				if i < sub.Download || (sub.DownloadFor > 0 && time.Since(e.Time) < sub.DownloadFor) {
+					keep[e.key()] = true
+				}
+			}
+			if !keep[e.key()] || p.url(e) != "" {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err := p.download(ctx, subs[0], e); err != nil {
+				podcastDownloads.Add(1, "error")
+				logger.Warn("download episode", "source", subs[0].Name, "url", e.Episode.URL, "error", err)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, rel := range p.files {
+		if keep[k] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(p.dir, filepath.FromSlash(rel))); err != nil && !os.IsNotExist(err) {
+			logger.Warn("remove episode", "file", rel, "error", err)
+			continue
+		}
+		delete(p.files, k)
+		logger.Debug("removed episode", "file", rel)
+	}
+	dirs, _ := ioutil.ReadDir(p.dir)
+	for _, d := range dirs {
+		if d.IsDir() {
+			// only removes empty directories
+			os.Remove(filepath.Join(p.dir, d.Name()))
+		}
+	}
+}
+
+// download fetches the audio of e into the directory of its show,
+// within PodcastTimeout and PodcastMaxBytes.
+func (p *podcaster) download(ctx context.Context, sub Sub, e *Entry) error {
+	t := time.Now()
+	ctx, sp := startSpan(ctx, "download episode", "source", sub.Name, "url", e.Episode.URL)
+	sp.kind = spanClient
+	defer sp.End()
+
+	if e.Episode.Length > PodcastMaxBytes {
+		err := fmt.Errorf("length %v over %v bytes", e.Episode.Length, PodcastMaxBytes)
+		sp.SetError(err)
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, PodcastTimeout)
+	defer cancel()
+
+	rel := pushID(sub.feed()) + "/" + e.key() + episodeExt(e.Episode)
+	fn := filepath.Join(p.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, e.Episode.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "readss")
+	res, err := podcastClient.Do(req.WithContext(ctx))
+	if err != nil {
+		sp.SetError(err)
+		return err
+	}
+	defer res.Body.Close()
+	sp.SetAttributes("http.status_code", res.StatusCode)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err = fmt.Errorf("status %v", res.Status)
+		sp.SetError(err)
+		return err
+	}
+	if res.ContentLength > PodcastMaxBytes {
+		err = fmt.Errorf("over %v bytes", PodcastMaxBytes)
+		sp.SetError(err)
+		return err
+	}
+
+	f, err := os.Create(fn + ".tmp")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(res.Body, PodcastMaxBytes+1))
+	if err == nil && n > PodcastMaxBytes {
+		err = fmt.Errorf("over %v bytes", PodcastMaxBytes)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(fn+".tmp", fn)
+	}
+	if err != nil {
+		os.Remove(fn + ".tmp")
+		sp.SetError(err)
+		return err
+	}
+	sp.SetAttributes("bytes", n)
+
+	p.mu.Lock()
+	p.files[e.key()] = rel
+	p.mu.Unlock()
+	podcastDownloads.Add(1, "ok")
+	logger.Info("downloaded episode", "source", sub.Name, "url", e.Episode.URL, "file", rel, "bytes", n, "duration", time.Since(t))
+	return nil
+}
+
+// episodeExt is the file extension of the episode url, or one for its type.
+func episodeExt(ep *Episode) string {
+	if u, err := url.Parse(ep.URL); err == nil {
+		if ext := path.Ext(u.Path); len(ext) > 1 && len(ext) <= 5 {
+			return strings.ToLower(ext)
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(ep.Type); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// ServeHTTP serves downloaded episodes under /podcasts/,
+// supporting range requests for seeking.
+func (p *podcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel := strings.TrimPrefix(path.Clean(r.URL.Path), "/podcasts/")
+	base := path.Base(rel)
+	p.mu.RLock()
+	ok := p.files[strings.TrimSuffix(base, path.Ext(base))] == rel
+	p.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(p.dir, filepath.FromSlash(rel)))
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"mime"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+func TestItunesDuration(t *testing.T) {
+	tcs := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"45", 45 * time.Second},
+		{" 3600 ", time.Hour},
+		{"1.5", 1500 * time.Millisecond},
+		{"1:30", 90 * time.Second},
+		{"90:00", 90 * time.Minute},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"1:02:03.5", time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{"", 0},
+		{"abc", 0},
+		{"1:xx", 0},
+		{"1::2", 0},
+		{"12 minutes", 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := itunesDuration(tc.in); got != tc.want {
+				t.Errorf("itunesDuration(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+const testPodcast = `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel><title>show</title>
+<item><title>episode</title>
+<enclosure url="https://example.com/ep.mp3" type="audio/mpeg" length="12345"/>
+<itunes:duration>1:02:03</itunes:duration>
+<itunes:episode> 7 </itunes:episode>
+<itunes:season>2</itunes:season>
+<itunes:image href="https://example.com/ep.jpg"/>
+</item>
+</channel></rss>`
+
+func TestFeedEpisodeParsed(t *testing.T) {
+	feed, err := gofeed.NewParser().ParseString(testPodcast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := feedEpisode(feed, feed.Items[0])
+	want := &Episode{
+		URL:      "https://example.com/ep.mp3",
+		Type:     "audio/mpeg",
+		Length:   12345,
+		Duration: time.Hour + 2*time.Minute + 3*time.Second,
+		Number:   7,
+		Season:   2,
+		Image:    "https://example.com/ep.jpg",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("feedEpisode = %+v, want %+v", got, want)
+	}
+}
+
+func TestFeedEpisodeEnclosure(t *testing.T) {
+	enc := func(u, typ, length string) *gofeed.Enclosure {
+		return &gofeed.Enclosure{URL: u, Type: typ, Length: length}
+	}
+	tcs := []struct {
+		name   string
+		encs   []*gofeed.Enclosure
+		url    string
+		length int64
+	}{
+		{name: "none"},
+		{name: "image only", encs: []*gofeed.Enclosure{enc("https://example.com/a.jpg", "image/jpeg", "1")}},
+		{name: "no type", encs: []*gofeed.Enclosure{enc("https://example.com/a.mp3", "", "1")}},
+		{name: "audio", encs: []*gofeed.Enclosure{enc("https://example.com/a.mp3", "audio/mpeg", "10")}, url: "https://example.com/a.mp3", length: 10},
+		{name: "video", encs: []*gofeed.Enclosure{enc("https://example.com/a.mp4", "video/mp4", "20")}, url: "https://example.com/a.mp4", length: 20},
+		{name: "first media", encs: []*gofeed.Enclosure{
+			enc("https://example.com/a.pdf", "application/pdf", "1"),
+			enc("https://example.com/a.ogg", "audio/ogg", "30"),
+			enc("https://example.com/a.mp3", "audio/mpeg", "10"),
+		}, url: "https://example.com/a.ogg", length: 30},
+		{name: "skip empty url", encs: []*gofeed.Enclosure{
+			enc("", "audio/mpeg", "10"),
+			enc("https://example.com/a.m4a", "audio/mp4", "40"),
+		}, url: "https://example.com/a.m4a", length: 40},
+		{name: "bad length", encs: []*gofeed.Enclosure{enc("https://example.com/a.mp3", "audio/mpeg", "big")}, url: "https://example.com/a.mp3"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ep := feedEpisode(&gofeed.Feed{}, &gofeed.Item{Enclosures: tc.encs})
+			if tc.url == "" {
+				if ep != nil {
+					t.Errorf("feedEpisode = %+v, want nil", ep)
+				}
+				return
+			}
+			if ep == nil || ep.URL != tc.url || ep.Length != tc.length {
+				t.Errorf("feedEpisode = %+v, want %v of length %d", ep, tc.url, tc.length)
+			}
+		})
+	}
+}
+
+func TestFeedEpisodeImage(t *testing.T) {
+	const (
+		itemItunes = "https://example.com/item-itunes.jpg"
+		itemImage  = "https://example.com/item.jpg"
+		feedItunes = "https://example.com/feed-itunes.jpg"
+		feedImage  = "https://example.com/feed.jpg"
+	)
+	tcs := []struct {
+		name string
+		item *gofeed.Item
+		feed *gofeed.Feed
+		want string
+	}{
+		{
+			name: "item itunes",
+			item: &gofeed.Item{ITunesExt: &ext.ITunesItemExtension{Image: itemItunes}, Image: &gofeed.Image{URL: itemImage}},
+			feed: &gofeed.Feed{ITunesExt: &ext.ITunesFeedExtension{Image: feedItunes}, Image: &gofeed.Image{URL: feedImage}},
+			want: itemItunes,
+		}, {
+			name: "item image",
+			item: &gofeed.Item{ITunesExt: &ext.ITunesItemExtension{Duration: "1"}, Image: &gofeed.Image{URL: itemImage}},
+			feed: &gofeed.Feed{ITunesExt: &ext.ITunesFeedExtension{Image: feedItunes}, Image: &gofeed.Image{URL: feedImage}},
+			want: itemImage,
+		}, {
+			name: "feed itunes",
+			item: &gofeed.Item{},
+			feed: &gofeed.Feed{ITunesExt: &ext.ITunesFeedExtension{Image: feedItunes}, Image: &gofeed.Image{URL: feedImage}},
+			want: feedItunes,
+		}, {
+			name: "feed image",
+			item: &gofeed.Item{Image: &gofeed.Image{}},
+			feed: &gofeed.Feed{ITunesExt: &ext.ITunesFeedExtension{}, Image: &gofeed.Image{URL: feedImage}},
+			want: feedImage,
+		}, {
+			name: "none",
+			item: &gofeed.Item{},
+			feed: &gofeed.Feed{},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.item.Enclosures = []*gofeed.Enclosure{{URL: "https://example.com/a.mp3", Type: "audio/mpeg"}}
+			ep := feedEpisode(tc.feed, tc.item)
+			if ep == nil || ep.Image != tc.want {
+				t.Errorf("feedEpisode image = %+v, want %q", ep, tc.want)
+			}
+		})
+	}
+}
+
+func TestEpisodeExt(t *testing.T) {
+	mime.AddExtensionType(".rdtest", "audio/x-readss-test")
+	tcs := []struct {
+		url, typ string
+		want     string
+	}{
+		{"https://example.com/ep.mp3", "audio/mpeg", ".mp3"},
+		{"https://example.com/EP.M4A", "audio/mp4", ".m4a"},
+		{"https://example.com/ep.ogg?token=abc.def", "audio/ogg", ".ogg"},
+		{"https://example.com/v1.2/episode", "audio/x-readss-test", ".rdtest"},
+		{"https://example.com/ep.", "audio/x-readss-test", ".rdtest"},
+		{"https://example.com/ep.download", "audio/x-readss-test", ".rdtest"},
+		{"https://example.com/episode", "audio/x-unknown", ".bin"},
+		{"https://example.com/%zz", "", ".bin"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := episodeExt(&Episode{URL: tc.url, Type: tc.typ}); got != tc.want {
+				t.Errorf("episodeExt(%q, %q) = %q, want %q", tc.url, tc.typ, got, tc.want)
+			}
+		})
+	}
+}
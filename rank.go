@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+// ranked is an article to be ordered by rank,
+// along with the entry and subscription it came from.
+type ranked struct {
+	e   *Entry
+	at  *readss.Article
+	sub Sub
+}
+
+// rank orders rs according to mode and keeps at most n:
+//
+//	time        newest first
+//	cap         newest first, with at most RankCap * weight articles
+//	            per subscription in any RankWindow
+//	roundrobin  take weight articles from each subscription in turn,
+//	            starting with the most recently updated
+func rank(rs []ranked, mode string, n int) []ranked {
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].e.Time.After(rs[j].e.Time) })
+	switch mode {
+	case "cap":
+		rs = rankCap(rs, RankCap, RankWindow)
+	case "roundrobin":
+		rs = rankRoundRobin(rs)
+	}
+
+	if len(rs) > n {
+		rs = rs[:n]
+	}
+	return rs
+}
+
+// rankCap drops articles from subscriptions that already have
+// limit * weight newer articles within window of it.
+// rs must be sorted newest first.
+func rankCap(rs []ranked, limit int, window time.Duration) []ranked {
+	kept := make(map[string][]time.Time)
+	out := rs[:0:0]
+	for _, r := range rs {
+		var n int
+		for _, t := range kept[r.sub.Name] {
+			if t.Sub(r.e.Time) < window {
+				n++
+			}
+		}
+		if n >= limit*r.sub.weight() {
+			continue
+		}
+		kept[r.sub.Name] = append(kept[r.sub.Name], r.e.Time)
+		out = append(out, r)
+	}
+	return out
+}
+
+// rankRoundRobin interleaves subscriptions.
+// rs must be sorted newest first.
+func rankRoundRobin(rs []ranked) []ranked {
+	var order []string
+	bySub := make(map[string][]ranked)
+	for _, r := range rs {
+		if _, ok := bySub[r.sub.Name]; !ok {
+			order = append(order, r.sub.Name)
+		}
+		bySub[r.sub.Name] = append(bySub[r.sub.Name], r)
+	}
+
+	out := make([]ranked, 0, len(rs))
+	for len(out) < len(rs) {
+		for _, name := range order {
+			q := bySub[name]
+			if len(q) == 0 {
+				continue
+			}
+			n := q[0].sub.weight()
+			if n > len(q) {
+				n = len(q)
+			}
+			out = append(out, q[:n]...)
+			bySub[name] = q[n:]
+		}
+	}
+	return out
+}
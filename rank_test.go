@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+func TestRank(t *testing.T) {
+	defer func(c int, w time.Duration) { RankCap, RankWindow = c, w }(RankCap, RankWindow)
+	RankCap, RankWindow = 1, 24*time.Hour
+
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// article is an article of sub, published hours before t0
+	type article struct {
+		id     string
+		sub    string
+		weight int
+		hours  int
+	}
+	tcs := []struct {
+		name     string
+		mode     string
+		n        int
+		articles []article
+		want     []string
+	}{
+		{
+			name: "time",
+			mode: "time",
+			n:    10,
+			articles: []article{
+				{"a2", "a", 0, 2}, {"b1", "b", 0, 1}, {"a0", "a", 0, 0}, {"c3", "c", 0, 3},
+			},
+			want: []string{"a0", "b1", "a2", "c3"},
+		},
+		{
+			name: "time keeps n",
+			mode: "time",
+			n:    2,
+			articles: []article{
+				{"a2", "a", 0, 2}, {"b1", "b", 0, 1}, {"a0", "a", 0, 0},
+			},
+			want: []string{"a0", "b1"},
+		},
+		{
+			name: "unknown mode is time",
+			mode: "",
+			n:    10,
+			articles: []article{
+				{"a1", "a", 0, 1}, {"a0", "a", 0, 0},
+			},
+			want: []string{"a0", "a1"},
+		},
+		{
+			name: "cap",
+			mode: "cap",
+			n:    10,
+			articles: []article{
+				{"a0", "a", 0, 0}, {"a1", "a", 0, 1}, {"a2", "a", 0, 2}, {"b3", "b", 0, 3}, {"a30", "a", 0, 30},
+			},
+			want: []string{"a0", "b3", "a30"},
+		},
+		{
+			name: "cap weighted",
+			mode: "cap",
+			n:    10,
+			articles: []article{
+				{"a0", "a", 2, 0}, {"a1", "a", 2, 1}, {"a2", "a", 2, 2}, {"b3", "b", 0, 3}, {"b4", "b", 0, 4},
+			},
+			want: []string{"a0", "a1", "b3"},
+		},
+		{
+			name: "cap window",
+			mode: "cap",
+			n:    10,
+			articles: []article{
+				{"a0", "a", 0, 0}, {"a23", "a", 0, 23}, {"a24", "a", 0, 24}, {"a47", "a", 0, 47}, {"a48", "a", 0, 48},
+			},
+			want: []string{"a0", "a24", "a48"},
+		},
+		{
+			name: "roundrobin",
+			mode: "roundrobin",
+			n:    10,
+			articles: []article{
+				{"a0", "a", 0, 0}, {"a1", "a", 0, 1}, {"a2", "a", 0, 2}, {"b3", "b", 0, 3}, {"b4", "b", 0, 4}, {"c5", "c", 0, 5},
+			},
+			want: []string{"a0", "b3", "c5", "a1", "b4", "a2"},
+		},
+		{
+			name: "roundrobin weighted",
+			mode: "roundrobin",
+			n:    4,
+			articles: []article{
+				{"b0", "b", 0, 0}, {"a1", "a", 2, 1}, {"a2", "a", 2, 2}, {"a3", "a", 2, 3}, {"b4", "b", 0, 4},
+			},
+			want: []string{"b0", "a1", "a2", "b4"},
+		},
+		{
+			name: "empty",
+			mode: "roundrobin",
+			n:    10,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var rs []ranked
+			for _, a := range tc.articles {
+				rs = append(rs, ranked{
+					e:   &Entry{ID: a.id, Time: t0.Add(-time.Duration(a.hours) * time.Hour)},
+					at:  &readss.Article{Id: a.id},
+					sub: Sub{Name: a.sub, Weight: a.weight},
+				})
+			}
+			var got []string
+			for _, r := range rank(rs, tc.mode, tc.n) {
+				got = append(got, r.at.Id)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("rank = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
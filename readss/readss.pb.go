@@ -25,6 +25,9 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type ListRequest struct {
+	// user must be empty or the user of the account
+	// the call is authenticated as with basic authorization
+	User                 string   `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -55,8 +58,17 @@ func (m *ListRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_ListRequest proto.InternalMessageInfo
 
+func (m *ListRequest) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
 type ListReply struct {
-	Articles             []*Article `protobuf:"bytes,1,rep,name=articles,proto3" json:"articles,omitempty"`
+	Articles []*Article `protobuf:"bytes,1,rep,name=articles,proto3" json:"articles,omitempty"`
+	// clusters groups articles about the same story
+	Clusters             []*Cluster `protobuf:"bytes,2,rep,name=clusters,proto3" json:"clusters,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
 	XXX_unrecognized     []byte     `json:"-"`
 	XXX_sizecache        int32      `json:"-"`
@@ -94,12 +106,327 @@ func (m *ListReply) GetArticles() []*Article {
 	return nil
 }
 
+func (m *ListReply) GetClusters() []*Cluster {
+	if m != nil {
+		return m.Clusters
+	}
+	return nil
+}
+
+type Cluster struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Sources              []string `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"`
+	ArticleIds           []string `protobuf:"bytes,3,rep,name=article_ids,json=articleIds,proto3" json:"article_ids,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Cluster) Reset()         { *m = Cluster{} }
+func (m *Cluster) String() string { return proto.CompactTextString(m) }
+func (*Cluster) ProtoMessage()    {}
+func (*Cluster) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{2}
+}
+
+func (m *Cluster) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Cluster.Unmarshal(m, b)
+}
+func (m *Cluster) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Cluster.Marshal(b, m, deterministic)
+}
+func (m *Cluster) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Cluster.Merge(m, src)
+}
+func (m *Cluster) XXX_Size() int {
+	return xxx_messageInfo_Cluster.Size(m)
+}
+func (m *Cluster) XXX_DiscardUnknown() {
+	xxx_messageInfo_Cluster.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Cluster proto.InternalMessageInfo
+
+func (m *Cluster) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Cluster) GetSources() []string {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+func (m *Cluster) GetArticleIds() []string {
+	if m != nil {
+		return m.ArticleIds
+	}
+	return nil
+}
+
+type SearchRequest struct {
+	// user as in ListRequest
+	User                 string   `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Query                string   `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SearchRequest) Reset()         { *m = SearchRequest{} }
+func (m *SearchRequest) String() string { return proto.CompactTextString(m) }
+func (*SearchRequest) ProtoMessage()    {}
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{3}
+}
+
+func (m *SearchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchRequest.Unmarshal(m, b)
+}
+func (m *SearchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchRequest.Marshal(b, m, deterministic)
+}
+func (m *SearchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchRequest.Merge(m, src)
+}
+func (m *SearchRequest) XXX_Size() int {
+	return xxx_messageInfo_SearchRequest.Size(m)
+}
+func (m *SearchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchRequest proto.InternalMessageInfo
+
+func (m *SearchRequest) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *SearchRequest) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+type SearchReply struct {
+	Results              []*SearchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *SearchReply) Reset()         { *m = SearchReply{} }
+func (m *SearchReply) String() string { return proto.CompactTextString(m) }
+func (*SearchReply) ProtoMessage()    {}
+func (*SearchReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{4}
+}
+
+func (m *SearchReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchReply.Unmarshal(m, b)
+}
+func (m *SearchReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchReply.Marshal(b, m, deterministic)
+}
+func (m *SearchReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchReply.Merge(m, src)
+}
+func (m *SearchReply) XXX_Size() int {
+	return xxx_messageInfo_SearchReply.Size(m)
+}
+func (m *SearchReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchReply proto.InternalMessageInfo
+
+func (m *SearchReply) GetResults() []*SearchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type SearchResult struct {
+	Article              *Article `protobuf:"bytes,1,opt,name=article,proto3" json:"article,omitempty"`
+	Title                string   `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Snippet              string   `protobuf:"bytes,3,opt,name=snippet,proto3" json:"snippet,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SearchResult) Reset()         { *m = SearchResult{} }
+func (m *SearchResult) String() string { return proto.CompactTextString(m) }
+func (*SearchResult) ProtoMessage()    {}
+func (*SearchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{5}
+}
+
+func (m *SearchResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SearchResult.Unmarshal(m, b)
+}
+func (m *SearchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SearchResult.Marshal(b, m, deterministic)
+}
+func (m *SearchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SearchResult.Merge(m, src)
+}
+func (m *SearchResult) XXX_Size() int {
+	return xxx_messageInfo_SearchResult.Size(m)
+}
+func (m *SearchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_SearchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SearchResult proto.InternalMessageInfo
+
+func (m *SearchResult) GetArticle() *Article {
+	if m != nil {
+		return m.Article
+	}
+	return nil
+}
+
+func (m *SearchResult) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *SearchResult) GetSnippet() string {
+	if m != nil {
+		return m.Snippet
+	}
+	return ""
+}
+
+type GetArticleRequest struct {
+	// user as in ListRequest
+	User                 string   `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetArticleRequest) Reset()         { *m = GetArticleRequest{} }
+func (m *GetArticleRequest) String() string { return proto.CompactTextString(m) }
+func (*GetArticleRequest) ProtoMessage()    {}
+func (*GetArticleRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{6}
+}
+
+func (m *GetArticleRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetArticleRequest.Unmarshal(m, b)
+}
+func (m *GetArticleRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetArticleRequest.Marshal(b, m, deterministic)
+}
+func (m *GetArticleRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetArticleRequest.Merge(m, src)
+}
+func (m *GetArticleRequest) XXX_Size() int {
+	return xxx_messageInfo_GetArticleRequest.Size(m)
+}
+func (m *GetArticleRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetArticleRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetArticleRequest proto.InternalMessageInfo
+
+func (m *GetArticleRequest) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *GetArticleRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type GetArticleReply struct {
+	Article *Article `protobuf:"bytes,1,opt,name=article,proto3" json:"article,omitempty"`
+	// content is the extracted article if available,
+	// otherwise the content or description from the feed
+	Content              string   `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetArticleReply) Reset()         { *m = GetArticleReply{} }
+func (m *GetArticleReply) String() string { return proto.CompactTextString(m) }
+func (*GetArticleReply) ProtoMessage()    {}
+func (*GetArticleReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{7}
+}
+
+func (m *GetArticleReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetArticleReply.Unmarshal(m, b)
+}
+func (m *GetArticleReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetArticleReply.Marshal(b, m, deterministic)
+}
+func (m *GetArticleReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetArticleReply.Merge(m, src)
+}
+func (m *GetArticleReply) XXX_Size() int {
+	return xxx_messageInfo_GetArticleReply.Size(m)
+}
+func (m *GetArticleReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetArticleReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetArticleReply proto.InternalMessageInfo
+
+func (m *GetArticleReply) GetArticle() *Article {
+	if m != nil {
+		return m.Article
+	}
+	return nil
+}
+
+func (m *GetArticleReply) GetContent() string {
+	if m != nil {
+		return m.Content
+	}
+	return ""
+}
+
 type Article struct {
-	Title                string   `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
-	Url                  string   `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
-	Source               string   `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
-	Time                 string   `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
-	Reltime              string   `protobuf:"bytes,5,opt,name=reltime,proto3" json:"reltime,omitempty"`
+	Title   string   `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Url     string   `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Source  string   `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Time    string   `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	Reltime string   `protobuf:"bytes,5,opt,name=reltime,proto3" json:"reltime,omitempty"`
+	Tags    []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	Id      string   `protobuf:"bytes,7,opt,name=id,proto3" json:"id,omitempty"`
+	// original_url is the url as given by the feed,
+	// url has tracking parameters removed and redirect wrappers resolved
+	OriginalUrl string `protobuf:"bytes,8,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	// cluster_id is set if the article is part of a cluster
+	ClusterId string `protobuf:"bytes,9,opt,name=cluster_id,json=clusterId,proto3" json:"cluster_id,omitempty"`
+	// episode is set for podcast episodes
+	Episode *Episode `protobuf:"bytes,10,opt,name=episode,proto3" json:"episode,omitempty"`
+	// archived_url is the snapshot of url taken when the article was first seen,
+	// for subscriptions with archiving
+	ArchivedUrl string `protobuf:"bytes,11,opt,name=archived_url,json=archivedUrl,proto3" json:"archived_url,omitempty"`
+	// source_icon_url is the icon of the feed the article is from
+	SourceIconUrl        string   `protobuf:"bytes,12,opt,name=source_icon_url,json=sourceIconUrl,proto3" json:"source_icon_url,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -109,7 +436,7 @@ func (m *Article) Reset()         { *m = Article{} }
 func (m *Article) String() string { return proto.CompactTextString(m) }
 func (*Article) ProtoMessage()    {}
 func (*Article) Descriptor() ([]byte, []int) {
-	return fileDescriptor_14e5489cbafef27c, []int{2}
+	return fileDescriptor_14e5489cbafef27c, []int{8}
 }
 
 func (m *Article) XXX_Unmarshal(b []byte) error {
@@ -165,29 +492,220 @@ func (m *Article) GetReltime() string {
 	return ""
 }
 
+func (m *Article) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Article) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Article) GetOriginalUrl() string {
+	if m != nil {
+		return m.OriginalUrl
+	}
+	return ""
+}
+
+func (m *Article) GetClusterId() string {
+	if m != nil {
+		return m.ClusterId
+	}
+	return ""
+}
+
+func (m *Article) GetEpisode() *Episode {
+	if m != nil {
+		return m.Episode
+	}
+	return nil
+}
+
+func (m *Article) GetArchivedUrl() string {
+	if m != nil {
+		return m.ArchivedUrl
+	}
+	return ""
+}
+
+func (m *Article) GetSourceIconUrl() string {
+	if m != nil {
+		return m.SourceIconUrl
+	}
+	return ""
+}
+
+type Episode struct {
+	// audio_url is the downloaded copy if archived,
+	// otherwise the enclosure from the feed
+	AudioUrl         string `protobuf:"bytes,1,opt,name=audio_url,json=audioUrl,proto3" json:"audio_url,omitempty"`
+	OriginalAudioUrl string `protobuf:"bytes,2,opt,name=original_audio_url,json=originalAudioUrl,proto3" json:"original_audio_url,omitempty"`
+	AudioType        string `protobuf:"bytes,3,opt,name=audio_type,json=audioType,proto3" json:"audio_type,omitempty"`
+	// audio_length is in bytes, 0 if unknown
+	AudioLength int64 `protobuf:"varint,4,opt,name=audio_length,json=audioLength,proto3" json:"audio_length,omitempty"`
+	// duration is in seconds, 0 if unknown
+	Duration   int64  `protobuf:"varint,5,opt,name=duration,proto3" json:"duration,omitempty"`
+	Episode    int32  `protobuf:"varint,6,opt,name=episode,proto3" json:"episode,omitempty"`
+	Season     int32  `protobuf:"varint,7,opt,name=season,proto3" json:"season,omitempty"`
+	ArtworkUrl string `protobuf:"bytes,8,opt,name=artwork_url,json=artworkUrl,proto3" json:"artwork_url,omitempty"`
+	// archived is true if audio_url is served by readss
+	Archived             bool     `protobuf:"varint,9,opt,name=archived,proto3" json:"archived,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Episode) Reset()         { *m = Episode{} }
+func (m *Episode) String() string { return proto.CompactTextString(m) }
+func (*Episode) ProtoMessage()    {}
+func (*Episode) Descriptor() ([]byte, []int) {
+	return fileDescriptor_14e5489cbafef27c, []int{9}
+}
+
+func (m *Episode) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Episode.Unmarshal(m, b)
+}
+func (m *Episode) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Episode.Marshal(b, m, deterministic)
+}
+func (m *Episode) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Episode.Merge(m, src)
+}
+func (m *Episode) XXX_Size() int {
+	return xxx_messageInfo_Episode.Size(m)
+}
+func (m *Episode) XXX_DiscardUnknown() {
+	xxx_messageInfo_Episode.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Episode proto.InternalMessageInfo
+
+func (m *Episode) GetAudioUrl() string {
+	if m != nil {
+		return m.AudioUrl
+	}
+	return ""
+}
+
+func (m *Episode) GetOriginalAudioUrl() string {
+	if m != nil {
+		return m.OriginalAudioUrl
+	}
+	return ""
+}
+
+func (m *Episode) GetAudioType() string {
+	if m != nil {
+		return m.AudioType
+	}
+	return ""
+}
+
+func (m *Episode) GetAudioLength() int64 {
+	if m != nil {
+		return m.AudioLength
+	}
+	return 0
+}
+
+func (m *Episode) GetDuration() int64 {
+	if m != nil {
+		return m.Duration
+	}
+	return 0
+}
+
+func (m *Episode) GetEpisode() int32 {
+	if m != nil {
+		return m.Episode
+	}
+	return 0
+}
+
+func (m *Episode) GetSeason() int32 {
+	if m != nil {
+		return m.Season
+	}
+	return 0
+}
+
+func (m *Episode) GetArtworkUrl() string {
+	if m != nil {
+		return m.ArtworkUrl
+	}
+	return ""
+}
+
+func (m *Episode) GetArchived() bool {
+	if m != nil {
+		return m.Archived
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterType((*ListRequest)(nil), "readss.ListRequest")
 	proto.RegisterType((*ListReply)(nil), "readss.ListReply")
+	proto.RegisterType((*Cluster)(nil), "readss.Cluster")
+	proto.RegisterType((*SearchRequest)(nil), "readss.SearchRequest")
+	proto.RegisterType((*SearchReply)(nil), "readss.SearchReply")
+	proto.RegisterType((*SearchResult)(nil), "readss.SearchResult")
+	proto.RegisterType((*GetArticleRequest)(nil), "readss.GetArticleRequest")
+	proto.RegisterType((*GetArticleReply)(nil), "readss.GetArticleReply")
 	proto.RegisterType((*Article)(nil), "readss.Article")
+	proto.RegisterType((*Episode)(nil), "readss.Episode")
 }
 
 func init() { proto.RegisterFile("readss.proto", fileDescriptor_14e5489cbafef27c) }
 
 var fileDescriptor_14e5489cbafef27c = []byte{
-	// 200 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x90, 0x41, 0xab, 0x82, 0x40,
-	0x10, 0xc7, 0xf1, 0xa9, 0xeb, 0x73, 0x7c, 0x8f, 0x6a, 0x8a, 0x58, 0x3a, 0x89, 0x27, 0x21, 0xf0,
-	0x60, 0x17, 0xaf, 0xdd, 0x3b, 0xf9, 0x0d, 0xcc, 0xe6, 0xb0, 0xb0, 0xa1, 0xed, 0xae, 0x84, 0xdf,
-	0x3e, 0xdc, 0xd5, 0xa8, 0xdb, 0xff, 0xf7, 0x63, 0x18, 0xfe, 0x33, 0xf0, 0xa7, 0xa8, 0xb9, 0x69,
-	0x5d, 0xf4, 0xaa, 0x33, 0x1d, 0x32, 0x47, 0xd9, 0x3f, 0x24, 0x17, 0xa1, 0x4d, 0x4d, 0x8f, 0x81,
-	0xb4, 0xc9, 0x2a, 0x88, 0x1d, 0xf6, 0x72, 0xc4, 0x23, 0xfc, 0x36, 0xca, 0x88, 0x56, 0x92, 0xe6,
-	0x5e, 0xea, 0xe7, 0x49, 0xb9, 0x2a, 0xe6, 0x25, 0x67, 0xe7, 0xeb, 0xf7, 0x40, 0xf6, 0x84, 0x68,
-	0x96, 0xb8, 0x83, 0xd0, 0x08, 0x23, 0x89, 0x7b, 0xa9, 0x97, 0xc7, 0xb5, 0x03, 0x5c, 0x83, 0x3f,
-	0x28, 0xc9, 0x7f, 0xac, 0x9b, 0x22, 0xee, 0x81, 0xe9, 0x6e, 0x50, 0x2d, 0x71, 0xdf, 0xca, 0x99,
-	0x10, 0x21, 0x30, 0xe2, 0x4e, 0x3c, 0xb0, 0xd6, 0x66, 0xe4, 0x10, 0x29, 0x92, 0x56, 0x87, 0x56,
-	0x2f, 0x58, 0x56, 0xc0, 0xa6, 0xca, 0xa4, 0xb0, 0x80, 0x60, 0x4a, 0xb8, 0x5d, 0x5a, 0x7e, 0x5c,
-	0x76, 0xd8, 0x7c, 0xcb, 0x5e, 0x8e, 0x57, 0x66, 0x5f, 0x71, 0x7a, 0x05, 0x00, 0x00, 0xff, 0xff,
-	0x77, 0x3d, 0x06, 0x34, 0x1a, 0x01, 0x00, 0x00,
+	// 636 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x6e, 0xd4, 0x3c,
+	0x10, 0xd5, 0x66, 0xbb, 0xf9, 0x99, 0x6c, 0xbf, 0xb6, 0x6e, 0x3f, 0x30, 0x8b, 0x2a, 0xda, 0x5c,
+	0xa0, 0x22, 0xd0, 0x5e, 0x14, 0x24, 0xc4, 0x05, 0x12, 0x15, 0x42, 0xa8, 0x52, 0xaf, 0x42, 0xcb,
+	0xed, 0x2a, 0x24, 0x56, 0x6b, 0x61, 0xe2, 0xd4, 0x76, 0x40, 0xfb, 0x38, 0xbc, 0x01, 0xef, 0xc2,
+	0x0b, 0x21, 0x8f, 0xed, 0x74, 0xbb, 0x54, 0x95, 0xb8, 0xf3, 0x9c, 0x39, 0xf6, 0xcc, 0x39, 0x63,
+	0x1b, 0xa6, 0x8a, 0x55, 0x8d, 0xd6, 0xf3, 0x4e, 0x49, 0x23, 0x49, 0xec, 0xa2, 0xe2, 0x10, 0xf2,
+	0x33, 0xae, 0x4d, 0xc9, 0xae, 0x7b, 0xa6, 0x0d, 0x21, 0xb0, 0xd1, 0x6b, 0xa6, 0xe8, 0xe8, 0x60,
+	0x74, 0x94, 0x95, 0xb8, 0x2e, 0x18, 0x64, 0x8e, 0xd2, 0x89, 0x25, 0x79, 0x0e, 0x69, 0xa5, 0x0c,
+	0xaf, 0x05, 0xd3, 0x74, 0x74, 0x30, 0x3e, 0xca, 0x8f, 0xb7, 0xe6, 0xfe, 0xe0, 0x13, 0x87, 0x97,
+	0x03, 0xc1, 0x92, 0x6b, 0xd1, 0x6b, 0xc3, 0x94, 0xa6, 0xd1, 0x6d, 0xf2, 0x7b, 0x87, 0x97, 0x03,
+	0xa1, 0x38, 0x87, 0xc4, 0x83, 0xe4, 0x3f, 0x88, 0x78, 0xe3, 0x7b, 0x88, 0x78, 0x43, 0x28, 0x24,
+	0x5a, 0xf6, 0xaa, 0x66, 0xee, 0x98, 0xac, 0x0c, 0x21, 0x79, 0x02, 0xb9, 0xaf, 0xb6, 0xe0, 0x8d,
+	0xa6, 0x63, 0xcc, 0x82, 0x87, 0x4e, 0x1b, 0x5d, 0xbc, 0x81, 0xcd, 0x4f, 0xac, 0x52, 0xf5, 0xd5,
+	0x3d, 0x0a, 0xc9, 0x1e, 0x4c, 0xae, 0x7b, 0xa6, 0x96, 0x34, 0x42, 0xd0, 0x05, 0xc5, 0x5b, 0xc8,
+	0xc3, 0x56, 0xab, 0x7c, 0x0e, 0x89, 0x62, 0xba, 0x17, 0x26, 0x08, 0xdf, 0x0b, 0x5a, 0x02, 0xcb,
+	0x26, 0xcb, 0x40, 0x2a, 0x38, 0x4c, 0x57, 0x13, 0xe4, 0x19, 0x24, 0xbe, 0x2f, 0xac, 0x7d, 0x87,
+	0x71, 0x21, 0x6f, 0xfb, 0x31, 0xdc, 0x08, 0x16, 0xfa, 0xc1, 0x00, 0x5d, 0x68, 0x79, 0xd7, 0x31,
+	0x43, 0xc7, 0x88, 0x87, 0xb0, 0x78, 0x0d, 0x3b, 0x1f, 0x99, 0x09, 0xc7, 0xdc, 0x23, 0xd4, 0x19,
+	0x1b, 0x05, 0x63, 0x8b, 0xcf, 0xb0, 0xb5, 0xba, 0xd1, 0xca, 0xfc, 0x87, 0x36, 0x29, 0x24, 0xb5,
+	0x6c, 0x0d, 0x6b, 0x8d, 0x3f, 0x32, 0x84, 0xc5, 0xef, 0x08, 0x92, 0x93, 0x75, 0x31, 0xa3, 0x55,
+	0x31, 0xdb, 0x30, 0xee, 0x95, 0xf0, 0xfb, 0xec, 0x92, 0x3c, 0x80, 0xd8, 0x4d, 0xd5, 0xab, 0xf3,
+	0x91, 0xd5, 0x61, 0xf8, 0x37, 0x46, 0x37, 0x9c, 0x0e, 0xbb, 0xb6, 0x95, 0x15, 0x13, 0x08, 0x4f,
+	0x5c, 0x65, 0x1f, 0x22, 0xbb, 0xba, 0xd4, 0x34, 0xc6, 0x9b, 0x80, 0x6b, 0xaf, 0x3a, 0x19, 0xae,
+	0xd3, 0x21, 0x4c, 0xa5, 0xe2, 0x97, 0xbc, 0xad, 0xc4, 0xc2, 0x36, 0x91, 0x62, 0x26, 0x0f, 0xd8,
+	0x85, 0x12, 0x64, 0x1f, 0xc0, 0x5f, 0xcc, 0x05, 0x6f, 0x68, 0x86, 0x84, 0xcc, 0x23, 0xa7, 0x8d,
+	0x35, 0x89, 0x75, 0x5c, 0xcb, 0x86, 0x51, 0xb8, 0x6d, 0xd2, 0x07, 0x07, 0x97, 0x21, 0x6f, 0x8b,
+	0xd9, 0x4b, 0xc0, 0xbf, 0xb3, 0x06, 0x8b, 0xe5, 0xae, 0x58, 0xc0, 0x6c, 0xb1, 0xa7, 0xb0, 0xe5,
+	0xb4, 0x2e, 0x78, 0x2d, 0x5b, 0x64, 0x4d, 0x91, 0xb5, 0xe9, 0xe0, 0xd3, 0x5a, 0xb6, 0x17, 0x4a,
+	0x14, 0x3f, 0x23, 0x48, 0xfc, 0xf9, 0xe4, 0x31, 0x64, 0x55, 0xdf, 0x70, 0x89, 0x6c, 0xe7, 0x6c,
+	0x8a, 0x80, 0x3d, 0xf0, 0x05, 0x90, 0x41, 0xe0, 0x0d, 0xcb, 0x79, 0xbd, 0x1d, 0x32, 0x27, 0x81,
+	0xbd, 0x0f, 0xe0, 0x48, 0x66, 0xd9, 0x05, 0xf3, 0xdd, 0xe1, 0xe7, 0xcb, 0xce, 0x09, 0xc0, 0xb4,
+	0x60, 0xed, 0xa5, 0xb9, 0xc2, 0x39, 0x8c, 0xcb, 0x1c, 0xb1, 0x33, 0x84, 0xc8, 0x0c, 0xd2, 0xa6,
+	0x57, 0x95, 0xe1, 0xb2, 0xc5, 0x79, 0x8c, 0xcb, 0x21, 0xb6, 0xa3, 0x0a, 0x56, 0xc5, 0x07, 0xa3,
+	0xa3, 0xc9, 0x8d, 0x33, 0x76, 0xe0, 0xac, 0xd2, 0xb2, 0xc5, 0xd1, 0x4c, 0x4a, 0x1f, 0xf9, 0x37,
+	0xfd, 0x43, 0xaa, 0xaf, 0x2b, 0xd3, 0x01, 0x0f, 0xd9, 0x86, 0x67, 0x90, 0x06, 0xfb, 0x70, 0x34,
+	0x69, 0x39, 0xc4, 0xc7, 0xbf, 0x46, 0x10, 0xdb, 0xdf, 0x8a, 0x29, 0x32, 0x87, 0x0d, 0xbb, 0x22,
+	0xbb, 0x61, 0x36, 0x2b, 0x1f, 0xdd, 0x6c, 0xe7, 0x36, 0x68, 0x6f, 0xfe, 0x2b, 0x88, 0xdd, 0x83,
+	0x25, 0xff, 0xaf, 0xbf, 0x6c, 0xb7, 0x67, 0x77, 0x1d, 0xb6, 0xbb, 0xde, 0x01, 0xdc, 0x3c, 0x21,
+	0xf2, 0x28, 0x50, 0xfe, 0x7a, 0x8f, 0xb3, 0x87, 0x77, 0xa5, 0x3a, 0xb1, 0xfc, 0x12, 0xe3, 0x8f,
+	0xfc, 0xf2, 0xcf, 0x00, 0xce, 0xd3, 0xc0, 0x6f, 0xa1, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -203,6 +721,8 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type ListerClient interface {
 	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReply, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error)
+	GetArticle(ctx context.Context, in *GetArticleRequest, opts ...grpc.CallOption) (*GetArticleReply, error)
 }
 
 type listerClient struct {
@@ -222,9 +742,29 @@ func (c *listerClient) List(ctx context.Context, in *ListRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *listerClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error) {
+	out := new(SearchReply)
+	err := c.cc.Invoke(ctx, "/readss.Lister/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *listerClient) GetArticle(ctx context.Context, in *GetArticleRequest, opts ...grpc.CallOption) (*GetArticleReply, error) {
+	out := new(GetArticleReply)
+	err := c.cc.Invoke(ctx, "/readss.Lister/GetArticle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ListerServer is the server API for Lister service.
 type ListerServer interface {
 	List(context.Context, *ListRequest) (*ListReply, error)
+	Search(context.Context, *SearchRequest) (*SearchReply, error)
+	GetArticle(context.Context, *GetArticleRequest) (*GetArticleReply, error)
 }
 
 // UnimplementedListerServer can be embedded to have forward compatible implementations.
@@ -234,6 +774,12 @@ type UnimplementedListerServer struct {
 func (*UnimplementedListerServer) List(ctx context.Context, req *ListRequest) (*ListReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
 }
+func (*UnimplementedListerServer) Search(ctx context.Context, req *SearchRequest) (*SearchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (*UnimplementedListerServer) GetArticle(ctx context.Context, req *GetArticleRequest) (*GetArticleReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetArticle not implemented")
+}
 
 func RegisterListerServer(s *grpc.Server, srv ListerServer) {
 	s.RegisterService(&_Lister_serviceDesc, srv)
@@ -257,6 +803,42 @@ func _Lister_List_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lister_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ListerServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/readss.Lister/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ListerServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lister_GetArticle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetArticleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ListerServer).GetArticle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/readss.Lister/GetArticle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ListerServer).GetArticle(ctx, req.(*GetArticleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Lister_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "readss.Lister",
 	HandlerType: (*ListerServer)(nil),
@@ -265,6 +847,14 @@ var _Lister_serviceDesc = grpc.ServiceDesc{
 			MethodName: "List",
 			Handler:    _Lister_List_Handler,
 		},
+		{
+			MethodName: "Search",
+			Handler:    _Lister_Search_Handler,
+		},
+		{
+			MethodName: "GetArticle",
+			Handler:    _Lister_GetArticle_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "readss.proto",
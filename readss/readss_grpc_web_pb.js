@@ -141,5 +141,115 @@ proto.readss.ListerPromiseClient.prototype.list =
 };
 
 
+/**
+ * @const
+ * @type {!grpc.web.AbstractClientBase.MethodInfo<
+ *   !proto.readss.SearchRequest,
+ *   !proto.readss.SearchReply>}
+ */
+const methodInfo_Lister_Search = new grpc.web.AbstractClientBase.MethodInfo(
+  proto.readss.SearchReply,
+  /** @param {!proto.readss.SearchRequest} request */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.readss.SearchReply.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.readss.SearchRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.Error, ?proto.readss.SearchReply)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.readss.SearchReply>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.readss.ListerClient.prototype.search =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/readss.Lister/Search',
+      request,
+      metadata || {},
+      methodInfo_Lister_Search,
+      callback);
+};
+
+
+/**
+ * @param {!proto.readss.SearchRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.readss.SearchReply>}
+ *     A native promise that resolves to the response
+ */
+proto.readss.ListerPromiseClient.prototype.search =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/readss.Lister/Search',
+      request,
+      metadata || {},
+      methodInfo_Lister_Search);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.AbstractClientBase.MethodInfo<
+ *   !proto.readss.GetArticleRequest,
+ *   !proto.readss.GetArticleReply>}
+ */
+const methodInfo_Lister_GetArticle = new grpc.web.AbstractClientBase.MethodInfo(
+  proto.readss.GetArticleReply,
+  /** @param {!proto.readss.GetArticleRequest} request */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.readss.GetArticleReply.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.readss.GetArticleRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.Error, ?proto.readss.GetArticleReply)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.readss.GetArticleReply>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.readss.ListerClient.prototype.getArticle =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/readss.Lister/GetArticle',
+      request,
+      metadata || {},
+      methodInfo_Lister_GetArticle,
+      callback);
+};
+
+
+/**
+ * @param {!proto.readss.GetArticleRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.readss.GetArticleReply>}
+ *     A native promise that resolves to the response
+ */
+proto.readss.ListerPromiseClient.prototype.getArticle =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/readss.Lister/GetArticle',
+      request,
+      metadata || {},
+      methodInfo_Lister_GetArticle);
+};
+
+
 module.exports = proto.readss;
 
@@ -12,8 +12,15 @@ var goog = jspb;
 var global = Function('return this')();
 
 goog.exportSymbol('proto.readss.Article', null, global);
+goog.exportSymbol('proto.readss.Cluster', null, global);
+goog.exportSymbol('proto.readss.Episode', null, global);
+goog.exportSymbol('proto.readss.GetArticleReply', null, global);
+goog.exportSymbol('proto.readss.GetArticleRequest', null, global);
 goog.exportSymbol('proto.readss.ListReply', null, global);
 goog.exportSymbol('proto.readss.ListRequest', null, global);
+goog.exportSymbol('proto.readss.SearchReply', null, global);
+goog.exportSymbol('proto.readss.SearchRequest', null, global);
+goog.exportSymbol('proto.readss.SearchResult', null, global);
 
 /**
  * Generated by JsPbCodeGenerator.
@@ -61,7 +68,7 @@ proto.readss.ListRequest.prototype.toObject = function(opt_includeInstance) {
  */
 proto.readss.ListRequest.toObject = function(includeInstance, msg) {
   var f, obj = {
-
+    user: jspb.Message.getFieldWithDefault(msg, 1, "")
   };
 
   if (includeInstance) {
@@ -98,6 +105,10 @@ proto.readss.ListRequest.deserializeBinaryFromReader = function(msg, reader) {
     }
     var field = reader.getFieldNumber();
     switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setUser(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -127,6 +138,28 @@ proto.readss.ListRequest.prototype.serializeBinary = function() {
  */
 proto.readss.ListRequest.serializeBinaryToWriter = function(message, writer) {
   var f = undefined;
+  f = message.getUser();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string user = 1;
+ * @return {string}
+ */
+proto.readss.ListRequest.prototype.getUser = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.ListRequest.prototype.setUser = function(value) {
+  jspb.Message.setProto3StringField(this, 1, value);
 };
 
 
@@ -153,7 +186,7 @@ if (goog.DEBUG && !COMPILED) {
  * @private {!Array<number>}
  * @const
  */
-proto.readss.ListReply.repeatedFields_ = [1];
+proto.readss.ListReply.repeatedFields_ = [1,2];
 
 
 
@@ -185,7 +218,9 @@ proto.readss.ListReply.prototype.toObject = function(opt_includeInstance) {
 proto.readss.ListReply.toObject = function(includeInstance, msg) {
   var f, obj = {
     articlesList: jspb.Message.toObjectList(msg.getArticlesList(),
-    proto.readss.Article.toObject, includeInstance)
+    proto.readss.Article.toObject, includeInstance),
+    clustersList: jspb.Message.toObjectList(msg.getClustersList(),
+    proto.readss.Cluster.toObject, includeInstance)
   };
 
   if (includeInstance) {
@@ -227,6 +262,11 @@ proto.readss.ListReply.deserializeBinaryFromReader = function(msg, reader) {
       reader.readMessage(value,proto.readss.Article.deserializeBinaryFromReader);
       msg.addArticles(value);
       break;
+    case 2:
+      var value = new proto.readss.Cluster;
+      reader.readMessage(value,proto.readss.Cluster.deserializeBinaryFromReader);
+      msg.addClusters(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -264,6 +304,14 @@ proto.readss.ListReply.serializeBinaryToWriter = function(message, writer) {
       proto.readss.Article.serializeBinaryToWriter
     );
   }
+  f = message.getClustersList();
+  if (f.length > 0) {
+    writer.writeRepeatedMessage(
+      2,
+      f,
+      proto.readss.Cluster.serializeBinaryToWriter
+    );
+  }
 };
 
 
@@ -298,6 +346,37 @@ proto.readss.ListReply.prototype.clearArticlesList = function() {
 };
 
 
+/**
+ * repeated Cluster clusters = 2;
+ * @return {!Array<!proto.readss.Cluster>}
+ */
+proto.readss.ListReply.prototype.getClustersList = function() {
+  return /** @type{!Array<!proto.readss.Cluster>} */ (
+    jspb.Message.getRepeatedWrapperField(this, proto.readss.Cluster, 2));
+};
+
+
+/** @param {!Array<!proto.readss.Cluster>} value */
+proto.readss.ListReply.prototype.setClustersList = function(value) {
+  jspb.Message.setRepeatedWrapperField(this, 2, value);
+};
+
+
+/**
+ * @param {!proto.readss.Cluster=} opt_value
+ * @param {number=} opt_index
+ * @return {!proto.readss.Cluster}
+ */
+proto.readss.ListReply.prototype.addClusters = function(opt_value, opt_index) {
+  return jspb.Message.addToRepeatedWrapperField(this, 2, opt_value, proto.readss.Cluster, opt_index);
+};
+
+
+proto.readss.ListReply.prototype.clearClustersList = function() {
+  this.setClustersList([]);
+};
+
+
 
 /**
  * Generated by JsPbCodeGenerator.
@@ -309,13 +388,20 @@ proto.readss.ListReply.prototype.clearArticlesList = function() {
  * @extends {jspb.Message}
  * @constructor
  */
-proto.readss.Article = function(opt_data) {
-  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+proto.readss.Cluster = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, proto.readss.Cluster.repeatedFields_, null);
 };
-goog.inherits(proto.readss.Article, jspb.Message);
+goog.inherits(proto.readss.Cluster, jspb.Message);
 if (goog.DEBUG && !COMPILED) {
-  proto.readss.Article.displayName = 'proto.readss.Article';
+  proto.readss.Cluster.displayName = 'proto.readss.Cluster';
 }
+/**
+ * List of repeated fields within this message type.
+ * @private {!Array<number>}
+ * @const
+ */
+proto.readss.Cluster.repeatedFields_ = [2,3];
+
 
 
 if (jspb.Message.GENERATE_TO_OBJECT) {
@@ -329,8 +415,8 @@ if (jspb.Message.GENERATE_TO_OBJECT) {
  *     for transitional soy proto support: http://goto/soy-param-migration
  * @return {!Object}
  */
-proto.readss.Article.prototype.toObject = function(opt_includeInstance) {
-  return proto.readss.Article.toObject(opt_includeInstance, this);
+proto.readss.Cluster.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.Cluster.toObject(opt_includeInstance, this);
 };
 
 
@@ -339,17 +425,15 @@ proto.readss.Article.prototype.toObject = function(opt_includeInstance) {
  * @param {boolean|undefined} includeInstance Whether to include the JSPB
  *     instance for transitional soy proto support:
  *     http://goto/soy-param-migration
- * @param {!proto.readss.Article} msg The msg instance to transform.
+ * @param {!proto.readss.Cluster} msg The msg instance to transform.
  * @return {!Object}
  * @suppress {unusedLocalVariables} f is only used for nested messages
  */
-proto.readss.Article.toObject = function(includeInstance, msg) {
+proto.readss.Cluster.toObject = function(includeInstance, msg) {
   var f, obj = {
-    title: jspb.Message.getFieldWithDefault(msg, 1, ""),
-    url: jspb.Message.getFieldWithDefault(msg, 2, ""),
-    source: jspb.Message.getFieldWithDefault(msg, 3, ""),
-    time: jspb.Message.getFieldWithDefault(msg, 4, ""),
-    reltime: jspb.Message.getFieldWithDefault(msg, 5, "")
+    id: jspb.Message.getFieldWithDefault(msg, 1, ""),
+    sourcesList: (f = jspb.Message.getRepeatedField(msg, 2)) == null ? undefined : f,
+    articleIdsList: (f = jspb.Message.getRepeatedField(msg, 3)) == null ? undefined : f
   };
 
   if (includeInstance) {
@@ -363,23 +447,23 @@ proto.readss.Article.toObject = function(includeInstance, msg) {
 /**
  * Deserializes binary data (in protobuf wire format).
  * @param {jspb.ByteSource} bytes The bytes to deserialize.
- * @return {!proto.readss.Article}
+ * @return {!proto.readss.Cluster}
  */
-proto.readss.Article.deserializeBinary = function(bytes) {
+proto.readss.Cluster.deserializeBinary = function(bytes) {
   var reader = new jspb.BinaryReader(bytes);
-  var msg = new proto.readss.Article;
-  return proto.readss.Article.deserializeBinaryFromReader(msg, reader);
+  var msg = new proto.readss.Cluster;
+  return proto.readss.Cluster.deserializeBinaryFromReader(msg, reader);
 };
 
 
 /**
  * Deserializes binary data (in protobuf wire format) from the
  * given reader into the given message object.
- * @param {!proto.readss.Article} msg The message object to deserialize into.
+ * @param {!proto.readss.Cluster} msg The message object to deserialize into.
  * @param {!jspb.BinaryReader} reader The BinaryReader to use.
- * @return {!proto.readss.Article}
+ * @return {!proto.readss.Cluster}
  */
-proto.readss.Article.deserializeBinaryFromReader = function(msg, reader) {
+proto.readss.Cluster.deserializeBinaryFromReader = function(msg, reader) {
   while (reader.nextField()) {
     if (reader.isEndGroup()) {
       break;
@@ -388,23 +472,15 @@ proto.readss.Article.deserializeBinaryFromReader = function(msg, reader) {
     switch (field) {
     case 1:
       var value = /** @type {string} */ (reader.readString());
-      msg.setTitle(value);
+      msg.setId(value);
       break;
     case 2:
       var value = /** @type {string} */ (reader.readString());
-      msg.setUrl(value);
+      msg.addSources(value);
       break;
     case 3:
       var value = /** @type {string} */ (reader.readString());
-      msg.setSource(value);
-      break;
-    case 4:
-      var value = /** @type {string} */ (reader.readString());
-      msg.setTime(value);
-      break;
-    case 5:
-      var value = /** @type {string} */ (reader.readString());
-      msg.setReltime(value);
+      msg.addArticleIds(value);
       break;
     default:
       reader.skipField();
@@ -419,9 +495,9 @@ proto.readss.Article.deserializeBinaryFromReader = function(msg, reader) {
  * Serializes the message to binary data (in protobuf wire format).
  * @return {!Uint8Array}
  */
-proto.readss.Article.prototype.serializeBinary = function() {
+proto.readss.Cluster.prototype.serializeBinary = function() {
   var writer = new jspb.BinaryWriter();
-  proto.readss.Article.serializeBinaryToWriter(this, writer);
+  proto.readss.Cluster.serializeBinaryToWriter(this, writer);
   return writer.getResultBuffer();
 };
 
@@ -429,122 +505,1855 @@ proto.readss.Article.prototype.serializeBinary = function() {
 /**
  * Serializes the given message to binary data (in protobuf wire
  * format), writing to the given BinaryWriter.
- * @param {!proto.readss.Article} message
+ * @param {!proto.readss.Cluster} message
  * @param {!jspb.BinaryWriter} writer
  * @suppress {unusedLocalVariables} f is only used for nested messages
  */
-proto.readss.Article.serializeBinaryToWriter = function(message, writer) {
+proto.readss.Cluster.serializeBinaryToWriter = function(message, writer) {
   var f = undefined;
-  f = message.getTitle();
+  f = message.getId();
   if (f.length > 0) {
     writer.writeString(
       1,
       f
     );
   }
-  f = message.getUrl();
+  f = message.getSourcesList();
   if (f.length > 0) {
-    writer.writeString(
+    writer.writeRepeatedString(
       2,
       f
     );
   }
-  f = message.getSource();
+  f = message.getArticleIdsList();
   if (f.length > 0) {
-    writer.writeString(
+    writer.writeRepeatedString(
       3,
       f
     );
   }
-  f = message.getTime();
-  if (f.length > 0) {
-    writer.writeString(
-      4,
-      f
-    );
-  }
-  f = message.getReltime();
-  if (f.length > 0) {
-    writer.writeString(
-      5,
-      f
-    );
-  }
 };
 
 
 /**
- * optional string title = 1;
+ * optional string id = 1;
  * @return {string}
  */
-proto.readss.Article.prototype.getTitle = function() {
+proto.readss.Cluster.prototype.getId = function() {
   return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
 };
 
 
 /** @param {string} value */
-proto.readss.Article.prototype.setTitle = function(value) {
+proto.readss.Cluster.prototype.setId = function(value) {
   jspb.Message.setProto3StringField(this, 1, value);
 };
 
 
 /**
- * optional string url = 2;
- * @return {string}
+ * repeated string sources = 2;
+ * @return {!Array<string>}
  */
-proto.readss.Article.prototype.getUrl = function() {
-  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+proto.readss.Cluster.prototype.getSourcesList = function() {
+  return /** @type {!Array<string>} */ (jspb.Message.getRepeatedField(this, 2));
 };
 
 
-/** @param {string} value */
-proto.readss.Article.prototype.setUrl = function(value) {
-  jspb.Message.setProto3StringField(this, 2, value);
+/** @param {!Array<string>} value */
+proto.readss.Cluster.prototype.setSourcesList = function(value) {
+  jspb.Message.setField(this, 2, value || []);
 };
 
 
 /**
- * optional string source = 3;
- * @return {string}
+ * @param {string} value
+ * @param {number=} opt_index
  */
-proto.readss.Article.prototype.getSource = function() {
-  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 3, ""));
+proto.readss.Cluster.prototype.addSources = function(value, opt_index) {
+  jspb.Message.addToRepeatedField(this, 2, value, opt_index);
 };
 
 
-/** @param {string} value */
-proto.readss.Article.prototype.setSource = function(value) {
-  jspb.Message.setProto3StringField(this, 3, value);
+proto.readss.Cluster.prototype.clearSourcesList = function() {
+  this.setSourcesList([]);
 };
 
 
 /**
- * optional string time = 4;
- * @return {string}
+ * repeated string article_ids = 3;
+ * @return {!Array<string>}
  */
-proto.readss.Article.prototype.getTime = function() {
-  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 4, ""));
+proto.readss.Cluster.prototype.getArticleIdsList = function() {
+  return /** @type {!Array<string>} */ (jspb.Message.getRepeatedField(this, 3));
 };
 
 
-/** @param {string} value */
-proto.readss.Article.prototype.setTime = function(value) {
-  jspb.Message.setProto3StringField(this, 4, value);
+/** @param {!Array<string>} value */
+proto.readss.Cluster.prototype.setArticleIdsList = function(value) {
+  jspb.Message.setField(this, 3, value || []);
 };
 
 
 /**
- * optional string reltime = 5;
- * @return {string}
+ * @param {string} value
+ * @param {number=} opt_index
  */
-proto.readss.Article.prototype.getReltime = function() {
-  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 5, ""));
+proto.readss.Cluster.prototype.addArticleIds = function(value, opt_index) {
+  jspb.Message.addToRepeatedField(this, 3, value, opt_index);
 };
 
 
-/** @param {string} value */
-proto.readss.Article.prototype.setReltime = function(value) {
-  jspb.Message.setProto3StringField(this, 5, value);
+proto.readss.Cluster.prototype.clearArticleIdsList = function() {
+  this.setArticleIdsList([]);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.SearchRequest = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.readss.SearchRequest, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.SearchRequest.displayName = 'proto.readss.SearchRequest';
+}
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.SearchRequest.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.SearchRequest.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.SearchRequest} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchRequest.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    user: jspb.Message.getFieldWithDefault(msg, 1, ""),
+    query: jspb.Message.getFieldWithDefault(msg, 2, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.SearchRequest}
+ */
+proto.readss.SearchRequest.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.SearchRequest;
+  return proto.readss.SearchRequest.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.SearchRequest} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.SearchRequest}
+ */
+proto.readss.SearchRequest.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setUser(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setQuery(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.SearchRequest.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.SearchRequest.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.SearchRequest} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchRequest.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getUser();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getQuery();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string user = 1;
+ * @return {string}
+ */
+proto.readss.SearchRequest.prototype.getUser = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.SearchRequest.prototype.setUser = function(value) {
+  jspb.Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional string query = 2;
+ * @return {string}
+ */
+proto.readss.SearchRequest.prototype.getQuery = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.SearchRequest.prototype.setQuery = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.SearchReply = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, proto.readss.SearchReply.repeatedFields_, null);
+};
+goog.inherits(proto.readss.SearchReply, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.SearchReply.displayName = 'proto.readss.SearchReply';
+}
+/**
+ * List of repeated fields within this message type.
+ * @private {!Array<number>}
+ * @const
+ */
+proto.readss.SearchReply.repeatedFields_ = [1];
+
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.SearchReply.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.SearchReply.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.SearchReply} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchReply.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    resultsList: jspb.Message.toObjectList(msg.getResultsList(),
+    proto.readss.SearchResult.toObject, includeInstance)
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.SearchReply}
+ */
+proto.readss.SearchReply.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.SearchReply;
+  return proto.readss.SearchReply.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.SearchReply} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.SearchReply}
+ */
+proto.readss.SearchReply.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = new proto.readss.SearchResult;
+      reader.readMessage(value,proto.readss.SearchResult.deserializeBinaryFromReader);
+      msg.addResults(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.SearchReply.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.SearchReply.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.SearchReply} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchReply.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getResultsList();
+  if (f.length > 0) {
+    writer.writeRepeatedMessage(
+      1,
+      f,
+      proto.readss.SearchResult.serializeBinaryToWriter
+    );
+  }
+};
+
+
+/**
+ * repeated SearchResult results = 1;
+ * @return {!Array<!proto.readss.SearchResult>}
+ */
+proto.readss.SearchReply.prototype.getResultsList = function() {
+  return /** @type{!Array<!proto.readss.SearchResult>} */ (
+    jspb.Message.getRepeatedWrapperField(this, proto.readss.SearchResult, 1));
+};
+
+
+/** @param {!Array<!proto.readss.SearchResult>} value */
+proto.readss.SearchReply.prototype.setResultsList = function(value) {
+  jspb.Message.setRepeatedWrapperField(this, 1, value);
+};
+
+
+/**
+ * @param {!proto.readss.SearchResult=} opt_value
+ * @param {number=} opt_index
+ * @return {!proto.readss.SearchResult}
+ */
+proto.readss.SearchReply.prototype.addResults = function(opt_value, opt_index) {
+  return jspb.Message.addToRepeatedWrapperField(this, 1, opt_value, proto.readss.SearchResult, opt_index);
+};
+
+
+proto.readss.SearchReply.prototype.clearResultsList = function() {
+  this.setResultsList([]);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.SearchResult = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.readss.SearchResult, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.SearchResult.displayName = 'proto.readss.SearchResult';
+}
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.SearchResult.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.SearchResult.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.SearchResult} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchResult.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    article: (f = msg.getArticle()) && proto.readss.Article.toObject(includeInstance, f),
+    title: jspb.Message.getFieldWithDefault(msg, 2, ""),
+    snippet: jspb.Message.getFieldWithDefault(msg, 3, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.SearchResult}
+ */
+proto.readss.SearchResult.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.SearchResult;
+  return proto.readss.SearchResult.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.SearchResult} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.SearchResult}
+ */
+proto.readss.SearchResult.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = new proto.readss.Article;
+      reader.readMessage(value,proto.readss.Article.deserializeBinaryFromReader);
+      msg.setArticle(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setTitle(value);
+      break;
+    case 3:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setSnippet(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.SearchResult.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.SearchResult.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.SearchResult} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.SearchResult.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getArticle();
+  if (f != null) {
+    writer.writeMessage(
+      1,
+      f,
+      proto.readss.Article.serializeBinaryToWriter
+    );
+  }
+  f = message.getTitle();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+  f = message.getSnippet();
+  if (f.length > 0) {
+    writer.writeString(
+      3,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional Article article = 1;
+ * @return {?proto.readss.Article}
+ */
+proto.readss.SearchResult.prototype.getArticle = function() {
+  return /** @type{?proto.readss.Article} */ (
+    jspb.Message.getWrapperField(this, proto.readss.Article, 1));
+};
+
+
+/** @param {?proto.readss.Article|undefined} value */
+proto.readss.SearchResult.prototype.setArticle = function(value) {
+  jspb.Message.setWrapperField(this, 1, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ */
+proto.readss.SearchResult.prototype.clearArticle = function() {
+  this.setArticle(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.readss.SearchResult.prototype.hasArticle = function() {
+  return jspb.Message.getField(this, 1) != null;
+};
+
+
+/**
+ * optional string title = 2;
+ * @return {string}
+ */
+proto.readss.SearchResult.prototype.getTitle = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.SearchResult.prototype.setTitle = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+/**
+ * optional string snippet = 3;
+ * @return {string}
+ */
+proto.readss.SearchResult.prototype.getSnippet = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 3, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.SearchResult.prototype.setSnippet = function(value) {
+  jspb.Message.setProto3StringField(this, 3, value);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.GetArticleRequest = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.readss.GetArticleRequest, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.GetArticleRequest.displayName = 'proto.readss.GetArticleRequest';
+}
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.GetArticleRequest.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.GetArticleRequest.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.GetArticleRequest} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.GetArticleRequest.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    user: jspb.Message.getFieldWithDefault(msg, 1, ""),
+    id: jspb.Message.getFieldWithDefault(msg, 2, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.GetArticleRequest}
+ */
+proto.readss.GetArticleRequest.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.GetArticleRequest;
+  return proto.readss.GetArticleRequest.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.GetArticleRequest} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.GetArticleRequest}
+ */
+proto.readss.GetArticleRequest.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setUser(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setId(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.GetArticleRequest.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.GetArticleRequest.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.GetArticleRequest} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.GetArticleRequest.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getUser();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getId();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string user = 1;
+ * @return {string}
+ */
+proto.readss.GetArticleRequest.prototype.getUser = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.GetArticleRequest.prototype.setUser = function(value) {
+  jspb.Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional string id = 2;
+ * @return {string}
+ */
+proto.readss.GetArticleRequest.prototype.getId = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.GetArticleRequest.prototype.setId = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.GetArticleReply = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.readss.GetArticleReply, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.GetArticleReply.displayName = 'proto.readss.GetArticleReply';
+}
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.GetArticleReply.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.GetArticleReply.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.GetArticleReply} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.GetArticleReply.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    article: (f = msg.getArticle()) && proto.readss.Article.toObject(includeInstance, f),
+    content: jspb.Message.getFieldWithDefault(msg, 2, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.GetArticleReply}
+ */
+proto.readss.GetArticleReply.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.GetArticleReply;
+  return proto.readss.GetArticleReply.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.GetArticleReply} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.GetArticleReply}
+ */
+proto.readss.GetArticleReply.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = new proto.readss.Article;
+      reader.readMessage(value,proto.readss.Article.deserializeBinaryFromReader);
+      msg.setArticle(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setContent(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.GetArticleReply.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.GetArticleReply.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.GetArticleReply} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.GetArticleReply.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getArticle();
+  if (f != null) {
+    writer.writeMessage(
+      1,
+      f,
+      proto.readss.Article.serializeBinaryToWriter
+    );
+  }
+  f = message.getContent();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional Article article = 1;
+ * @return {?proto.readss.Article}
+ */
+proto.readss.GetArticleReply.prototype.getArticle = function() {
+  return /** @type{?proto.readss.Article} */ (
+    jspb.Message.getWrapperField(this, proto.readss.Article, 1));
+};
+
+
+/** @param {?proto.readss.Article|undefined} value */
+proto.readss.GetArticleReply.prototype.setArticle = function(value) {
+  jspb.Message.setWrapperField(this, 1, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ */
+proto.readss.GetArticleReply.prototype.clearArticle = function() {
+  this.setArticle(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.readss.GetArticleReply.prototype.hasArticle = function() {
+  return jspb.Message.getField(this, 1) != null;
+};
+
+
+/**
+ * optional string content = 2;
+ * @return {string}
+ */
+proto.readss.GetArticleReply.prototype.getContent = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.GetArticleReply.prototype.setContent = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.Article = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, proto.readss.Article.repeatedFields_, null);
+};
+goog.inherits(proto.readss.Article, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.Article.displayName = 'proto.readss.Article';
+}
+/**
+ * List of repeated fields within this message type.
+ * @private {!Array<number>}
+ * @const
+ */
+proto.readss.Article.repeatedFields_ = [6];
+
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.Article.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.Article.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.Article} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.Article.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    title: jspb.Message.getFieldWithDefault(msg, 1, ""),
+    url: jspb.Message.getFieldWithDefault(msg, 2, ""),
+    source: jspb.Message.getFieldWithDefault(msg, 3, ""),
+    time: jspb.Message.getFieldWithDefault(msg, 4, ""),
+    reltime: jspb.Message.getFieldWithDefault(msg, 5, ""),
+    tagsList: (f = jspb.Message.getRepeatedField(msg, 6)) == null ? undefined : f,
+    id: jspb.Message.getFieldWithDefault(msg, 7, ""),
+    originalUrl: jspb.Message.getFieldWithDefault(msg, 8, ""),
+    clusterId: jspb.Message.getFieldWithDefault(msg, 9, ""),
+    episode: (f = msg.getEpisode()) && proto.readss.Episode.toObject(includeInstance, f),
+    archivedUrl: jspb.Message.getFieldWithDefault(msg, 11, ""),
+    sourceIconUrl: jspb.Message.getFieldWithDefault(msg, 12, "")
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.Article}
+ */
+proto.readss.Article.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.Article;
+  return proto.readss.Article.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.Article} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.Article}
+ */
+proto.readss.Article.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setTitle(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setUrl(value);
+      break;
+    case 3:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setSource(value);
+      break;
+    case 4:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setTime(value);
+      break;
+    case 5:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setReltime(value);
+      break;
+    case 6:
+      var value = /** @type {string} */ (reader.readString());
+      msg.addTags(value);
+      break;
+    case 7:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setId(value);
+      break;
+    case 8:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setOriginalUrl(value);
+      break;
+    case 9:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setClusterId(value);
+      break;
+    case 10:
+      var value = new proto.readss.Episode;
+      reader.readMessage(value,proto.readss.Episode.deserializeBinaryFromReader);
+      msg.setEpisode(value);
+      break;
+    case 11:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setArchivedUrl(value);
+      break;
+    case 12:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setSourceIconUrl(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.Article.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.Article.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.Article} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.Article.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getTitle();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+  f = message.getSource();
+  if (f.length > 0) {
+    writer.writeString(
+      3,
+      f
+    );
+  }
+  f = message.getTime();
+  if (f.length > 0) {
+    writer.writeString(
+      4,
+      f
+    );
+  }
+  f = message.getReltime();
+  if (f.length > 0) {
+    writer.writeString(
+      5,
+      f
+    );
+  }
+  f = message.getTagsList();
+  if (f.length > 0) {
+    writer.writeRepeatedString(
+      6,
+      f
+    );
+  }
+  f = message.getId();
+  if (f.length > 0) {
+    writer.writeString(
+      7,
+      f
+    );
+  }
+  f = message.getOriginalUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      8,
+      f
+    );
+  }
+  f = message.getClusterId();
+  if (f.length > 0) {
+    writer.writeString(
+      9,
+      f
+    );
+  }
+  f = message.getEpisode();
+  if (f != null) {
+    writer.writeMessage(
+      10,
+      f,
+      proto.readss.Episode.serializeBinaryToWriter
+    );
+  }
+  f = message.getArchivedUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      11,
+      f
+    );
+  }
+  f = message.getSourceIconUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      12,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string title = 1;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getTitle = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setTitle = function(value) {
+  jspb.Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional string url = 2;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+/**
+ * optional string source = 3;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getSource = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 3, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setSource = function(value) {
+  jspb.Message.setProto3StringField(this, 3, value);
+};
+
+
+/**
+ * optional string time = 4;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getTime = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 4, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setTime = function(value) {
+  jspb.Message.setProto3StringField(this, 4, value);
+};
+
+
+/**
+ * optional string reltime = 5;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getReltime = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 5, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setReltime = function(value) {
+  jspb.Message.setProto3StringField(this, 5, value);
+};
+
+
+/**
+ * repeated string tags = 6;
+ * @return {!Array<string>}
+ */
+proto.readss.Article.prototype.getTagsList = function() {
+  return /** @type {!Array<string>} */ (jspb.Message.getRepeatedField(this, 6));
+};
+
+
+/** @param {!Array<string>} value */
+proto.readss.Article.prototype.setTagsList = function(value) {
+  jspb.Message.setField(this, 6, value || []);
+};
+
+
+/**
+ * @param {string} value
+ * @param {number=} opt_index
+ */
+proto.readss.Article.prototype.addTags = function(value, opt_index) {
+  jspb.Message.addToRepeatedField(this, 6, value, opt_index);
+};
+
+
+proto.readss.Article.prototype.clearTagsList = function() {
+  this.setTagsList([]);
+};
+
+
+/**
+ * optional string id = 7;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getId = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 7, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setId = function(value) {
+  jspb.Message.setProto3StringField(this, 7, value);
+};
+
+
+/**
+ * optional string original_url = 8;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getOriginalUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 8, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setOriginalUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 8, value);
+};
+
+
+/**
+ * optional string cluster_id = 9;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getClusterId = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 9, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setClusterId = function(value) {
+  jspb.Message.setProto3StringField(this, 9, value);
+};
+
+
+/**
+ * optional Episode episode = 10;
+ * @return {?proto.readss.Episode}
+ */
+proto.readss.Article.prototype.getEpisode = function() {
+  return /** @type{?proto.readss.Episode} */ (
+    jspb.Message.getWrapperField(this, proto.readss.Episode, 10));
+};
+
+
+/** @param {?proto.readss.Episode|undefined} value */
+proto.readss.Article.prototype.setEpisode = function(value) {
+  jspb.Message.setWrapperField(this, 10, value);
+};
+
+
+/**
+ * Clears the message field making it undefined.
+ */
+proto.readss.Article.prototype.clearEpisode = function() {
+  this.setEpisode(undefined);
+};
+
+
+/**
+ * Returns whether this field is set.
+ * @return {boolean}
+ */
+proto.readss.Article.prototype.hasEpisode = function() {
+  return jspb.Message.getField(this, 10) != null;
+};
+
+
+/**
+ * optional string archived_url = 11;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getArchivedUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 11, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setArchivedUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 11, value);
+};
+
+
+/**
+ * optional string source_icon_url = 12;
+ * @return {string}
+ */
+proto.readss.Article.prototype.getSourceIconUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 12, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Article.prototype.setSourceIconUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 12, value);
+};
+
+
+
+/**
+ * Generated by JsPbCodeGenerator.
+ * @param {Array=} opt_data Optional initial data array, typically from a
+ * server response, or constructed directly in Javascript. The array is used
+ * in place and becomes part of the constructed object. It is not cloned.
+ * If no data is provided, the constructed object will be empty, but still
+ * valid.
+ * @extends {jspb.Message}
+ * @constructor
+ */
+proto.readss.Episode = function(opt_data) {
+  jspb.Message.initialize(this, opt_data, 0, -1, null, null);
+};
+goog.inherits(proto.readss.Episode, jspb.Message);
+if (goog.DEBUG && !COMPILED) {
+  proto.readss.Episode.displayName = 'proto.readss.Episode';
+}
+
+
+if (jspb.Message.GENERATE_TO_OBJECT) {
+/**
+ * Creates an object representation of this proto suitable for use in Soy templates.
+ * Field names that are reserved in JavaScript and will be renamed to pb_name.
+ * To access a reserved field use, foo.pb_<name>, eg, foo.pb_default.
+ * For the list of reserved names please see:
+ *     com.google.apps.jspb.JsClassTemplate.JS_RESERVED_WORDS.
+ * @param {boolean=} opt_includeInstance Whether to include the JSPB instance
+ *     for transitional soy proto support: http://goto/soy-param-migration
+ * @return {!Object}
+ */
+proto.readss.Episode.prototype.toObject = function(opt_includeInstance) {
+  return proto.readss.Episode.toObject(opt_includeInstance, this);
+};
+
+
+/**
+ * Static version of the {@see toObject} method.
+ * @param {boolean|undefined} includeInstance Whether to include the JSPB
+ *     instance for transitional soy proto support:
+ *     http://goto/soy-param-migration
+ * @param {!proto.readss.Episode} msg The msg instance to transform.
+ * @return {!Object}
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.Episode.toObject = function(includeInstance, msg) {
+  var f, obj = {
+    audioUrl: jspb.Message.getFieldWithDefault(msg, 1, ""),
+    originalAudioUrl: jspb.Message.getFieldWithDefault(msg, 2, ""),
+    audioType: jspb.Message.getFieldWithDefault(msg, 3, ""),
+    audioLength: jspb.Message.getFieldWithDefault(msg, 4, 0),
+    duration: jspb.Message.getFieldWithDefault(msg, 5, 0),
+    episode: jspb.Message.getFieldWithDefault(msg, 6, 0),
+    season: jspb.Message.getFieldWithDefault(msg, 7, 0),
+    artworkUrl: jspb.Message.getFieldWithDefault(msg, 8, ""),
+    archived: jspb.Message.getFieldWithDefault(msg, 9, false)
+  };
+
+  if (includeInstance) {
+    obj.$jspbMessageInstance = msg;
+  }
+  return obj;
+};
+}
+
+
+/**
+ * Deserializes binary data (in protobuf wire format).
+ * @param {jspb.ByteSource} bytes The bytes to deserialize.
+ * @return {!proto.readss.Episode}
+ */
+proto.readss.Episode.deserializeBinary = function(bytes) {
+  var reader = new jspb.BinaryReader(bytes);
+  var msg = new proto.readss.Episode;
+  return proto.readss.Episode.deserializeBinaryFromReader(msg, reader);
+};
+
+
+/**
+ * Deserializes binary data (in protobuf wire format) from the
+ * given reader into the given message object.
+ * @param {!proto.readss.Episode} msg The message object to deserialize into.
+ * @param {!jspb.BinaryReader} reader The BinaryReader to use.
+ * @return {!proto.readss.Episode}
+ */
+proto.readss.Episode.deserializeBinaryFromReader = function(msg, reader) {
+  while (reader.nextField()) {
+    if (reader.isEndGroup()) {
+      break;
+    }
+    var field = reader.getFieldNumber();
+    switch (field) {
+    case 1:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setAudioUrl(value);
+      break;
+    case 2:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setOriginalAudioUrl(value);
+      break;
+    case 3:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setAudioType(value);
+      break;
+    case 4:
+      var value = /** @type {number} */ (reader.readInt64());
+      msg.setAudioLength(value);
+      break;
+    case 5:
+      var value = /** @type {number} */ (reader.readInt64());
+      msg.setDuration(value);
+      break;
+    case 6:
+      var value = /** @type {number} */ (reader.readInt32());
+      msg.setEpisode(value);
+      break;
+    case 7:
+      var value = /** @type {number} */ (reader.readInt32());
+      msg.setSeason(value);
+      break;
+    case 8:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setArtworkUrl(value);
+      break;
+    case 9:
+      var value = /** @type {boolean} */ (reader.readBool());
+      msg.setArchived(value);
+      break;
+    default:
+      reader.skipField();
+      break;
+    }
+  }
+  return msg;
+};
+
+
+/**
+ * Serializes the message to binary data (in protobuf wire format).
+ * @return {!Uint8Array}
+ */
+proto.readss.Episode.prototype.serializeBinary = function() {
+  var writer = new jspb.BinaryWriter();
+  proto.readss.Episode.serializeBinaryToWriter(this, writer);
+  return writer.getResultBuffer();
+};
+
+
+/**
+ * Serializes the given message to binary data (in protobuf wire
+ * format), writing to the given BinaryWriter.
+ * @param {!proto.readss.Episode} message
+ * @param {!jspb.BinaryWriter} writer
+ * @suppress {unusedLocalVariables} f is only used for nested messages
+ */
+proto.readss.Episode.serializeBinaryToWriter = function(message, writer) {
+  var f = undefined;
+  f = message.getAudioUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      1,
+      f
+    );
+  }
+  f = message.getOriginalAudioUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      2,
+      f
+    );
+  }
+  f = message.getAudioType();
+  if (f.length > 0) {
+    writer.writeString(
+      3,
+      f
+    );
+  }
+  f = message.getAudioLength();
+  if (f !== 0) {
+    writer.writeInt64(
+      4,
+      f
+    );
+  }
+  f = message.getDuration();
+  if (f !== 0) {
+    writer.writeInt64(
+      5,
+      f
+    );
+  }
+  f = message.getEpisode();
+  if (f !== 0) {
+    writer.writeInt32(
+      6,
+      f
+    );
+  }
+  f = message.getSeason();
+  if (f !== 0) {
+    writer.writeInt32(
+      7,
+      f
+    );
+  }
+  f = message.getArtworkUrl();
+  if (f.length > 0) {
+    writer.writeString(
+      8,
+      f
+    );
+  }
+  f = message.getArchived();
+  if (f) {
+    writer.writeBool(
+      9,
+      f
+    );
+  }
+};
+
+
+/**
+ * optional string audio_url = 1;
+ * @return {string}
+ */
+proto.readss.Episode.prototype.getAudioUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 1, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Episode.prototype.setAudioUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 1, value);
+};
+
+
+/**
+ * optional string original_audio_url = 2;
+ * @return {string}
+ */
+proto.readss.Episode.prototype.getOriginalAudioUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 2, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Episode.prototype.setOriginalAudioUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 2, value);
+};
+
+
+/**
+ * optional string audio_type = 3;
+ * @return {string}
+ */
+proto.readss.Episode.prototype.getAudioType = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 3, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Episode.prototype.setAudioType = function(value) {
+  jspb.Message.setProto3StringField(this, 3, value);
+};
+
+
+/**
+ * optional int64 audio_length = 4;
+ * @return {number}
+ */
+proto.readss.Episode.prototype.getAudioLength = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 4, 0));
+};
+
+
+/** @param {number} value */
+proto.readss.Episode.prototype.setAudioLength = function(value) {
+  jspb.Message.setProto3IntField(this, 4, value);
+};
+
+
+/**
+ * optional int64 duration = 5;
+ * @return {number}
+ */
+proto.readss.Episode.prototype.getDuration = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 5, 0));
+};
+
+
+/** @param {number} value */
+proto.readss.Episode.prototype.setDuration = function(value) {
+  jspb.Message.setProto3IntField(this, 5, value);
+};
+
+
+/**
+ * optional int32 episode = 6;
+ * @return {number}
+ */
+proto.readss.Episode.prototype.getEpisode = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 6, 0));
+};
+
+
+/** @param {number} value */
+proto.readss.Episode.prototype.setEpisode = function(value) {
+  jspb.Message.setProto3IntField(this, 6, value);
+};
+
+
+/**
+ * optional int32 season = 7;
+ * @return {number}
+ */
+proto.readss.Episode.prototype.getSeason = function() {
+  return /** @type {number} */ (jspb.Message.getFieldWithDefault(this, 7, 0));
+};
+
+
+/** @param {number} value */
+proto.readss.Episode.prototype.setSeason = function(value) {
+  jspb.Message.setProto3IntField(this, 7, value);
+};
+
+
+/**
+ * optional string artwork_url = 8;
+ * @return {string}
+ */
+proto.readss.Episode.prototype.getArtworkUrl = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 8, ""));
+};
+
+
+/** @param {string} value */
+proto.readss.Episode.prototype.setArtworkUrl = function(value) {
+  jspb.Message.setProto3StringField(this, 8, value);
+};
+
+
+/**
+ * optional bool archived = 9;
+ * @return {boolean}
+ */
+proto.readss.Episode.prototype.getArchived = function() {
+  return /** @type {boolean} */ (jspb.Message.getFieldWithDefault(this, 9, false));
+};
+
+
+/** @param {boolean} value */
+proto.readss.Episode.prototype.setArchived = function(value) {
+  jspb.Message.setProto3BooleanField(this, 9, value);
 };
 
 
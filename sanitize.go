@@ -0,0 +1,252 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Policy is an allowlist of elements and attributes.
+// Elements not in Elements or Drop are replaced by their children.
+type Policy struct {
+	// Elements maps allowed elements to their allowed attributes
+	Elements map[string][]string
+	// URLAttrs are attributes holding urls,
+	// they are resolved against the base url and must use one of Schemes
+	URLAttrs map[string]bool
+	Schemes  map[string]bool
+	// Drop are elements removed along with their contents
+	Drop map[string]bool
+	// Trackers matches image urls that are tracking pixels
+	Trackers *regexp.Regexp
+}
+
+var DefaultPolicy = &Policy{
+	Elements: map[string][]string{
+		"a":          {"href", "title"},
+		"abbr":       {"title"},
+		"audio":      {"src", "controls"},
+		"b":          nil,
+		"blockquote": {"cite"},
+		"br":         nil,
+		"caption":    nil,
+		"cite":       nil,
+		"code":       nil,
+		"dd":         nil,
+		"del":        nil,
+		"details":    nil,
+		"dfn":        nil,
+		"div":        nil,
+		"dl":         nil,
+		"dt":         nil,
+		"em":         nil,
+		"figcaption": nil,
+		"figure":     nil,
+		"h1":         nil,
+		"h2":         nil,
+		"h3":         nil,
+		"h4":         nil,
+		"h5":         nil,
+		"h6":         nil,
+		"hr":         nil,
+		"i":          nil,
+		"img":        {"src", "alt", "title", "width", "height"},
+		"ins":        nil,
+		"kbd":        nil,
+		"li":         nil,
+		"mark":       nil,
+		"ol":         {"start"},
+		"p":          nil,
+		"pre":        nil,
+		"q":          {"cite"},
+		"s":          nil,
+		"samp":       nil,
+		"small":      nil,
+		"source":     {"src", "type"},
+		"span":       nil,
+		"strong":     nil,
+		"sub":        nil,
+		"summary":    nil,
+		"sup":        nil,
+		"table":      nil,
+		"tbody":      nil,
+		"td":         {"colspan", "rowspan"},
+		"tfoot":      nil,
+		"th":         {"colspan", "rowspan", "scope"},
+		"thead":      nil,
+		"time":       {"datetime"},
+		"tr":         nil,
+		"u":          nil,
+		"ul":         nil,
+		"var":        nil,
+		"video":      {"src", "poster", "controls"},
+	},
+	URLAttrs: map[string]bool{
+		"href":   true,
+		"src":    true,
+		"cite":   true,
+		"poster": true,
+	},
+	Schemes: map[string]bool{
+		"http":   true,
+		"https":  true,
+		"mailto": true,
+	},
+	Drop: map[string]bool{
+		"base":     true,
+		"button":   true,
+		"embed":    true,
+		"form":     true,
+		"frame":    true,
+		"frameset": true,
+		"head":     true,
+		"iframe":   true,
+		"input":    true,
+		"link":     true,
+		"math":     true,
+		"meta":     true,
+		"noscript": true,
+		"object":   true,
+		"script":   true,
+		"select":   true,
+		"style":    true,
+		"svg":      true,
+		"template": true,
+		"textarea": true,
+		"title":    true,
+	},
+	Trackers: regexp.MustCompile(`(?i)^https?://(feeds\.feedburner\.com/~r/|feeds\.feedburner\.com/~ff/|pixel\.wp\.com/|stats\.wordpress\.com/|[^/]*doubleclick\.net/|[^/]*google-analytics\.com/|[^/]*\.list-manage\.com/track/|pixel\.|[^/]*/(pixel|tracking|beacon)\.(gif|png)\b)`),
+}
+
+// sanitize cleans s with DefaultPolicy, resolving relative urls against base.
+func sanitize(s, base string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		u = nil
+	}
+	return DefaultPolicy.Sanitize(s, u)
+}
+
+// Sanitize parses s as an html fragment and renders only what p allows.
+func (p *Policy) Sanitize(s string, base *url.URL) string {
+	ctx := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	ns, err := html.ParseFragment(strings.NewReader(s), ctx)
+	if err != nil {
+		return html.EscapeString(s)
+	}
+	var b strings.Builder
+	for _, n := range ns {
+		p.render(&b, n, base)
+	}
+	return b.String()
+}
+
+func (p *Policy) render(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if p.Drop[n.Data] {
+		return
+	}
+	allowed, ok := p.Elements[n.Data]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			p.render(b, c, base)
+		}
+		return
+	}
+	if n.Data == "img" && p.isTracker(n, base) {
+		return
+	}
+
+	b.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !contains(allowed, a.Key) {
+			continue
+		}
+		val := a.Val
+		if p.URLAttrs[a.Key] {
+			val = p.resolve(val, base)
+			if val == "" {
+				continue
+			}
+		}
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+	}
+	if n.Data == "a" {
+		b.WriteString(` rel="noopener noreferrer nofollow"`)
+	}
+	b.WriteString(">")
+	if voidElements[n.Data] {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.render(b, c, base)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
+
+// resolve returns the absolute form of a url,
+// or "" if it does not use an allowed scheme.
+func (p *Policy) resolve(s string, base *url.URL) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !p.Schemes[u.Scheme] {
+		return ""
+	}
+	return u.String()
+}
+
+// isTracker reports whether an img is a tracking pixel:
+// a known tracker, sized 1x1 or smaller, or hidden.
+func (p *Policy) isTracker(n *html.Node, base *url.URL) bool {
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "src":
+			if p.Trackers != nil && p.Trackers.MatchString(p.resolve(a.Val, base)) {
+				return true
+			}
+		case "width", "height":
+			if v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(a.Val), "px")); err == nil && v <= 1 {
+				return true
+			}
+		case "style":
+			st := strings.ToLower(strings.Replace(a.Val, " ", "", -1))
+			if strings.Contains(st, "display:none") || strings.Contains(st, "visibility:hidden") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var voidElements = map[string]bool{
+	"br":     true,
+	"hr":     true,
+	"img":    true,
+	"source": true,
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
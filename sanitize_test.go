@@ -0,0 +1,169 @@
+package main
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	const base = "https://example.com/posts/1"
+	tcs := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "allowed",
+			in:   `<p>some <em>text</em> and <a href="https://other.example/" title="t">a link</a></p>`,
+			want: `<p>some <em>text</em> and <a href="https://other.example/" title="t" rel="noopener noreferrer nofollow">a link</a></p>`,
+		},
+		{
+			name: "relative urls",
+			in:   `<a href="../2">prev</a><img src="/a.png" alt="a">`,
+			want: `<a href="https://example.com/2" rel="noopener noreferrer nofollow">prev</a><img src="https://example.com/a.png" alt="a">`,
+		},
+		{
+			name: "mailto",
+			in:   `<a href="mailto:me@example.com">mail</a>`,
+			want: `<a href="mailto:me@example.com" rel="noopener noreferrer nofollow">mail</a>`,
+		},
+		{
+			name: "escaped text",
+			in:   `&lt;script&gt;alert(1)&lt;/script&gt;`,
+			want: `&lt;script&gt;alert(1)&lt;/script&gt;`,
+		},
+		{
+			name: "unknown elements unwrapped",
+			in:   `<custom-el data-x="1"><b>bold</b></custom-el>`,
+			want: `<b>bold</b>`,
+		},
+
+		// scripts
+		{
+			name: "script",
+			in:   `<p>hi<script>alert(1)</script></p>`,
+			want: `<p>hi</p>`,
+		},
+		{
+			name: "noscript",
+			in:   `<noscript><img src="x" onerror="alert(1)"></noscript>ok`,
+			want: `ok`,
+		},
+		{
+			name: "javascript href",
+			in:   `<a href="javascript:alert(1)">x</a>`,
+			want: `<a rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "javascript href mixed case and space",
+			in:   `<a href="  JaVaScRiPt:alert(1)">x</a>`,
+			want: `<a rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "javascript href encoded tab",
+			in:   `<a href="java&#x09;script:alert(1)">x</a>`,
+			want: `<a rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "javascript href entities",
+			in:   `<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a>`,
+			want: `<a rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "vbscript src",
+			in:   `<img src="vbscript:msgbox(1)">`,
+			want: `<img>`,
+		},
+		{
+			name: "data src",
+			in:   `<img src="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">`,
+			want: `<img>`,
+		},
+		{
+			name: "javascript cite",
+			in:   `<blockquote cite="javascript:alert(1)">q</blockquote>`,
+			want: `<blockquote>q</blockquote>`,
+		},
+		{
+			name: "javascript poster",
+			in:   `<video poster="javascript:alert(1)" src="/v.mp4"></video>`,
+			want: `<video src="https://example.com/v.mp4"></video>`,
+		},
+		{
+			name: "event attributes",
+			in:   `<p onclick="alert(1)" onmouseover="alert(2)">x</p><img src="/a.png" onerror="alert(3)" onload="alert(4)">`,
+			want: `<p>x</p><img src="https://example.com/a.png">`,
+		},
+		{
+			name: "style attribute",
+			in:   `<p style="background:url(javascript:alert(1))">x</p>`,
+			want: `<p>x</p>`,
+		},
+		{
+			name: "attribute breakout",
+			in:   `<a title='"><script>alert(1)</script>' href="/x">x</a>`,
+			want: `<a title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;" href="https://example.com/x" rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "svg",
+			in:   `<svg onload="alert(1)"><script>alert(2)</script><a href="javascript:alert(3)"><text>x</text></a></svg>ok`,
+			want: `ok`,
+		},
+		{
+			name: "svg in paragraph",
+			in:   `<p>a<svg><animate onbegin="alert(1)" attributeName="x"/></svg>b</p>`,
+			want: `<p>ab</p>`,
+		},
+		{
+			name: "math",
+			in:   `<math><mtext><a href="javascript:alert(1)">x</a></mtext></math>ok`,
+			want: `ok`,
+		},
+		{
+			name: "iframe and object",
+			in:   `<iframe src="https://evil.example/"></iframe><object data="x.swf"></object><embed src="x.swf">ok`,
+			want: `ok`,
+		},
+		{
+			name: "forms",
+			in:   `<form action="https://evil.example/"><input name="p"><button>go</button></form>ok`,
+			want: `ok`,
+		},
+		{
+			name: "style and base",
+			in:   `<style>body{display:none}</style><base href="https://evil.example/"><a href="/x">x</a>`,
+			want: `<a href="https://example.com/x" rel="noopener noreferrer nofollow">x</a>`,
+		},
+		{
+			name: "namespaced attribute",
+			in:   `<a xlink:href="javascript:alert(1)" href="/x">x</a>`,
+			want: `<a href="https://example.com/x" rel="noopener noreferrer nofollow">x</a>`,
+		},
+
+		// trackers
+		{
+			name: "known tracker",
+			in:   `<p>x<img src="https://feeds.feedburner.com/~r/blog/~4/abc"></p>`,
+			want: `<p>x</p>`,
+		},
+		{
+			name: "pixel sized",
+			in:   `<img src="https://example.com/p.gif" width="1" height="1px">`,
+			want: ``,
+		},
+		{
+			name: "hidden",
+			in:   `<img src="https://example.com/p.gif" style="display: none">`,
+			want: ``,
+		},
+		{
+			name: "not a tracker",
+			in:   `<img src="https://example.com/photo.jpg" width="640">`,
+			want: `<img src="https://example.com/photo.jpg" width="640">`,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sanitize(tc.in, base); got != tc.want {
+				t.Errorf("sanitize(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	xhtml "golang.org/x/net/html"
+
+	"seankhliao.com/readss/readss"
+)
+
+// Query is a parsed search query.
+// All terms, phrases and filters must match.
+//
+// Syntax:
+//
+//	word            entry contains word
+//	"some words"    entry contains the phrase
+//	source:name     entry is from the subscription called name
+//	tag:name        entry is tagged name by its subscription or a filter rule
+//	before:date     entry is older than date (2006-01-02)
+//	after:date      entry is newer than date (2006-01-02)
+//
+// Values may be quoted, as in source:"Some Blog".
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+	Sources []string
+	Tags    []string
+	Before  time.Time
+	After   time.Time
+}
+
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return q, nil
+		}
+
+		var key string
+		if i := strings.IndexAny(s, ": \t\n\""); i > 0 && s[i] == ':' {
+			key, s = strings.ToLower(s[:i]), s[i+1:]
+		}
+
+		var val string
+		quoted := strings.HasPrefix(s, "\"")
+		if quoted {
+			i := strings.Index(s[1:], "\"")
+			if i < 0 {
+				return q, fmt.Errorf("unterminated quote in %q", s)
+			}
+			val, s = s[1:i+1], s[i+2:]
+		} else {
+			i := strings.IndexFunc(s, unicode.IsSpace)
+			if i < 0 {
+				i = len(s)
+			}
+			val, s = s[:i], s[i:]
+		}
+
+		switch key {
+		case "source":
+			q.Sources = append(q.Sources, val)
+		case "tag":
+			q.Tags = append(q.Tags, val)
+		case "before", "after":
+			t, err := time.ParseInLocation("2006-01-02", val, time.Local)
+			if err != nil {
+				return q, fmt.Errorf("parse %v date: %v", key, err)
+			}
+			if key == "before" {
+				q.Before = t
+			} else {
+				q.After = t.Add(24 * time.Hour)
+			}
+		default:
+			if key != "" {
+				val = key + ":" + val
+			}
+			ws := tokenize(val)
+			switch {
+			case len(ws) == 0:
+			case len(ws) == 1:
+				q.Terms = append(q.Terms, ws[0].word)
+			default:
+				p := make([]string, len(ws))
+				for i, w := range ws {
+					p[i] = w.word
+				}
+				q.Phrases = append(q.Phrases, p)
+			}
+		}
+	}
+}
+
+// Filter checks the non text parts of the query
+// for an entry presented as at.
+func (q Query) Filter(e *Entry, at *readss.Article) bool {
+	if !q.Before.IsZero() && !e.Time.Before(q.Before) {
+		return false
+	}
+	if !q.After.IsZero() && e.Time.Before(q.After) {
+		return false
+	}
+	for _, s := range q.Sources {
+		if !strings.EqualFold(s, at.Source) {
+			return false
+		}
+	}
+tags:
+	for _, t := range q.Tags {
+		for _, st := range at.Tags {
+			if strings.EqualFold(t, st) {
+				continue tags
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// words is the set of words to highlight.
+func (q Query) words() map[string]bool {
+	ws := make(map[string]bool)
+	for _, t := range q.Terms {
+		ws[t] = true
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p {
+			ws[t] = true
+		}
+	}
+	return ws
+}
+
+type posting struct {
+	doc int
+	pos []int
+}
+
+// Index is an inverted index over the title, summary, content and author of entries.
+type Index struct {
+	docs  []*Entry
+	terms map[string][]posting
+}
+
+func NewIndex(es []*Entry) *Index {
+	idx := &Index{
+		docs:  es,
+		terms: make(map[string][]posting),
+	}
+	for d, e := range es {
+		var pos int
+		for _, field := range []string{e.Title, htmlText(e.Summary), htmlText(e.Content), e.Author} {
+			ws := tokenize(field)
+			for i, w := range ws {
+				ps := idx.terms[w.word]
+				if len(ps) == 0 || ps[len(ps)-1].doc != d {
+					ps = append(ps, posting{doc: d})
+				}
+				ps[len(ps)-1].pos = append(ps[len(ps)-1].pos, pos+i)
+				idx.terms[w.word] = ps
+			}
+			// leave a gap so phrases don't match across fields
+			pos += len(ws) + 1
+		}
+	}
+	return idx
+}
+
+// Match returns the entries containing all terms and phrases in q.
+func (idx *Index) Match(q Query) []*Entry {
+	if len(q.Terms) == 0 && len(q.Phrases) == 0 {
+		return idx.docs
+	}
+
+	var lists [][]posting
+	for _, t := range q.Terms {
+		lists = append(lists, idx.terms[t])
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p {
+			lists = append(lists, idx.terms[t])
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	docs := make(map[int]bool, len(lists[0]))
+	for _, p := range lists[0] {
+		docs[p.doc] = true
+	}
+	for _, l := range lists[1:] {
+		next := make(map[int]bool, len(docs))
+		for _, p := range l {
+			if docs[p.doc] {
+				next[p.doc] = true
+			}
+		}
+		docs = next
+	}
+
+	var es []*Entry
+	for d := range docs {
+		if idx.hasPhrases(d, q.Phrases) {
+			es = append(es, idx.docs[d])
+		}
+	}
+	return es
+}
+
+func (idx *Index) hasPhrases(d int, phrases [][]string) bool {
+phrases:
+	for _, p := range phrases {
+		ps := make([]map[int]bool, len(p))
+		for i, t := range p {
+			ps[i] = idx.positions(t, d)
+		}
+	start:
+		for pos := range ps[0] {
+			for i := 1; i < len(p); i++ {
+				if !ps[i][pos+i] {
+					continue start
+				}
+			}
+			continue phrases
+		}
+		return false
+	}
+	return true
+}
+
+func (idx *Index) positions(t string, d int) map[int]bool {
+	ps := idx.terms[t]
+	i := sort.Search(len(ps), func(i int) bool { return ps[i].doc >= d })
+	m := make(map[int]bool)
+	if i < len(ps) && ps[i].doc == d {
+		for _, p := range ps[i].pos {
+			m[p] = true
+		}
+	}
+	return m
+}
+
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits s into lowercased runs of letters and digits.
+func tokenize(s string) []token {
+	var ts []token
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			ts = append(ts, token{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ts = append(ts, token{strings.ToLower(s[start:]), start, len(s)})
+	}
+	return ts
+}
+
+// htmlText extracts the text content of an html fragment.
+func htmlText(s string) string {
+	var b strings.Builder
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case xhtml.TextToken:
+			b.Write(z.Text())
+		case xhtml.StartTagToken, xhtml.EndTagToken, xhtml.SelfClosingTagToken:
+			b.WriteByte(' ')
+		}
+	}
+}
+
+// highlight html escapes s and wraps words in <mark>.
+// If window > 0, s is cut down to about window tokens around the first match.
+func highlight(s string, words map[string]bool, window int) string {
+	ts := tokenize(s)
+	first, last := 0, len(ts)
+	if window > 0 && len(ts) > window {
+		for i, t := range ts {
+			if words[t.word] {
+				first = i - window/4
+				break
+			}
+		}
+		if first < 0 {
+			first = 0
+		}
+		last = first + window
+		if last > len(ts) {
+			last = len(ts)
+		}
+	}
+
+	var b strings.Builder
+	from, to := 0, len(s)
+	if first > 0 {
+		b.WriteString("… ")
+		from = ts[first].start
+	}
+	if last < len(ts) {
+		to = ts[last-1].end
+	}
+	prev := from
+	for _, t := range ts[first:last] {
+		if !words[t.word] {
+			continue
+		}
+		b.WriteString(html.EscapeString(s[prev:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(s[t.start:t.end]))
+		b.WriteString("</mark>")
+		prev = t.end
+	}
+	b.WriteString(html.EscapeString(s[prev:to]))
+	if last < len(ts) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+func TestParseQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02", s, time.Local)
+		return t
+	}
+	tcs := []struct {
+		in   string
+		want Query
+		err  string
+	}{
+		{in: ""},
+		{in: "  \t "},
+		{
+			in:   "Go Generics",
+			want: Query{Terms: []string{"go", "generics"}},
+		},
+		{
+			in:   `"type parameters" go`,
+			want: Query{Terms: []string{"go"}, Phrases: [][]string{{"type", "parameters"}}},
+		},
+		{
+			in:   `"single"`,
+			want: Query{Terms: []string{"single"}},
+		},
+		{
+			in:   "go-1.18",
+			want: Query{Phrases: [][]string{{"go", "1", "18"}}},
+		},
+		{
+			in:   `source:"Some Blog" tag:news SOURCE:other`,
+			want: Query{Sources: []string{"Some Blog", "other"}, Tags: []string{"news"}},
+		},
+		{
+			in:   "after:2020-01-02 before:2020-02-01",
+			want: Query{After: day("2020-01-03"), Before: day("2020-02-01")},
+		},
+		{
+			in:   "https://example.com",
+			want: Query{Phrases: [][]string{{"https", "example", "com"}}},
+		},
+		{
+			in:   `"" ...`,
+			want: Query{},
+		},
+		{
+			in:  `go "unterminated phrase`,
+			err: `unterminated quote in "\"unterminated phrase"`,
+		},
+		{
+			in:  `source:"Some Blog`,
+			err: `unterminated quote in "\"Some Blog"`,
+		},
+		{
+			in:  "before:yesterday",
+			err: `parse before date: `,
+		},
+		{
+			in:  "after:2020-13-01",
+			err: `parse after date: `,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseQuery(tc.in)
+			if tc.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tc.err) {
+					t.Fatalf("ParseQuery error = %v, want %v...", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseQuery = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIndexMatch(t *testing.T) {
+	es := []*Entry{
+		{ID: "0", Title: "Type parameters in Go", Summary: "<p>Generics are <b>here</b>.</p>"},
+		{ID: "1", Title: "Parameters of a type", Content: "a type system, parameters later"},
+		{ID: "2", Title: "Release notes", Summary: "the last word is type", Content: "parameters start the content"},
+		{ID: "3", Title: "Go go go", Author: "Type Parameters"},
+	}
+	idx := NewIndex(es)
+	tcs := []struct {
+		q    string
+		want []string
+	}{
+		{"", []string{"0", "1", "2", "3"}},
+		{"go", []string{"0", "3"}},
+		{"type parameters", []string{"0", "1", "2", "3"}},
+		{`"type parameters"`, []string{"0", "3"}},
+		{`"parameters type"`, nil},
+		{`"parameters of a type"`, []string{"1"}},
+		{`"generics are here"`, []string{"0"}},
+		{`"go go"`, []string{"3"}},
+		{`"go type"`, nil},
+		{`"type parameters" go`, []string{"0", "3"}},
+		{"missing", nil},
+		{"go missing", nil},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.q, func(t *testing.T) {
+			q, err := ParseQuery(tc.q)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			var got []string
+			for _, e := range idx.Match(q) {
+				got = append(got, e.ID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Match = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	e := &Entry{Time: time.Date(2020, 1, 15, 12, 0, 0, 0, time.Local)}
+	at := &readss.Article{Source: "Some Blog", Tags: []string{"news", "go"}}
+	tcs := []struct {
+		q    string
+		want bool
+	}{
+		{"", true},
+		{`source:"some blog"`, true},
+		{"source:other", false},
+		{"tag:GO", true},
+		{"tag:go tag:news", true},
+		{"tag:go tag:rust", false},
+		{"after:2020-01-14", true},
+		{"after:2020-01-15", false},
+		{"before:2020-01-15", false},
+		{"before:2020-01-16", true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.q, func(t *testing.T) {
+			q, err := ParseQuery(tc.q)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			if got := q.Filter(e, at); got != tc.want {
+				t.Errorf("Filter = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	words := map[string]bool{"go": true, "fast": true}
+	long := strings.Repeat("filler ", 20) + "go is fast " + strings.Repeat("more ", 20)
+	tcs := []struct {
+		name   string
+		s      string
+		window int
+		want   string
+	}{
+		{"none", "nothing here", 0, "nothing here"},
+		{"words", "Go is <fast>", 0, "<mark>Go</mark> is &lt;<mark>fast</mark>&gt;"},
+		{"whole words", "going gopher go", 0, "going gopher <mark>go</mark>"},
+		{"short window", "go is fast", 30, "<mark>go</mark> is <mark>fast</mark>"},
+		{"window", long, 8, "… filler filler <mark>go</mark> is <mark>fast</mark> more more more …"},
+		{"no match window", strings.Repeat("filler ", 10), 4, "filler filler filler filler …"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := highlight(tc.s, words, tc.window); got != tc.want {
+				t.Errorf("highlight = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
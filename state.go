@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// accountSubs returns the subscriptions and rules served to a.
+func (s *Server) accountSubs(a Account) ([]Sub, Rules, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs, ok := s.users[a.User]
+	if !ok {
+		logger.Warn("account for unknown user", "login", a.Login, "user", a.User)
+	}
+	return subs, s.rules, ok
+}
+
+// readState remembers which articles each user has read or saved in client apps,
+// by article id.
+type readState struct {
+	fn    string
+	mu    sync.Mutex
+	users map[string]*userState
+}
+
+type userState struct {
+	Read  map[string]time.Time `json:"read,omitempty"`
+	Saved map[string]time.Time `json:"saved,omitempty"`
+}
+
+func loadReadState(fn string) *readState {
+	rs := &readState{
+		fn:    fn,
+		users: make(map[string]*userState),
+	}
+	if fn == "" {
+		return rs
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("open read state", "file", fn, "error", err)
+		}
+		return rs
+	}
+	if err := json.Unmarshal(b, &rs.users); err != nil {
+		logger.Error("decode read state", "file", fn, "error", err)
+	}
+	return rs
+}
+
+// user returns the state of user, rs.mu must be held.
+func (rs *readState) user(user string) *userState {
+	us, ok := rs.users[user]
+	if !ok {
+		us = &userState{}
+		rs.users[user] = us
+	}
+	if us.Read == nil {
+		us.Read = make(map[string]time.Time)
+	}
+	if us.Saved == nil {
+		us.Saved = make(map[string]time.Time)
+	}
+	return us
+}
+
+// get returns the ids user has read and saved.
+func (rs *readState) get(user string) (read, saved map[string]bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	us := rs.user(user)
+	read = make(map[string]bool, len(us.Read))
+	for k := range us.Read {
+		read[k] = true
+	}
+	saved = make(map[string]bool, len(us.Saved))
+	for k := range us.Saved {
+		saved[k] = true
+	}
+	return read, saved
+}
+
+// mark sets ids as read, or saved if saved is true, or clears them if on is false.
+func (rs *readState) mark(user string, saved, on bool, ids ...string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	us := rs.user(user)
+	m := us.Read
+	if saved {
+		m = us.Saved
+	}
+	now := time.Now()
+	for _, id := range ids {
+		if on {
+			m[id] = now
+		} else {
+			delete(m, id)
+		}
+	}
+}
+
+// save forgets articles no longer in store and writes the state to its file, if any.
+func (rs *readState) save(store *Store) error {
+	rs.mu.Lock()
+	for _, us := range rs.users {
+		for _, m := range []map[string]time.Time{us.Read, us.Saved} {
+			for k := range m {
+				if store.Get(k) == nil {
+					delete(m, k)
+				}
+			}
+		}
+	}
+	b, err := json.Marshal(rs.users)
+	rs.mu.Unlock()
+	if err != nil || rs.fn == "" {
+		return err
+	}
+	tmp := rs.fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, rs.fn)
+}
+
+// userItem is an entry as seen by a user through a subscription.
+type userItem struct {
+	e    *Entry
+	sub  Sub
+	tags []string
+}
+
+// userItems are the stored entries of the feeds in subs that pass rules,
+// oldest Seq first.
+func userItems(store *Store, subs []Sub, rules Rules) []userItem {
+	feeds := store.Feeds()
+	seen := make(map[string]bool, len(subs))
+	var items []userItem
+	for _, sub := range subs {
+		if seen[sub.feed()] {
+			continue
+		}
+		seen[sub.feed()] = true
+		for _, e := range feeds[sub.feed()] {
+			v := rules.Apply(e, sub)
+			if v.Drop || v.Hide {
+				continue
+			}
+			items = append(items, userItem{e, sub, append(append([]string(nil), sub.Tags...), v.Tags...)})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].e.Seq < items[j].e.Seq })
+	return items
+}
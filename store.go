@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+// Entry is a single feed item,
+// independent of the users subscribed to the feed.
+type Entry struct {
+	ID         string
+	Feed       string
+	Title      string
+	URL        string
+	OrigURL    string
+	Summary    string
+	Content    string
+	Author     string
+	Categories []string
+	Time       time.Time
+	// Extracted is the main content of the linked page
+	Extracted string
+	// Added is when a refresh first saw the entry
+	Added time.Time
+	// Seq numbers entries in the order they were added,
+	// for clients that need increasing integer ids
+	Seq int64 `json:",omitempty"`
+	// Episode is set for podcast episodes
+	Episode *Episode `json:",omitempty"`
+}
+
+// Episode is the audio enclosure of an entry and its itunes details.
+type Episode struct {
+	URL      string
+	Type     string
+	Length   int64
+	Duration time.Duration
+	Number   int
+	Season   int
+	Image    string
+}
+
+// key is a stable identifier for the entry,
+// also exposed as the article id.
+func (e *Entry) key() string {
+	h := sha256.Sum256([]byte(e.Feed + "\x00" + e.ID))
+	return hex.EncodeToString(h[:12])
+}
+
+// body is the extracted article if available,
+// otherwise the content or description from the feed,
+// with images through the proxy.
+func (e *Entry) body() string {
+	if e.Extracted != "" {
+		return images.rewrite(e.Extracted)
+	}
+	if e.Content != "" {
+		return images.rewrite(e.Content)
+	}
+	return images.rewrite(e.Summary)
+}
+
+// Article presents the entry as seen through sub.
+func (e *Entry) Article(sub Sub) *readss.Article {
+	return &readss.Article{
+		Title:         e.Title,
+		Url:           e.URL,
+		Source:        sub.Name,
+		Time:          e.Time.Format("2006-01-02 15:04"),
+		Reltime:       humanTime(e.Time),
+		Tags:          append([]string(nil), sub.Tags...),
+		Id:            e.key(),
+		OriginalUrl:   e.OrigURL,
+		Episode:       e.episode(),
+		ArchivedUrl:   archives.url(e),
+		SourceIconUrl: icons.url(e.Feed),
+	}
+}
+
+// episode presents the podcast episode of e, pointing at the downloaded copy if any.
+func (e *Entry) episode() *readss.Episode {
+	if e.Episode == nil {
+		return nil
+	}
+	ep := &readss.Episode{
+		AudioUrl:         e.Episode.URL,
+		OriginalAudioUrl: e.Episode.URL,
+		AudioType:        e.Episode.Type,
+		AudioLength:      e.Episode.Length,
+		Duration:         int64(e.Episode.Duration / time.Second),
+		Episode:          int32(e.Episode.Number),
+		Season:           int32(e.Episode.Season),
+		ArtworkUrl:       images.url(e.Episode.Image),
+	}
+	if u := podcasts.url(e); u != "" {
+		ep.AudioUrl, ep.Archived = u, true
+	}
+	return ep
+}
+
+// Store holds every entry seen in the retention window
+// and optionally persists them to fn.
+type Store struct {
+	mu      sync.RWMutex
+	fn      string
+	entries map[string]*Entry
+	idx     *Index
+	// seq is the last Seq given out
+	seq int64
+}
+
+func NewStore(fn string) *Store {
+	s := &Store{
+		fn:      fn,
+		entries: make(map[string]*Entry),
+	}
+	if fn == "" {
+		return s
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("open store", "file", fn, "error", err)
+		}
+		return s
+	}
+	defer f.Close()
+
+	var es []*Entry
+	if err := json.NewDecoder(f).Decode(&es); err != nil {
+		logger.Error("decode store", "file", fn, "error", err)
+		return s
+	}
+	for _, e := range es {
+		s.entries[e.key()] = e
+		if e.Seq > s.seq {
+			s.seq = e.Seq
+		}
+	}
+	return s
+}
+
+// Add inserts or replaces entries,
+// keeping when they were first added and their Seq.
+// It returns the entries that were not already stored.
+func (s *Store) Add(es []*Entry) []*Entry {
+	var added []*Entry
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range es {
+		k := e.key()
+		if old, ok := s.entries[k]; ok {
+			e.Added, e.Seq = old.Added, old.Seq
+		} else {
+			s.seq++
+			e.Added, e.Seq = now, s.seq
+			added = append(added, e)
+		}
+		s.entries[k] = e
+	}
+	s.idx = nil
+	return added
+}
+
+// Prune drops entries older than t.
+func (s *Store) Prune(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.entries {
+		if e.Time.Before(t) {
+			delete(s.entries, k)
+		}
+	}
+	s.idx = nil
+}
+
+// Get returns the entry with key k, or nil.
+func (s *Store) Get(k string) *Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries[k]
+}
+
+// Feeds returns all entries grouped by feed url.
+func (s *Store) Feeds() map[string][]*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	feeds := make(map[string][]*Entry)
+	for _, e := range s.entries {
+		feeds[e.Feed] = append(feeds[e.Feed], e)
+	}
+	return feeds
+}
+
+// AddedBetween returns the entries first added after since, up to and including until.
+func (s *Store) AddedBetween(since, until time.Time) []*Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var es []*Entry
+	for _, e := range s.entries {
+		if e.Added.After(since) && !e.Added.After(until) {
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Save writes all entries to the backing file, if any.
+func (s *Store) Save() error {
+	if s.fn == "" {
+		return nil
+	}
+	s.mu.RLock()
+	es := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		es = append(es, e)
+	}
+	s.mu.RUnlock()
+	sort.Slice(es, func(i, j int) bool { return es[i].Time.After(es[j].Time) })
+
+	tmp := s.fn + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(f).Encode(es)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.fn)
+}
+
+// Search returns the entries matching q in the feeds subscribed to in subs,
+// newest first. Entries dropped by rules are never returned.
+func (s *Store) Search(q Query, subs []Sub, rules Rules) []*readss.SearchResult {
+	s.mu.Lock()
+	if s.idx == nil {
+		es := make([]*Entry, 0, len(s.entries))
+		for _, e := range s.entries {
+			es = append(es, e)
+		}
+		s.idx = NewIndex(es)
+	}
+	idx := s.idx
+	s.mu.Unlock()
+
+	feeds := make(map[string]Sub, len(subs))
+	for _, sub := range subs {
+		if _, ok := feeds[sub.feed()]; !ok {
+			feeds[sub.feed()] = sub
+		}
+	}
+
+	var es []*Entry
+	var ats []*readss.Article
+	for _, e := range idx.Match(q) {
+		sub, ok := feeds[e.Feed]
+		if !ok {
+			continue
+		}
+		v := rules.Apply(e, sub)
+		if v.Drop {
+			continue
+		}
+		at := e.Article(sub)
+		at.Tags = append(at.Tags, v.Tags...)
+		if q.Filter(e, at) {
+			es = append(es, e)
+			ats = append(ats, at)
+		}
+	}
+	sort.Sort(entryArticles{es, ats})
+	if len(es) > 100 {
+		es, ats = es[:100], ats[:100]
+	}
+
+	words := q.words()
+	rs := make([]*readss.SearchResult, len(es))
+	for i, e := range es {
+		body := htmlText(e.Summary)
+		if body == "" {
+			body = htmlText(e.Content)
+		}
+		rs[i] = &readss.SearchResult{
+			Article: ats[i],
+			Title:   highlight(e.Title, words, 0),
+			Snippet: highlight(body, words, 30),
+		}
+	}
+	return rs
+}
+
+// entryArticles sorts entries and their articles together, newest first.
+type entryArticles struct {
+	es  []*Entry
+	ats []*readss.Article
+}
+
+func (ea entryArticles) Len() int           { return len(ea.es) }
+func (ea entryArticles) Less(i, j int) bool { return ea.es[i].Time.After(ea.es[j].Time) }
+func (ea entryArticles) Swap(i, j int) {
+	ea.es[i], ea.es[j] = ea.es[j], ea.es[i]
+	ea.ats[i], ea.ats[j] = ea.ats[j], ea.ats[i]
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoTerm = errors.New("terminal not supported on this platform")
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errNoTerm
+}
+
+func termSize(fd int) (int, int, error) {
+	return 0, 0, errNoTerm
+}
+
+func notifyResize(c chan<- os.Signal) {}
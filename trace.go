@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// span kinds and status codes as defined by otlp
+const (
+	spanInternal = 1
+	spanServer   = 2
+	spanClient   = 3
+
+	statusOK    = 1
+	statusError = 2
+)
+
+// Span is a timed operation in a trace.
+type Span struct {
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+
+	mu     sync.Mutex
+	start  time.Time
+	end    time.Time
+	attrs  []interface{}
+	events []spanEvent
+	err    error
+}
+
+type spanEvent struct {
+	name string
+	at   time.Time
+}
+
+type spanKey struct{}
+
+// spanFrom returns the current span in ctx, or nil.
+func spanFrom(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// startSpan starts a child of the span in ctx, or a new trace if there is none.
+// kvs are alternating attribute keys and values.
+func startSpan(ctx context.Context, name string, kvs ...interface{}) (context.Context, *Span) {
+	s := &Span{
+		name:  name,
+		kind:  spanInternal,
+		start: time.Now(),
+		attrs: kvs,
+	}
+	if p := spanFrom(ctx); p != nil {
+		s.traceID = p.traceID
+		s.parent = p.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// TraceID is the hex encoded trace id.
+func (s *Span) TraceID() string {
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func (s *Span) SetAttributes(kvs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, kvs...)
+	s.mu.Unlock()
+}
+
+func (s *Span) AddEvent(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.events = append(s.events, spanEvent{name, time.Now()})
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed if err is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	tracer.enqueue(s)
+}
+
+// remoteParent returns ctx with the parent from a w3c traceparent header.
+func remoteParent(ctx context.Context, tp string) context.Context {
+	ps := strings.Split(strings.TrimSpace(tp), "-")
+	if len(ps) != 4 || len(ps[1]) != 32 || len(ps[2]) != 16 {
+		return ctx
+	}
+	s := &Span{}
+	if _, err := hex.Decode(s.traceID[:], []byte(ps[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(s.spanID[:], []byte(ps[2])); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// traceInterceptor records a server span for every rpc,
+// continuing traces from an incoming traceparent.
+func traceInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tp := md.Get("traceparent"); len(tp) > 0 {
+			ctx = remoteParent(ctx, tp[0])
+		}
+	}
+	ctx, sp := startSpan(ctx, info.FullMethod, "rpc.system", "grpc", "rpc.method", info.FullMethod)
+	sp.kind = spanServer
+	res, err := handler(ctx, req)
+	sp.SetAttributes("rpc.grpc.status_code", int(status.Code(err)))
+	sp.SetError(err)
+	sp.End()
+	return res, err
+}
+
+// clientTrace records the dns, connect and tls phases of requests
+// as children of the span in ctx.
+func clientTrace(ctx context.Context) *httptrace.ClientTrace {
+	var mu sync.Mutex
+	var dns, hs *Span
+	conns := make(map[string]*Span)
+	return &httptrace.ClientTrace{
+		DNSStart: func(i httptrace.DNSStartInfo) {
+			mu.Lock()
+			_, dns = startSpan(ctx, "dns", "net.host.name", i.Host)
+			mu.Unlock()
+		},
+		DNSDone: func(i httptrace.DNSDoneInfo) {
+			mu.Lock()
+			dns.SetError(i.Err)
+			dns.End()
+			dns = nil
+			mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			_, conns[network+addr] = startSpan(ctx, "connect", "net.transport", network, "net.peer.addr", addr)
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			sp := conns[network+addr]
+			delete(conns, network+addr)
+			mu.Unlock()
+			sp.SetError(err)
+			sp.End()
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			_, hs = startSpan(ctx, "tls")
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			mu.Lock()
+			hs.SetAttributes("tls.resumed", cs.DidResume)
+			hs.SetError(err)
+			hs.End()
+			hs = nil
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			spanFrom(ctx).AddEvent("first byte")
+		},
+	}
+}
+
+// tracer batches ended spans for an exporter.
+var tracer = &spanQueue{
+	q: make(chan *Span, 2048),
+}
+
+type spanQueue struct {
+	q chan *Span
+}
+
+func (t *spanQueue) enqueue(s *Span) {
+	select {
+	case t.q <- s:
+	default:
+		// drop rather than block when the exporter falls behind
+	}
+}
+
+// run exports queued spans every interval or when enough are queued,
+// flushing what is left once ctx is done.
+func (t *spanQueue) run(ctx context.Context, exp spanExporter, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := exp.export(batch); err != nil {
+			logger.Warn("export spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case s := <-t.q:
+			batch = append(batch, s)
+			if len(batch) >= 512 {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case s := <-t.q:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+type spanExporter interface {
+	export([]*Span) error
+}
+
+// newExporter exports over otlp/http json to endpoint,
+// or as json lines to stdout if endpoint is empty.
+func newExporter(endpoint string) spanExporter {
+	if endpoint == "" {
+		return &writerExporter{w: os.Stdout}
+	}
+	return &otlpExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func (e *otlpExporter) export(ss []*Span) error {
+	spans := make([]otlpSpan, 0, len(ss))
+	for _, s := range ss {
+		spans = append(spans, s.otlp())
+	}
+	var req otlpRequest
+	req.ResourceSpans = []otlpResourceSpans{{
+		Resource: otlpResource{
+			Attributes: otlpAttrs([]interface{}{"service.name", ServiceName}),
+		},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "readss"},
+			Spans: spans,
+		}},
+	}}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("status %v", res.Status)
+	}
+	return nil
+}
+
+type writerExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *writerExporter) export(ss []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range ss {
+		if err := enc.Encode(s.otlp()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otlp json encoding, see opentelemetry-proto/opentelemetry/proto/trace/v1
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Kind         int            `json:"kind"`
+	Start        string         `json:"startTimeUnixNano"`
+	End          string         `json:"endTimeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	Events       []otlpEvent    `json:"events,omitempty"`
+	Status       otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	Time string `json:"timeUnixNano"`
+	Name string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := otlpSpan{
+		TraceID:    hex.EncodeToString(s.traceID[:]),
+		SpanID:     hex.EncodeToString(s.spanID[:]),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+		End:        strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes: otlpAttrs(s.attrs),
+		Status:     otlpStatus{Code: statusOK},
+	}
+	if s.parent != [8]byte{} {
+		o.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	for _, e := range s.events {
+		o.Events = append(o.Events, otlpEvent{strconv.FormatInt(e.at.UnixNano(), 10), e.name})
+	}
+	if s.err != nil {
+		o.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+	}
+	return o
+}
+
+func otlpAttrs(kvs []interface{}) []otlpKeyValue {
+	var as []otlpKeyValue
+	for i := 0; i+1 < len(kvs); i += 2 {
+		var v otlpValue
+		switch vv := kvs[i+1].(type) {
+		case string:
+			v.String = &vv
+		case bool:
+			v.Bool = &vv
+		case int:
+			s := strconv.Itoa(vv)
+			v.Int = &s
+		case int64:
+			s := strconv.FormatInt(vv, 10)
+			v.Int = &s
+		case float64:
+			v.Double = &vv
+		default:
+			s := fmt.Sprint(vv)
+			v.String = &s
+		}
+		as = append(as, otlpKeyValue{fmt.Sprint(kvs[i]), v})
+	}
+	return as
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+
+	"seankhliao.com/readss/readss"
+)
+
+// tui is an interactive terminal client for a server's native grpc port.
+func tui(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	addr := fs.String("addr", envOr("READSS_ADDR", "localhost:8081"), "grpc address of the server (READSS_ADDR)")
+	user := fs.String("user", "", "user to list articles for")
+	login := fs.String("login", envOr("READSS_LOGIN", ""), "account login to authenticate as (READSS_LOGIN), the password is read from READSS_PASSWORD")
+	seenFile := fs.String("seen", defaultSeenFile(), "file to remember seen articles in")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()}
+	if *login != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(basicCreds{*login, os.Getenv("READSS_PASSWORD")}))
+	}
+	conn, err := grpc.DialContext(ctx, *addr, opts...)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("dial %v: %v", *addr, err)
+	}
+	defer conn.Close()
+
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	t := &termUI{
+		client:   readss.NewListerClient(conn),
+		user:     *user,
+		seenFile: *seenFile,
+		seen:     loadSeen(*seenFile),
+		out:      bufio.NewWriter(os.Stdout),
+	}
+	// alternate screen, hidden cursor
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	defer t.saveSeen()
+	return t.run(fd)
+}
+
+// basicCreds sends an account login with every call.
+type basicCreds struct {
+	login, password string
+}
+
+func (c basicCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.login+":"+c.password)),
+	}, nil
+}
+
+func (c basicCreds) RequireTransportSecurity() bool { return false }
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
+
+// defaultSeenFile is under XDG_STATE_HOME, or ~/.local/state.
+func defaultSeenFile() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "readss", "seen.json")
+}
+
+// seenRetain is how long seen article ids are remembered.
+const seenRetain = 90 * 24 * time.Hour
+
+// loadSeen reads the article ids seen previously, with when they were seen.
+func loadSeen(fn string) map[string]time.Time {
+	seen := make(map[string]time.Time)
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return seen
+	}
+	json.Unmarshal(b, &seen)
+	return seen
+}
+
+type termUI struct {
+	client   readss.ListerClient
+	user     string
+	seenFile string
+	seen     map[string]time.Time
+
+	all    []*readss.Article
+	view   []*readss.Article
+	cur    int
+	top    int
+	filter string
+	// editing is true while typing a filter
+	editing bool
+	status  string
+
+	w, h int
+	out  *bufio.Writer
+}
+
+func (t *termUI) saveSeen() {
+	for id, at := range t.seen {
+		if time.Since(at) > seenRetain {
+			delete(t.seen, id)
+		}
+	}
+	b, err := json.Marshal(t.seen)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(t.seenFile), 0755)
+	ioutil.WriteFile(t.seenFile, b, 0644)
+}
+
+func (t *termUI) run(fd int) error {
+	keys := make(chan string)
+	go readKeys(keys)
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	t.w, t.h, _ = termSize(fd)
+	t.reload()
+	for {
+		t.draw()
+		select {
+		case k, ok := <-keys:
+			if !ok || !t.key(k) {
+				return nil
+			}
+		case <-resize:
+			t.w, t.h, _ = termSize(fd)
+		}
+	}
+}
+
+// readKeys sends keypresses from stdin, escape sequences are kept whole.
+func readKeys(c chan<- string) {
+	defer close(c)
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		b := buf[:n]
+		if len(b) > 1 && b[0] == 0x1b {
+			c <- string(b)
+			continue
+		}
+		for len(b) > 0 {
+			_, size := utf8.DecodeRune(b)
+			c <- string(b[:size])
+			b = b[size:]
+		}
+	}
+}
+
+func (t *termUI) reload() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := t.client.List(ctx, &readss.ListRequest{User: t.user})
+	if err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.all = res.Articles
+	t.status = fmt.Sprintf("loaded %d articles", len(t.all))
+	t.apply()
+}
+
+// apply filters all into view, matching the filter case insensitively
+// against the title, source and tags.
+func (t *termUI) apply() {
+	f := strings.ToLower(t.filter)
+	t.view = t.view[:0]
+	for _, at := range t.all {
+		s := strings.ToLower(at.Title + " " + at.Source + " " + strings.Join(at.Tags, " "))
+		if strings.Contains(s, f) {
+			t.view = append(t.view, at)
+		}
+	}
+	t.move(0)
+}
+
+// move the cursor by d, keeping it on screen and marking what it lands on as seen.
+func (t *termUI) move(d int) {
+	t.cur += d
+	if t.cur >= len(t.view) {
+		t.cur = len(t.view) - 1
+	}
+	if t.cur < 0 {
+		t.cur = 0
+	}
+	rows := t.rows()
+	if t.cur < t.top {
+		t.top = t.cur
+	}
+	if t.cur >= t.top+rows {
+		t.top = t.cur - rows + 1
+	}
+	if at := t.current(); at != nil {
+		if _, ok := t.seen[at.Id]; !ok {
+			t.seen[at.Id] = time.Now()
+		}
+	}
+}
+
+func (t *termUI) current() *readss.Article {
+	if t.cur < 0 || t.cur >= len(t.view) {
+		return nil
+	}
+	return t.view[t.cur]
+}
+
+// rows is the number of articles that fit between the header and status lines.
+func (t *termUI) rows() int {
+	if t.h < 3 {
+		return 1
+	}
+	return t.h - 2
+}
+
+// key handles a keypress, returning false to quit.
+func (t *termUI) key(k string) bool {
+	if t.editing {
+		switch k {
+		case "\r", "\n":
+			t.editing = false
+		case "\x1b":
+			t.editing = false
+			t.filter = ""
+		case "\x7f", "\b":
+			if _, size := utf8.DecodeLastRuneInString(t.filter); size > 0 {
+				t.filter = t.filter[:len(t.filter)-size]
+			}
+		default:
+			if k >= " " && k[0] != 0x1b {
+				t.filter += k
+			}
+		}
+		t.apply()
+		return true
+	}
+
+	switch k {
+	case "q", "\x03":
+		return false
+	case "j", "\x1b[B", "\x0e":
+		t.move(1)
+	case "k", "\x1b[A", "\x10":
+		t.move(-1)
+	case " ", "\x1b[6~", "\x06":
+		t.move(t.rows())
+	case "b", "\x1b[5~", "\x02":
+		t.move(-t.rows())
+	case "g", "\x1b[H", "\x1b[1~":
+		t.move(-len(t.view))
+	case "G", "\x1b[F", "\x1b[4~":
+		t.move(len(t.view))
+	case "n":
+		for i := t.cur + 1; i < len(t.view); i++ {
+			if _, ok := t.seen[t.view[i].Id]; !ok {
+				t.move(i - t.cur)
+				break
+			}
+		}
+	case "/":
+		t.editing = true
+		t.filter = ""
+		t.apply()
+	case "\x1b":
+		t.filter = ""
+		t.apply()
+	case "r":
+		t.reload()
+	case "o", "\r", "\n":
+		if at := t.current(); at != nil {
+			if err := openBrowser(at.Url); err != nil {
+				t.status = err.Error()
+			} else {
+				t.status = "opened " + at.Url
+			}
+			t.saveSeen()
+		}
+	}
+	return true
+}
+
+// openBrowser opens u with the first command in $BROWSER,
+// or the platform default.
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	if b := strings.Split(os.Getenv("BROWSER"), string(os.PathListSeparator))[0]; b != "" {
+		cmd = exec.Command(b, u)
+	} else {
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", u)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+		default:
+			cmd = exec.Command("xdg-open", u)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func (t *termUI) draw() {
+	w := t.out
+	w.WriteString("\x1b[H")
+
+	header := fmt.Sprintf(" readss  %d/%d articles", len(t.view), len(t.all))
+	if t.user != "" {
+		header += "  user: " + t.user
+	}
+	if t.filter != "" && !t.editing {
+		header += "  filter: " + t.filter
+	}
+	fmt.Fprintf(w, "\x1b[7m%s\x1b[0m\r\n", pad(header, t.w))
+
+	for i := 0; i < t.rows(); i++ {
+		n := t.top + i
+		if n >= len(t.view) {
+			w.WriteString("\x1b[K\r\n")
+			continue
+		}
+		at := t.view[n]
+		mark := " "
+		if _, ok := t.seen[at.Id]; !ok {
+			mark = "*"
+		}
+		line := fmt.Sprintf("%s %-10s %-16s %s", mark, at.Reltime, trunc(at.Source, 16), at.Title)
+		style := ""
+		if mark == "*" {
+			style = "\x1b[1m"
+		}
+		if n == t.cur {
+			style += "\x1b[7m"
+		}
+		fmt.Fprintf(w, "%s%s\x1b[0m\r\n", style, pad(line, t.w))
+	}
+
+	if t.editing {
+		fmt.Fprintf(w, "%s\x1b[K", trunc("/"+t.filter+"_", t.w))
+	} else {
+		help := "j/k move  enter open  / filter  n next unseen  r reload  q quit"
+		if t.status != "" {
+			help = t.status + "  |  " + help
+		}
+		fmt.Fprintf(w, "%s\x1b[K", trunc(help, t.w))
+	}
+	w.Flush()
+}
+
+// trunc cuts s to at most n runes.
+func trunc(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// pad cuts or extends s to exactly n runes.
+func pad(s string, n int) string {
+	s = trunc(s, n)
+	if c := utf8.RuneCountInString(s); c < n {
+		s += strings.Repeat(" ", n-c)
+	}
+	return s
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"seankhliao.com/readss/readss"
+)
+
+var (
+	webhookClient = &http.Client{Timeout: 30 * time.Second}
+	// webhookBackoff is the wait before the first retry, doubling after each
+	webhookBackoff = time.Second
+)
+
+// Webhook posts new articles of User matching Query to URL.
+type Webhook struct {
+	Name string
+	URL  string
+	// Format is one of json, slack, matrix
+	Format string
+	User   string
+	Query  Query
+	// Secret signs the body with hmac sha256 if set
+	Secret string
+}
+
+// parseWebhooks reads a csv file of webhooks,
+// each record is: name, url, json|slack|matrix, followed by optional key=value options:
+//
+//	user=user whose subscriptions and tags are used, default ""
+//	query=search query articles must match
+//	secret=key to sign payloads with
+//	secret_env=environment variable holding the key
+func parseWebhooks(fn string) []Webhook {
+	hs, errs := readWebhooks(fn)
+	for _, err := range errs {
+		logger.Warn("parse webhooks", "file", fn, "error", err)
+	}
+	return hs
+}
+
+// readWebhooks is parseWebhooks, returning the problems it skipped.
+func readWebhooks(fn string) ([]Webhook, []error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	rr, err := cr.ReadAll()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var errs []error
+	hs := make([]Webhook, 0, len(rr))
+records:
+	for _, r := range rr {
+		if len(r) < 3 {
+			errs = append(errs, fmt.Errorf("short record %v", r))
+			continue
+		}
+		h := Webhook{
+			Name:   r[0],
+			URL:    r[1],
+			Format: r[2],
+		}
+		switch h.Format {
+		case "json", "slack", "matrix":
+		default:
+			errs = append(errs, fmt.Errorf("%v: unknown format %q", h.Name, h.Format))
+			continue
+		}
+		for _, opt := range r[3:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				errs = append(errs, fmt.Errorf("%v: bad option %q", h.Name, opt))
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "user":
+				h.User = kv[1]
+			case "query":
+				h.Query, err = ParseQuery(kv[1])
+				if err != nil {
+					// an unparsable filter would send everything
+					errs = append(errs, fmt.Errorf("%v: bad query: %v", h.Name, err))
+					continue records
+				}
+			case "secret":
+				h.Secret = kv[1]
+			case "secret_env":
+				h.Secret = os.Getenv(kv[1])
+				if h.Secret == "" {
+					errs = append(errs, fmt.Errorf("%v: %v is empty", h.Name, kv[1]))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("%v: unknown option %q", h.Name, kv[0]))
+			}
+		}
+		hs = append(hs, h)
+	}
+	return hs, errs
+}
+
+// notifyWebhooks sends the new entries es to every webhook with matching articles,
+// in the background until ctx is done.
+func (s *Server) notifyWebhooks(ctx context.Context, es []*Entry, users map[string][]Sub, rules Rules) {
+	for _, h := range parseWebhooks(Webhooks) {
+		subs, ok := users[h.User]
+		if !ok {
+			logger.Warn("webhook for unknown user", "webhook", h.Name, "user", h.User)
+			continue
+		}
+		_, ats := matchEntries(es, h.Query, subs, rules)
+		if len(ats) == 0 {
+			continue
+		}
+		body, err := webhookPayload(h, ats)
+		if err != nil {
+			logger.Error("webhook payload", "webhook", h.Name, "error", err)
+			continue
+		}
+		if !s.startDelivery() {
+			deadLetter(h, randomHex(8), 0, errors.New("shutting down"), body)
+			continue
+		}
+		go func(h Webhook) {
+			defer s.deliveries.Done()
+			deliverWebhook(ctx, h, body)
+		}(h)
+	}
+}
+
+// startDelivery counts a new delivery for Run to wait on,
+// unless the server is already draining and Run may be past waiting.
+func (s *Server) startDelivery() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.deliveries.Add(1)
+	return true
+}
+
+// webhookPayload formats articles for h.
+func webhookPayload(h Webhook, ats []*readss.Article) ([]byte, error) {
+	switch h.Format {
+	case "slack":
+		// https://api.slack.com/reference/surfaces/formatting
+		esc := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+		var b strings.Builder
+		for _, at := range ats {
+			fmt.Fprintf(&b, "• <%s|%s> (%s)\n", at.Url, esc.Replace(at.Title), esc.Replace(at.Source))
+		}
+		return json.Marshal(map[string]string{"text": b.String()})
+	case "matrix":
+		// the text and html fields of matrix-hookshot generic webhooks
+		var t, hb strings.Builder
+		hb.WriteString("<ul>")
+		for _, at := range ats {
+			fmt.Fprintf(&t, "- %s (%s) %s\n", at.Title, at.Source, at.Url)
+			fmt.Fprintf(&hb, `<li><a href="%s">%s</a> (%s)</li>`, html.EscapeString(at.Url), html.EscapeString(at.Title), html.EscapeString(at.Source))
+		}
+		hb.WriteString("</ul>")
+		return json.Marshal(map[string]string{"text": t.String(), "html": hb.String()})
+	}
+	return json.Marshal(struct {
+		Webhook  string            `json:"webhook"`
+		Articles []*readss.Article `json:"articles"`
+	}{h.Name, ats})
+}
+
+// deliverWebhook posts body to h, retrying server errors with backoff,
+// and writing it to the dead letter log if it cannot be delivered.
+func deliverWebhook(ctx context.Context, h Webhook, body []byte) {
+	delivery := randomHex(8)
+
+	var err error
+	attempts := 0
+	for backoff := webhookBackoff; attempts <= WebhookRetries; backoff *= 2 {
+		attempts++
+		var retry bool
+		retry, err = postWebhook(ctx, h, delivery, body)
+		if err == nil {
+			webhookDeliveries.Add(1, h.Name, "ok")
+			logger.Debug("delivered webhook", "webhook", h.Name, "delivery", delivery, "attempts", attempts)
+			return
+		}
+		logger.Warn("deliver webhook", "webhook", h.Name, "delivery", delivery, "attempt", attempts, "error", err)
+		if !retry || attempts > WebhookRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			continue
+		}
+		break
+	}
+	webhookDeliveries.Add(1, h.Name, "failed")
+	deadLetter(h, delivery, attempts, err, body)
+}
+
+// postWebhook makes a single delivery attempt,
+// reporting whether a failure is worth retrying.
+func postWebhook(ctx context.Context, h Webhook, delivery string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "readss")
+	req.Header.Set("X-Readss-Delivery", delivery)
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Readss-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	res, err := webhookClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("status %v", res.Status)
+	}
+	return false, fmt.Errorf("status %v", res.Status)
+}
+
+var deadLetterMu sync.Mutex
+
+// deadLetter appends an undeliverable payload to WebhookDeadLetter as a json line.
+func deadLetter(h Webhook, delivery string, attempts int, err error, body []byte) {
+	logger.Error("webhook undeliverable", "webhook", h.Name, "delivery", delivery, "attempts", attempts, "error", err)
+	if WebhookDeadLetter == "" {
+		return
+	}
+	b, _ := json.Marshal(struct {
+		Time     time.Time       `json:"time"`
+		Webhook  string          `json:"webhook"`
+		URL      string          `json:"url"`
+		Delivery string          `json:"delivery"`
+		Attempts int             `json:"attempts"`
+		Error    string          `json:"error"`
+		Body     json.RawMessage `json:"body"`
+	}{time.Now(), h.Name, h.URL, delivery, attempts, fmt.Sprint(err), body})
+
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	f, ferr := os.OpenFile(WebhookDeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if ferr != nil {
+		logger.Error("open dead letter log", "file", WebhookDeadLetter, "error", ferr)
+		return
+	}
+	defer f.Close()
+	if _, ferr := f.Write(append(b, '\n')); ferr != nil {
+		logger.Error("write dead letter log", "file", WebhookDeadLetter, "error", ferr)
+	}
+}
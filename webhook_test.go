@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testWebhooks writes a webhooks file of records to dir and points Webhooks and WebhookDeadLetter at dir.
+func testWebhooks(t *testing.T, dir string, records ...string) {
+	t.Helper()
+	Webhooks = filepath.Join(dir, "webhooks.csv")
+	WebhookDeadLetter = filepath.Join(dir, "deadletter")
+	if err := ioutil.WriteFile(Webhooks, []byte(strings.Join(records, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readDeadLetter returns the records of the dead letter log.
+func readDeadLetter(t *testing.T) []map[string]interface{} {
+	t.Helper()
+	b, err := ioutil.ReadFile(WebhookDeadLetter)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	var ls []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var l map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("dead letter line %q: %v", line, err)
+		}
+		ls = append(ls, l)
+	}
+	return ls
+}
+
+func TestNotifyWebhooksDraining(t *testing.T) {
+	defer func(h, dl string) { Webhooks, WebhookDeadLetter = h, dl }(Webhooks, WebhookDeadLetter)
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+	testWebhooks(t, dir, fmt.Sprintf("hook,%s,json", ts.URL))
+
+	feed := "https://example.com/feed"
+	users := map[string][]Sub{"": {{Name: "Some Blog", URL: feed}}}
+	es := []*Entry{{ID: "new", Feed: feed, Title: "fresh news", Time: time.Now()}}
+
+	s := &Server{}
+	s.notifyWebhooks(context.Background(), es, users, nil)
+	s.deliveries.Wait()
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("deliveries before draining = %d, want 1", n)
+	}
+
+	s.draining = true
+	s.notifyWebhooks(context.Background(), es, users, nil)
+	s.deliveries.Wait()
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("deliveries while draining = %d, want none", n-1)
+	}
+	ls := readDeadLetter(t)
+	if len(ls) != 1 || ls[0]["webhook"] != "hook" || ls[0]["error"] != "shutting down" {
+		t.Errorf("dead letter = %v, want the payload not sent while draining", ls)
+	}
+}
+
+type webhookHit struct {
+	delivery  string
+	signature string
+	body      string
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	defer func(dl string, r int, b time.Duration) {
+		WebhookDeadLetter, WebhookRetries, webhookBackoff = dl, r, b
+	}(WebhookDeadLetter, WebhookRetries, webhookBackoff)
+	webhookBackoff = time.Millisecond
+	body := `{"articles":[],"webhook":"hook"}`
+
+	tcs := []struct {
+		name     string
+		secret   string
+		retries  int
+		statuses []int
+		attempts int
+		dead     string
+	}{
+		{name: "ok", retries: 2, statuses: []int{200}, attempts: 1},
+		{name: "signed", secret: "s3cret", retries: 2, statuses: []int{204}, attempts: 1},
+		{name: "retry server error", retries: 2, statuses: []int{500, 502, 200}, attempts: 3},
+		{name: "retry rate limit", retries: 2, statuses: []int{429, 200}, attempts: 2},
+		{name: "no retry client error", retries: 2, statuses: []int{400}, attempts: 1, dead: "status 400 Bad Request"},
+		{name: "no retry not found", retries: 2, statuses: []int{404}, attempts: 1, dead: "status 404 Not Found"},
+		{name: "give up", secret: "s3cret", retries: 2, statuses: []int{503, 503, 503, 200}, attempts: 3, dead: "status 503 Service Unavailable"},
+		{name: "no retries", retries: 0, statuses: []int{500, 200}, attempts: 1, dead: "status 500 Internal Server Error"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "readss")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			WebhookDeadLetter = filepath.Join(dir, "deadletter")
+			WebhookRetries = tc.retries
+
+			var mu sync.Mutex
+			var hits []webhookHit
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				mu.Lock()
+				hits = append(hits, webhookHit{r.Header.Get("X-Readss-Delivery"), r.Header.Get("X-Readss-Signature-256"), string(b)})
+				status := tc.statuses[len(hits)-1]
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+			defer ts.Close()
+
+			h := Webhook{Name: "hook", URL: ts.URL, Format: "json", Secret: tc.secret}
+			deliverWebhook(context.Background(), h, []byte(body))
+
+			if len(hits) != tc.attempts {
+				t.Fatalf("attempts = %d, want %d", len(hits), tc.attempts)
+			}
+			wantSig := ""
+			if tc.secret != "" {
+				mac := hmac.New(sha256.New, []byte(tc.secret))
+				mac.Write([]byte(body))
+				wantSig = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			}
+			for i, hit := range hits {
+				if hit.body != body {
+					t.Errorf("attempt %d body = %q, want %q", i, hit.body, body)
+				}
+				if hit.signature != wantSig {
+					t.Errorf("attempt %d signature = %q, want %q", i, hit.signature, wantSig)
+				}
+				if hit.delivery == "" || hit.delivery != hits[0].delivery {
+					t.Errorf("attempt %d delivery = %q, want %q on every attempt", i, hit.delivery, hits[0].delivery)
+				}
+			}
+
+			ls := readDeadLetter(t)
+			if tc.dead == "" {
+				if len(ls) != 0 {
+					t.Errorf("dead letter = %v, want none", ls)
+				}
+				return
+			}
+			if len(ls) != 1 {
+				t.Fatalf("dead letter = %v, want 1 record", ls)
+			}
+			l := ls[0]
+			if l["webhook"] != "hook" || l["url"] != ts.URL || l["delivery"] != hits[0].delivery ||
+				l["attempts"] != float64(tc.attempts) || l["error"] != tc.dead {
+				t.Errorf("dead letter = %v, want %d attempts failing with %q", l, tc.attempts, tc.dead)
+			}
+			if b, _ := json.Marshal(l["body"]); string(b) != body {
+				t.Errorf("dead letter body = %s, want %s", b, body)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhookCanceled(t *testing.T) {
+	defer func(dl string, r int, b time.Duration) {
+		WebhookDeadLetter, WebhookRetries, webhookBackoff = dl, r, b
+	}(WebhookDeadLetter, WebhookRetries, webhookBackoff)
+	dir, err := ioutil.TempDir("", "readss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	WebhookDeadLetter = filepath.Join(dir, "deadletter")
+	WebhookRetries, webhookBackoff = 3, time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		// cancel while waiting to retry
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+	}))
+	defer ts.Close()
+
+	deliverWebhook(ctx, Webhook{Name: "hook", URL: ts.URL}, []byte(`{}`))
+	if hits != 1 {
+		t.Errorf("attempts = %d, want 1", hits)
+	}
+	ls := readDeadLetter(t)
+	if len(ls) != 1 || !strings.HasSuffix(ls[0]["error"].(string), context.Canceled.Error()) {
+		t.Errorf("dead letter = %v, want a canceled delivery", ls)
+	}
+}
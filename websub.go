@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// websub holds push subscriptions to the hubs advertised by feeds,
+// it is nil if WebSubCallback is unset and feeds are only polled.
+var websub *webSub
+
+// webSub subscribes to feeds through WebSub hubs,
+// https://www.w3.org/TR/websub/
+// Feeds are still polled every tick, catching anything a hub missed.
+type webSub struct {
+	// callback is the public url of the websub handler, ending in /
+	callback string
+	lease    time.Duration
+	// ingest is called with feeds pushed by hubs
+	ingest func(ctx context.Context, u string, feed *gofeed.Feed)
+
+	ctx  context.Context
+	mu   sync.Mutex
+	subs map[string]*pushSub
+}
+
+// pushSub is the subscription to a single feed, keyed by pushID of the feed.
+type pushSub struct {
+	feed   string
+	hub    string
+	topic  string
+	secret string
+	// mode is the request waiting for verification by the hub, if any
+	mode      string
+	requested time.Time
+	// lease and expires are set when the hub verifies a subscription
+	lease   time.Duration
+	expires time.Time
+}
+
+// websubRetry is how long to wait for a hub that failed or denied a request.
+const websubRetry = time.Hour
+
+func newWebSub(callback string, lease time.Duration, ingest func(context.Context, string, *gofeed.Feed)) *webSub {
+	if !strings.HasSuffix(callback, "/") {
+		callback += "/"
+	}
+	return &webSub{
+		callback: callback,
+		lease:    lease,
+		ingest:   ingest,
+		ctx:      context.Background(),
+		subs:     make(map[string]*pushSub),
+	}
+}
+
+// pushID is the callback path for feed u.
+func pushID(u string) string {
+	h := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(h[:12])
+}
+
+// run renews leases a quarter of the way before they expire until ctx is done.
+func (w *webSub) run(ctx context.Context) {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		w.mu.Lock()
+		for _, s := range w.subs {
+			if s.mode == "" && !s.expires.IsZero() && time.Until(s.expires) < s.lease/4 {
+				w.request(s, "subscribe")
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// discover subscribes to the hub of feed u, if it has one and we aren't already.
+func (w *webSub) discover(u string, feed *gofeed.Feed) {
+	if w == nil {
+		return
+	}
+	hub, topic := feed.Custom["hub"], feed.Custom["self"]
+	if topic == "" {
+		topic = u
+	}
+	id := pushID(u)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.subs[id]
+	if hub == "" {
+		if s != nil {
+			logger.Info("feed no longer has a hub, polling", "url", u, "hub", s.hub)
+			delete(w.subs, id)
+		}
+		return
+	}
+	switch {
+	case s == nil || s.hub != hub || s.topic != topic || s.mode == "unsubscribe":
+	case s.expires.IsZero() && time.Since(s.requested) > websubRetry:
+		// never verified, or denied
+	default:
+		return
+	}
+	s = &pushSub{feed: u, hub: hub, topic: topic, secret: randomHex(16)}
+	w.subs[id] = s
+	w.request(s, "subscribe")
+}
+
+// keep unsubscribes from feeds that are no longer subscribed to.
+func (w *webSub) keep(feeds map[string][]*Entry) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, s := range w.subs {
+		if _, ok := feeds[s.feed]; !ok && s.mode != "unsubscribe" {
+			w.request(s, "unsubscribe")
+		}
+	}
+}
+
+// request asks the hub to verify mode for s in the background,
+// w.mu must be held.
+func (w *webSub) request(s *pushSub, mode string) {
+	s.mode = mode
+	s.requested = time.Now()
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {s.topic},
+		"hub.callback": {w.callback + pushID(s.feed)},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.lease_seconds", strconv.Itoa(int(w.lease.Seconds())))
+		form.Set("hub.secret", s.secret)
+	}
+	ctx := w.ctx
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		req, err := http.NewRequest(http.MethodPost, s.hub, strings.NewReader(form.Encode()))
+		if err != nil {
+			logger.Warn("websub request", "url", s.feed, "hub", s.hub, "mode", mode, "error", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("User-Agent", "readss")
+		res, err := feedClient.Do(req.WithContext(ctx))
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				err = fmt.Errorf("status %v", res.Status)
+			}
+		}
+		if err != nil {
+			logger.Warn("websub request", "url", s.feed, "hub", s.hub, "mode", mode, "error", err)
+			return
+		}
+		logger.Debug("websub requested", "url", s.feed, "hub", s.hub, "mode", mode)
+	}()
+}
+
+// ServeHTTP handles intent verification and content distribution from hubs
+// on <callback>/<pushID>.
+func (w *webSub) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	w.mu.Lock()
+	s := w.subs[id]
+	w.mu.Unlock()
+	if s == nil {
+		http.NotFound(rw, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.verify(rw, r, id, s)
+	case http.MethodPost:
+		w.receive(rw, r, s)
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify answers the challenge of a hub if it matches a request we made.
+func (w *webSub) verify(rw http.ResponseWriter, r *http.Request, id string, s *pushSub) {
+	q := r.URL.Query()
+	mode, topic := q.Get("hub.mode"), q.Get("hub.topic")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// anyone can call, only requests about our subscription count
+	if topic != s.topic || w.subs[id] != s {
+		http.NotFound(rw, r)
+		return
+	}
+	if mode == "denied" {
+		logger.Warn("websub denied", "url", s.feed, "hub", s.hub, "reason", q.Get("hub.reason"))
+		s.mode, s.expires = "", time.Time{}
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+	if mode != s.mode {
+		http.NotFound(rw, r)
+		return
+	}
+	switch mode {
+	case "subscribe":
+		lease, _ := strconv.Atoi(q.Get("hub.lease_seconds"))
+		s.lease = time.Duration(lease) * time.Second
+		if s.lease <= 0 {
+			s.lease = w.lease
+		}
+		s.expires = time.Now().Add(s.lease)
+		logger.Info("websub subscribed", "url", s.feed, "hub", s.hub, "lease", s.lease)
+	case "unsubscribe":
+		delete(w.subs, id)
+		logger.Info("websub unsubscribed", "url", s.feed, "hub", s.hub)
+	}
+	s.mode = ""
+	rw.Header().Set("Content-Type", "text/plain")
+	rw.Write([]byte(q.Get("hub.challenge")))
+}
+
+// receive ingests content pushed by the hub,
+// content with a bad signature is acknowledged and dropped.
+func (w *webSub) receive(rw http.ResponseWriter, r *http.Request, s *pushSub) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, 16<<20))
+	if err != nil {
+		websubPushes.Add(1, "error")
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw.WriteHeader(http.StatusAccepted)
+
+	if err := checkHubSignature(r.Header.Get("X-Hub-Signature"), s.secret, b); err != nil {
+		websubPushes.Add(1, "bad_signature")
+		logger.Warn("websub push", "url", s.feed, "hub", s.hub, "error", err)
+		return
+	}
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(b))
+	if err != nil {
+		websubPushes.Add(1, "error")
+		logger.Warn("websub push", "url", s.feed, "hub", s.hub, "error", err)
+		return
+	}
+	websubPushes.Add(1, "ok")
+	w.mu.Lock()
+	ctx := w.ctx
+	w.mu.Unlock()
+	go w.ingest(ctx, s.feed, feed)
+}
+
+// ingest merges entries pushed for feed u into the served articles
+// without waiting for the next refresh.
+func (s *Server) ingest(ctx context.Context, u string, feed *gofeed.Feed) {
+	t := time.Now()
+	ctx, sp := startSpan(ctx, "push", "url", u)
+	defer sp.End()
+
+	s.mu.RLock()
+	users, rules := s.users, s.rules
+	s.mu.RUnlock()
+	pushed := map[string][]*Entry{u: feedEntries(u, feed)}
+	if ResolveRedirects {
+		resolveEntries(ctx, pushed, s.store)
+	}
+	extractEntries(ctx, users, pushed, s.store)
+	if ctx.Err() != nil {
+		sp.SetError(ctx.Err())
+		return
+	}
+
+	s.mu.Lock()
+	feeds := make(map[string][]*Entry, len(s.feeds))
+	for k, es := range s.feeds {
+		feeds[k] = es
+	}
+	feeds[u] = mergeEntries(feeds[u], pushed[u])
+	s.feeds = feeds
+	s.lists = userArticles(users, feeds, rules)
+	s.mu.Unlock()
+
+	added := s.add(pushed[u], time.Now().Add(-Retain))
+	archives.queue(users, added)
+	if Webhooks != "" && len(added) > 0 {
+		s.notifyWebhooks(ctx, added, users, rules)
+	}
+	sp.SetAttributes("items", len(pushed[u]), "added", len(added))
+	logger.Info("ingested push", "url", u, "duration", time.Since(t), "items", len(pushed[u]), "added", len(added), "trace_id", sp.TraceID())
+}
+
+// mergeEntries replaces entries in es with those in pushed,
+// hubs may push only the new or changed entries of a feed.
+func mergeEntries(es, pushed []*Entry) []*Entry {
+	merged := make([]*Entry, 0, len(es)+len(pushed))
+	keys := make(map[string]bool, len(pushed))
+	for _, e := range pushed {
+		keys[e.key()] = true
+		merged = append(merged, e)
+	}
+	for _, e := range es {
+		if !keys[e.key()] {
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// checkHubSignature checks a method=hexdigest signature of b.
+func checkHubSignature(sig, secret string, b []byte) error {
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+	kv := strings.SplitN(sig, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("bad signature %q", sig)
+	}
+	var h func() hash.Hash
+	switch kv[0] {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	case "sha384":
+		h = sha512.New384
+	case "sha512":
+		h = sha512.New
+	default:
+		return fmt.Errorf("unknown signature method %q", kv[0])
+	}
+	got, err := hex.DecodeString(kv[1])
+	if err != nil {
+		return fmt.Errorf("bad signature %q", sig)
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(b)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// feedLinks finds the hub and self links of feed u,
+// from Link headers or link elements before the first item.
+func feedLinks(u string, h http.Header, b []byte) (hub, self string) {
+	for _, l := range h["Link"] {
+		for _, part := range strings.Split(l, ",") {
+			i, j := strings.Index(part, "<"), strings.Index(part, ">")
+			if i < 0 || j < i {
+				continue
+			}
+			for _, p := range strings.Split(part[j+1:], ";") {
+				kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+				if len(kv) != 2 || kv[0] != "rel" {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(kv[1], `"`)) {
+					if rel == "hub" && hub == "" {
+						hub = part[i+1 : j]
+					} else if rel == "self" && self == "" {
+						self = part[i+1 : j]
+					}
+				}
+			}
+		}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+tokens:
+	for hub == "" || self == "" {
+		t, err := d.Token()
+		if err != nil {
+			break
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "item", "entry":
+			break tokens
+		case "link":
+			var rel, href string
+			for _, a := range se.Attr {
+				switch a.Name.Local {
+				case "rel":
+					rel = a.Value
+				case "href":
+					href = a.Value
+				}
+			}
+			for _, r := range strings.Fields(rel) {
+				if r == "hub" && hub == "" {
+					hub = href
+				} else if r == "self" && self == "" {
+					self = href
+				}
+			}
+		}
+	}
+	return resolveRef(u, hub), resolveRef(u, self)
+}
+
+// resolveRef resolves ref relative to base.
+func resolveRef(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := b.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return r.String()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCheckHubSignature(t *testing.T) {
+	body := []byte("<feed></feed>")
+	sign := func(h func() hash.Hash, secret string) string {
+		m := hmac.New(h, []byte(secret))
+		m.Write(body)
+		return hex.EncodeToString(m.Sum(nil))
+	}
+	tcs := []struct {
+		name string
+		sig  string
+		err  string
+	}{
+		{"sha1", "sha1=" + sign(sha1.New, "s3cret"), ""},
+		{"sha256", "sha256=" + sign(sha256.New, "s3cret"), ""},
+		{"missing", "", "missing signature"},
+		{"no method", sign(sha1.New, "s3cret"), `bad signature "` + sign(sha1.New, "s3cret") + `"`},
+		{"unknown method", "md5=00", `unknown signature method "md5"`},
+		{"not hex", "sha1=zz", `bad signature "sha1=zz"`},
+		{"wrong secret", "sha1=" + sign(sha1.New, "other"), "signature mismatch"},
+		{"wrong method", "sha256=" + sign(sha1.New, "s3cret"), "signature mismatch"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkHubSignature(tc.sig, "s3cret", body)
+			if tc.err == "" && err != nil {
+				t.Errorf("checkHubSignature: %v", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("checkHubSignature error = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestWebSubVerify(t *testing.T) {
+	const feed, topic = "https://example.com/feed", "https://example.com/feed.xml"
+	id := pushID(feed)
+	tcs := []struct {
+		name    string
+		pending string
+		query   url.Values
+		status  int
+		// subscribed is whether the subscription has a lease afterwards
+		subscribed bool
+	}{
+		{
+			name:       "subscribe",
+			pending:    "subscribe",
+			query:      url.Values{"hub.mode": {"subscribe"}, "hub.topic": {topic}, "hub.challenge": {"c"}, "hub.lease_seconds": {"60"}},
+			status:     http.StatusOK,
+			subscribed: true,
+		},
+		{
+			name:    "subscribe other topic",
+			pending: "subscribe",
+			query:   url.Values{"hub.mode": {"subscribe"}, "hub.topic": {"https://evil.example/"}, "hub.challenge": {"c"}},
+			status:  http.StatusNotFound,
+		},
+		{
+			name:       "unrequested mode",
+			pending:    "",
+			query:      url.Values{"hub.mode": {"unsubscribe"}, "hub.topic": {topic}, "hub.challenge": {"c"}},
+			status:     http.StatusNotFound,
+			subscribed: true,
+		},
+		{
+			name:    "denied",
+			pending: "subscribe",
+			query:   url.Values{"hub.mode": {"denied"}, "hub.topic": {topic}},
+			status:  http.StatusOK,
+		},
+		{
+			name:       "denied other topic",
+			pending:    "",
+			query:      url.Values{"hub.mode": {"denied"}, "hub.topic": {"https://evil.example/"}},
+			status:     http.StatusNotFound,
+			subscribed: true,
+		},
+		{
+			name:       "denied no topic",
+			pending:    "",
+			query:      url.Values{"hub.mode": {"denied"}},
+			status:     http.StatusNotFound,
+			subscribed: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newWebSub("https://readss.example/websub/", time.Hour, nil)
+			s := &pushSub{feed: feed, hub: "https://hub.example/", topic: topic, mode: tc.pending}
+			if tc.pending == "" {
+				s.lease, s.expires = time.Hour, time.Now().Add(time.Hour)
+			}
+			w.subs[id] = s
+
+			rec := httptest.NewRecorder()
+			w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/websub/"+id+"?"+tc.query.Encode(), nil))
+			if rec.Code != tc.status {
+				t.Errorf("status = %v, want %v", rec.Code, tc.status)
+			}
+			if got := !s.expires.IsZero(); got != tc.subscribed {
+				t.Errorf("subscribed = %v, want %v", got, tc.subscribed)
+			}
+			if tc.status == http.StatusOK && tc.query.Get("hub.challenge") != "" && rec.Body.String() != tc.query.Get("hub.challenge") {
+				t.Errorf("body = %q, want challenge", rec.Body.String())
+			}
+		})
+	}
+}